@@ -9,6 +9,9 @@ import (
 var ProjectConfig *Config
 
 type Config struct {
+	Server struct {
+		TrustedProxies []string `yaml:"trustedProxies"` // 可信的反向代理地址或网段，只有来自这些地址的请求才使用 X-Forwarded-For 中的客户端IP
+	} `yaml:"ServerConfig"`
 	Database struct {
 		Host   string `yaml:"Host"`
 		Port   string `yaml:"Port"`
@@ -17,8 +20,11 @@ type Config struct {
 		DBName string `yaml:"DBName"`
 	} `yaml:"Database"`
 	Volunteer struct {
-		TwtKey    string `yaml:"jwt_key"`
-		JwtExpiry int    `yaml:"jwt_expiry"`
+		TwtKey        string   `yaml:"jwt_key"`
+		AccessExpiry  int      `yaml:"access_expiry"`  // 访问令牌有效期（分钟）
+		RefreshExpiry int      `yaml:"refresh_expiry"` // 刷新令牌有效期（小时）
+		SigningKey    string   `yaml:"signing_key"`    // 签发访问令牌的私钥文件（PEM，RSA 或 Ed25519），未配置时使用 jwt_key 以 HS256 签名
+		VerifyKeys    []string `yaml:"verify_keys"`    // 密钥轮换期间仍然接受的其他公钥文件（PEM）
 	} `yaml:"VolunteerConfig"`
 	AliyunOSS struct {
 		AccessKeyId     string `yaml:"accessKeyId"`
@@ -28,6 +34,51 @@ type Config struct {
 		Endpoint        string `yaml:"endpoint"`
 		Area            string `yaml:"area"`
 	} `yaml:"AliyunOSSConfig"`
+	Email struct {
+		Enabled     bool     `yaml:"enabled"`     // 是否启用邮件通知
+		Sink        string   `yaml:"sink"`        // 投递方式：smtp、file 或 memory
+		SinkDir     string   `yaml:"sinkDir"`     // sink 为 file 时邮件的保存目录
+		Host        string   `yaml:"host"`        // SMTP 服务器地址
+		Port        int      `yaml:"port"`        // SMTP 服务器端口
+		Username    string   `yaml:"username"`    // SMTP 用户名
+		Password    string   `yaml:"password"`    // SMTP 密码
+		From        string   `yaml:"from"`        // 发件人地址
+		UseTLS      bool     `yaml:"useTLS"`      // 是否使用 TLS 直连（465端口），否则使用 STARTTLS
+		MaxAttempts int      `yaml:"maxAttempts"` // 最大重试次数
+		Categories  []string `yaml:"categories"`  // 需要发送邮件的消息分类
+	} `yaml:"EmailConfig"`
+	Account struct {
+		FrontendURL     string `yaml:"frontendURL"`     // 前端地址，用于生成邮件中的链接
+		ResetExpiry     int    `yaml:"resetExpiry"`     // 重置密码链接的有效期（分钟）
+		VerifyExpiry    int    `yaml:"verifyExpiry"`    // 邮箱验证链接的有效期（小时）
+		UnverifiedDays  int    `yaml:"unverifiedDays"`  // 注册后多少天仍未验证邮箱的账户会被删除
+		MaxLoginFails   int    `yaml:"maxLoginFails"`   // 连续登录失败多少次后锁定账户
+		LockoutMinutes  int    `yaml:"lockoutMinutes"`  // 首次锁定的时长（分钟），之后每次失败翻倍
+		RequireAdmin2FA bool   `yaml:"requireAdmin2FA"` // 是否要求可以管理活动的账户开启两步验证
+		TOTPKeyFile     string `yaml:"totpKeyFile"`     // 加密两步验证密钥的 AES-256 密钥文件，内容为 Base64 编码的 32 字节随机数
+	} `yaml:"AccountConfig"`
+	PasswordPolicy struct {
+		MinLength     int  `yaml:"minLength"`     // 最短长度，默认8位
+		MinClasses    int  `yaml:"minClasses"`    // 至少包含大写字母、小写字母、数字、符号中的几类，默认2类
+		RequireUpper  bool `yaml:"requireUpper"`  // 是否必须包含大写字母
+		RequireLower  bool `yaml:"requireLower"`  // 是否必须包含小写字母
+		RequireDigit  bool `yaml:"requireDigit"`  // 是否必须包含数字
+		RequireSymbol bool `yaml:"requireSymbol"` // 是否必须包含符号
+	} `yaml:"PasswordPolicyConfig"`
+	OIDC struct {
+		Enabled      bool     `yaml:"enabled"`      // 是否启用统一身份认证登录
+		Name         string   `yaml:"name"`         // 登录页面显示的身份提供方名称
+		Issuer       string   `yaml:"issuer"`       // 身份提供方地址，通过 /.well-known/openid-configuration 获取端点
+		ClientID     string   `yaml:"clientId"`     // 客户端ID
+		ClientSecret string   `yaml:"clientSecret"` // 客户端密钥，公共客户端留空
+		RedirectURL  string   `yaml:"redirectURL"`  // 前端的回调地址，需要在身份提供方处登记
+		Scopes       []string `yaml:"scopes"`       // 申请的权限范围，默认 openid、email、profile
+		AutoRegister bool     `yaml:"autoRegister"` // 邮箱未注册时是否自动创建账户
+		TrustEmail   bool     `yaml:"trustEmail"`   // 身份提供方不返回 email_verified 时是否信任其返回的邮箱
+	} `yaml:"OIDCConfig"`
+	Reminder struct {
+		Offsets []string `yaml:"offsets"` // 活动开始前多久发送提醒，例如 24h、2h
+	} `yaml:"ReminderConfig"`
 }
 
 func LoadConfig() {
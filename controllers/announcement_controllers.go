@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/services"
+	"volunteer-system-backend/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// AnnouncementController 公告控制器
+type AnnouncementController struct {
+	announcements *services.AnnouncementService
+}
+
+// NewAnnouncementController 创建新的公告控制器实例
+func NewAnnouncementController(announcements *services.AnnouncementService) *AnnouncementController {
+	return &AnnouncementController{announcements: announcements}
+}
+
+// CreateAnnouncement 发布公告
+// @Summary 发布公告
+// @Description 向组织内所有成员、指定活动的报名人、拥有指定技能的成员或参加过指定类别活动的成员发布公告，支持定时发送和过期时间
+// @Tags announcement
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.CreateAnnouncementRequest true "公告信息"
+// @Router /announcement/create [post]
+func (ac *AnnouncementController) CreateAnnouncement(c *gin.Context) {
+	var input dto.CreateAnnouncementRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	announcement, err := ac.announcements.CreateAnnouncement(currentOrgID(c), email.(string), input)
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "发布公告失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "发布公告成功", gin.H{"announcement": announcement})
+}
+
+// GetAnnouncements 获取公告列表
+// @Summary 获取公告列表
+// @Description 获取当前组织的公告列表，包括接收人数和已读人数
+// @Tags announcement
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Router /announcement/list [get]
+func (ac *AnnouncementController) GetAnnouncements(c *gin.Context) {
+	announcements, err := services.GetAnnouncements(currentOrgID(c))
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "获取公告列表失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取公告列表成功", gin.H{"announcements": announcements})
+}
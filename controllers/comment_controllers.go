@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/services"
+	"volunteer-system-backend/utils"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// CommentController 活动讨论控制器
+type CommentController struct {
+	comments *services.CommentService
+}
+
+// NewCommentController 创建新的活动讨论控制器实例
+func NewCommentController(comments *services.CommentService) *CommentController {
+	return &CommentController{comments: comments}
+}
+
+// GetTaskComments 获取活动讨论
+// @Summary 获取活动讨论
+// @Description 获取指定活动讨论区中的提问及其回复，置顶的讨论排在前面
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param TaskId query int true "任务ID"
+// @Router /task/comments [get]
+func (cc *CommentController) GetTaskComments(c *gin.Context) {
+	taskId, err := strconv.Atoi(c.Query("TaskId"))
+	if err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "任务ID格式错误，必须为有效的整数", nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	comments, err := services.GetTaskComments(currentOrgID(c), email.(string), uint(taskId))
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "获取活动讨论失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取活动讨论成功", gin.H{"comments": comments})
+}
+
+// CreateTaskComment 发布活动讨论
+// @Summary 发布活动讨论
+// @Description 报名该活动的用户和活动管理员可以提问或回复，回复会通知该讨论中的其他参与者
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.TaskCommentRequest true "讨论内容"
+// @Router /task/comment [post]
+func (cc *CommentController) CreateTaskComment(c *gin.Context) {
+	var input dto.TaskCommentRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	comment, err := cc.comments.CreateTaskComment(currentOrgID(c), email.(string), input)
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "发布讨论失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "发布讨论成功", gin.H{"comment": comment})
+}
+
+// authorizeCommentModerator 检查当前用户是否可以管理讨论所属的活动，不满足时直接写入错误响应
+func authorizeCommentModerator(c *gin.Context, commentId uint) bool {
+	taskId, err := services.GetCommentTaskID(currentOrgID(c), commentId)
+	if err != nil {
+		utils.Respond(c, http.StatusNotFound, "error", err.Error(), nil)
+		return false
+	}
+	return authorizeTaskManager(c, taskId)
+}
+
+// PinTaskComment 置顶活动讨论
+// @Summary 置顶活动讨论
+// @Description 管理员或该活动的协调员置顶或取消置顶提问和回复
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.CommentModerationRequest true "value 为 true 时置顶"
+// @Router /task/pinComment [post]
+func (cc *CommentController) PinTaskComment(c *gin.Context) {
+	var input dto.CommentModerationRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if !authorizeCommentModerator(c, input.CommentID) {
+		return
+	}
+	if err := services.PinTaskComment(currentOrgID(c), input.CommentID, input.Value); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "置顶讨论失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "置顶讨论成功", nil)
+}
+
+// HideTaskComment 隐藏活动讨论
+// @Summary 隐藏活动讨论
+// @Description 管理员或该活动的协调员隐藏或取消隐藏提问和回复，隐藏后只有活动管理员可见
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.CommentModerationRequest true "value 为 true 时隐藏"
+// @Router /task/hideComment [post]
+func (cc *CommentController) HideTaskComment(c *gin.Context) {
+	var input dto.CommentModerationRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if !authorizeCommentModerator(c, input.CommentID) {
+		return
+	}
+	if err := services.HideTaskComment(currentOrgID(c), input.CommentID, input.Value); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "隐藏讨论失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "隐藏讨论成功", nil)
+}
+
+// DeleteTaskComment 删除活动讨论
+// @Summary 删除活动讨论
+// @Description 发布者或活动管理员删除讨论，删除提问时一并删除其下的回复
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param CommentId query int true "讨论ID"
+// @Router /task/deleteComment [delete]
+func (cc *CommentController) DeleteTaskComment(c *gin.Context) {
+	commentId, err := strconv.Atoi(c.Query("CommentId"))
+	if err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "讨论ID格式错误，必须为有效的整数", nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	if err := services.DeleteTaskComment(currentOrgID(c), email.(string), uint(commentId)); err != nil {
+		if errors.Is(err, services.ErrNoTaskPermission) {
+			utils.Respond(c, http.StatusForbidden, "error", err.Error(), nil)
+			return
+		}
+		utils.Respond(c, http.StatusInternalServerError, "error", "删除讨论失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "删除讨论成功", nil)
+}
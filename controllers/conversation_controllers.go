@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/services"
+	"volunteer-system-backend/utils"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// conversationErrorStatus 根据私信服务返回的错误选择响应状态码
+func conversationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrConversationForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrRateLimited):
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// GetConversations 获取我的私信会话
+// @Summary 获取我的私信会话
+// @Description 获取当前用户在当前组织中的私信会话及未读数
+// @Tags conversation
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Router /conversation/list [get]
+func GetConversations(c *gin.Context) {
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	conversations, err := services.GetConversations(currentOrgID(c), email.(string))
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "获取会话列表失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取会话列表成功", gin.H{"conversations": conversations})
+}
+
+// StartConversation 发起私信会话
+// @Summary 发起私信会话
+// @Description 向一个或多个用户发起私信会话，志愿者只能联系自己报名的活动的协调员，多人会话中的接收者之间也需要能够互相发送私信
+// @Tags conversation
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.StartConversationRequest true "会话信息"
+// @Router /conversation/start [post]
+func StartConversation(c *gin.Context) {
+	var input dto.StartConversationRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	conversationId, err := services.StartConversation(currentOrgID(c), email.(string), input)
+	if err != nil {
+		utils.Respond(c, conversationErrorStatus(err), "error", "发起会话失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "发起会话成功", gin.H{"conversationId": conversationId})
+}
+
+// SendDirectMessage 发送私信
+// @Summary 发送私信
+// @Description 在参与的会话中发送私信
+// @Tags conversation
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.SendDirectMessageRequest true "私信内容"
+// @Router /conversation/send [post]
+func SendDirectMessage(c *gin.Context) {
+	var input dto.SendDirectMessageRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	message, err := services.SendDirectMessage(currentOrgID(c), email.(string), input)
+	if err != nil {
+		utils.Respond(c, conversationErrorStatus(err), "error", "发送私信失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "发送私信成功", gin.H{"message": message})
+}
+
+// GetDirectMessages 获取会话中的私信
+// @Summary 获取会话中的私信
+// @Description 获取会话中的私信，不传 beforeId 时获取最新的私信并将会话标记为已读
+// @Tags conversation
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param conversationId query int true "会话ID"
+// @Param beforeId query int false "获取该私信之前的历史私信"
+// @Param limit query int false "条数，默认50，最大100"
+// @Router /conversation/messages [get]
+func GetDirectMessages(c *gin.Context) {
+	var query dto.DirectMessageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	messages, err := services.GetDirectMessages(currentOrgID(c), email.(string), query)
+	if err != nil {
+		utils.Respond(c, conversationErrorStatus(err), "error", "获取私信失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取私信成功", gin.H{"messages": messages})
+}
+
+// MarkConversationRead 标记会话已读
+// @Summary 标记会话已读
+// @Description 将会话中的私信全部标记为已读
+// @Tags conversation
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.ConversationReadRequest true "会话ID"
+// @Router /conversation/read [post]
+func MarkConversationRead(c *gin.Context) {
+	var input dto.ConversationReadRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	if err := services.MarkConversationRead(currentOrgID(c), email.(string), input.ConversationID); err != nil {
+		utils.Respond(c, conversationErrorStatus(err), "error", "标记已读失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "标记已读成功", nil)
+}
+
+// GetOrgConversations 获取组织内的私信会话
+// @Summary 获取组织内的私信会话
+// @Description 管理员查看当前组织内的所有私信会话
+// @Tags conversation
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Router /conversation/moderation/list [get]
+func GetOrgConversations(c *gin.Context) {
+	conversations, err := services.GetOrgConversations(currentOrgID(c))
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "获取会话列表失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取会话列表成功", gin.H{"conversations": conversations})
+}
+
+// GetModeratedDirectMessages 查看会话中的私信
+// @Summary 查看会话中的私信
+// @Description 管理员查看当前组织内会话中的私信，包括被屏蔽的私信
+// @Tags conversation
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param conversationId query int true "会话ID"
+// @Param beforeId query int false "获取该私信之前的历史私信"
+// @Param limit query int false "条数，默认50，最大100"
+// @Router /conversation/moderation/messages [get]
+func GetModeratedDirectMessages(c *gin.Context) {
+	var query dto.DirectMessageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	messages, err := services.GetModeratedDirectMessages(currentOrgID(c), query)
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "获取私信失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取私信成功", gin.H{"messages": messages})
+}
+
+// HideDirectMessage 屏蔽私信
+// @Summary 屏蔽私信
+// @Description 管理员屏蔽或取消屏蔽当前组织内的私信，屏蔽后会话参与者不可见
+// @Tags conversation
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.DirectMessageModerationRequest true "value 为 true 时屏蔽"
+// @Router /conversation/moderation/hide [post]
+func HideDirectMessage(c *gin.Context) {
+	var input dto.DirectMessageModerationRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if err := services.HideDirectMessage(currentOrgID(c), input.MessageID, input.Value); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "屏蔽私信失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "屏蔽私信成功", nil)
+}
+
+// DeleteConversation 删除会话
+// @Summary 删除会话
+// @Description 管理员删除当前组织内的会话及其中的私信
+// @Tags conversation
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param ConversationId query int true "会话ID"
+// @Router /conversation/moderation/delete [delete]
+func DeleteConversation(c *gin.Context) {
+	conversationId, err := strconv.Atoi(c.Query("ConversationId"))
+	if err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "会话ID格式错误，必须为有效的整数", nil)
+		return
+	}
+	if err := services.DeleteConversation(currentOrgID(c), uint(conversationId)); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "删除会话失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "删除会话成功", nil)
+}
@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"volunteer-system-backend/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// JWKS 公开校验访问令牌的公钥
+// @Summary 访问令牌公钥
+// @Description 以标准 JWK Set 格式返回校验访问令牌使用的公钥，其他服务按令牌头部的 kid 选择公钥校验令牌，无需共享密钥
+// @Tags user
+// @Produce json
+// @Router /.well-known/jwks.json [get]
+func JWKS(c *gin.Context) {
+	// 使用标准格式而不是统一的响应结构，便于通用的 JWT 库直接读取
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.PublicJWKS())
+}
@@ -2,12 +2,20 @@ package controllers
 
 import (
 	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
 	"volunteer-system-backend/services"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
+	"time"
 )
 
+// streamHeartbeatInterval 消息推送连接的心跳间隔，防止代理因空闲断开连接
+const streamHeartbeatInterval = 25 * time.Second
+
 // MessageController 消息控制器
 type MessageController struct {
 	messageService services.MessageService
@@ -20,66 +28,96 @@ func NewMessageController(messageService services.MessageService) *MessageContro
 	}
 }
 
-// GetMessagesByUserID 根据用户ID获取消息列表
-func (c *MessageController) GetMessagesByUserID(ctx *gin.Context) {
-	userIDStr := ctx.Param("userID")
-	userID, err := strconv.Atoi(userIDStr)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户 ID"})
+// toMessageResponse 转换为消息响应结构体
+func toMessageResponse(message models.Message) dto.MessageResponse {
+	return dto.MessageResponse{
+		ID:       message.ID,
+		Category: message.Category,
+		Title:    message.Title,
+		Content:  message.Content,
+		Time:     message.Time.Format("2006-01-02 15:04"),
+		Status:   message.Status,
+	}
+}
+
+// GetMyMessages 分页获取当前用户的消息列表，支持按状态和分类过滤
+func (c *MessageController) GetMyMessages(ctx *gin.Context) {
+	var query dto.MessageQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actor, ok := currentUser(ctx)
+	if !ok {
 		return
 	}
 
-	messages, err := c.messageService.GetMessagesByUserID(uint(userID))
+	messages, total, err := c.messageService.ListMessages(actor, currentOrgID(ctx), &query)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "无法获取消息"})
 		return
 	}
 
-	var messageResponses []dto.MessageResponse
+	messageResponses := make([]dto.MessageResponse, 0, len(messages))
 	for _, message := range messages {
-		messageResponse := dto.MessageResponse{
-			ID:      message.ID,
-			Title:   message.Title,
-			Content: message.Content,
-			Time:    message.Time.Format("2006-01-02 15:04"),
-			Status:  message.Status,
-		}
-		messageResponses = append(messageResponses, messageResponse)
+		messageResponses = append(messageResponses, toMessageResponse(message))
+	}
+
+	ctx.JSON(http.StatusOK, dto.MessageListResponse{
+		Total:    total,
+		Page:     query.Page,
+		PageSize: query.PageSize,
+		Messages: messageResponses,
+	})
+}
+
+// GetUnreadCount 获取当前用户的未读消息数
+func (c *MessageController) GetUnreadCount(ctx *gin.Context) {
+	actor, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	count, err := c.messageService.CountUnread(actor, currentOrgID(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "无法获取未读消息数"})
+		return
 	}
 
-	ctx.JSON(http.StatusOK, messageResponses)
+	ctx.JSON(http.StatusOK, gin.H{"unread": count})
 }
 
 // CreateMessage 创建新消息
 func (c *MessageController) CreateMessage(ctx *gin.Context) {
 	var request struct {
-		UserID  uint   `json:"userID" binding:"required"`
-		Title   string `json:"title" binding:"required"`
-		Content string `json:"content" binding:"required"`
+		UserID   uint   `json:"userID" binding:"required"`
+		Category string `json:"category"`
+		Title    string `json:"title" binding:"required"`
+		Content  string `json:"content" binding:"required"`
 	}
 	if err := ctx.ShouldBindJSON(&request); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	actor, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
 
-	message, err := c.messageService.CreateMessage(request.UserID, request.Title, request.Content)
+	message, err := c.messageService.CreateMessage(actor, currentOrgID(ctx), request.UserID, request.Category, request.Title, request.Content)
 	if err != nil {
+		if errors.Is(err, services.ErrMessageForbidden) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "创建消息失败"})
 		return
 	}
 
-	messageResponse := dto.MessageResponse{
-		ID:      message.ID,
-		Title:   message.Title,
-		Content: message.Content,
-		Time:    message.Time.Format("2006-01-02 15:04"),
-		Status:  message.Status,
-	}
-
-	ctx.JSON(http.StatusCreated, messageResponse)
+	ctx.JSON(http.StatusCreated, toMessageResponse(*message))
 }
 
-// MarkMessageAsRead 将消息标记为已读
+// MarkMessageAsRead 将当前用户的消息标记为已读
 func (c *MessageController) MarkMessageAsRead(ctx *gin.Context) {
 	messageIDStr := ctx.Param("messageID")
 	messageID, err := strconv.Atoi(messageIDStr)
@@ -87,12 +125,162 @@ func (c *MessageController) MarkMessageAsRead(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "消息 ID 无效"})
 		return
 	}
+	actor, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
 
-	err = c.messageService.MarkMessageAsRead(uint(messageID))
+	err = c.messageService.MarkMessageAsRead(actor, uint(messageID))
 	if err != nil {
+		if errors.Is(err, services.ErrMessageForbidden) || errors.Is(err, services.ErrMessageNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": services.ErrMessageNotFound.Error()})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "无法将消息标记为已读"})
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"message": "标记为已读的消息"})
 }
+
+// MarkAllAsRead 将当前用户的所有消息标记为已读
+func (c *MessageController) MarkAllAsRead(ctx *gin.Context) {
+	actor, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	count, err := c.messageService.MarkAllAsRead(actor, currentOrgID(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "无法将消息标记为已读"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "已全部标记为已读", "updated": count})
+}
+
+// DeleteMessage 删除当前用户的消息
+func (c *MessageController) DeleteMessage(ctx *gin.Context) {
+	messageID, err := strconv.Atoi(ctx.Param("messageID"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "消息 ID 无效"})
+		return
+	}
+	actor, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	err = c.messageService.DeleteMessage(actor, uint(messageID))
+	if err != nil {
+		if errors.Is(err, services.ErrMessageForbidden) || errors.Is(err, services.ErrMessageNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": services.ErrMessageNotFound.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "无法删除消息"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "消息已删除"})
+}
+
+// CreateStreamTicket 签发建立消息推送连接的一次性凭证，浏览器以 /message/stream?ticket= 建立 EventSource 连接，
+// 凭证一分钟内有效且只能使用一次，每次连接或重连前都需要重新获取
+func (c *MessageController) CreateStreamTicket(ctx *gin.Context) {
+	ticket, expiresAt, err := services.IssueStreamTicket(ctx.GetString("Email"), currentOrgID(ctx), ctx.GetUint("TokenVersion"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"ticket": ticket, "expiresIn": int(time.Until(expiresAt).Seconds())})
+}
+
+// StreamMessages 通过 Server-Sent Events 推送当前用户的新消息
+// 客户端重连时通过 Last-Event-ID 请求头（或 lastEventId 查询参数）补齐断线期间的消息，
+// 客户端接收过慢时服务端会断开连接，客户端重连后同样通过 Last-Event-ID 补齐
+func (c *MessageController) StreamMessages(ctx *gin.Context) {
+	actor, ok := currentUser(ctx)
+	if !ok {
+		return
+	}
+	orgID := currentOrgID(ctx)
+
+	lastEventID := ctx.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = ctx.Query("lastEventId")
+	}
+	var lastID uint
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Last-Event-ID 无效"})
+			return
+		}
+		lastID = uint(id)
+	}
+
+	// 先订阅再补齐，避免补齐期间产生的消息丢失
+	messages, unsubscribe := c.messageService.Subscribe(actor)
+	defer unsubscribe()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	fmt.Fprintf(ctx.Writer, "retry: %d\n\n", 3000)
+
+	// 分批补齐，直到没有遗漏的消息
+	for lastID > 0 {
+		missed, err := c.messageService.MessagesSince(actor, orgID, lastID)
+		if err != nil {
+			return
+		}
+		if len(missed) == 0 {
+			break
+		}
+		for _, message := range missed {
+			if writeMessageEvent(ctx, message) != nil {
+				return
+			}
+			lastID = message.ID
+		}
+	}
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			if message.ID <= lastID || (message.OrganizationID != 0 && message.OrganizationID != orgID) {
+				continue
+			}
+			if writeMessageEvent(ctx, message) != nil {
+				return
+			}
+			lastID = message.ID
+			ctx.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(ctx.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		}
+	}
+}
+
+// writeMessageEvent 以 Server-Sent Events 格式写出一条消息，事件ID为消息ID
+func writeMessageEvent(ctx *gin.Context, message models.Message) error {
+	data, err := json.Marshal(toMessageResponse(message))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(ctx.Writer, "id: %d\nevent: message\ndata: %s\n\n", message.ID, data)
+	return err
+}
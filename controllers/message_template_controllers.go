@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/services"
+	"volunteer-system-backend/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetMessageTemplates 获取消息模板列表
+// @Summary 获取消息模板列表
+// @Description 获取所有事件类型在各语言下的消息模板
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Router /admin/message_templates [get]
+func GetMessageTemplates(c *gin.Context) {
+	templates, err := services.GetMessageTemplates()
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "获取消息模板失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取消息模板成功", gin.H{"templates": templates})
+}
+
+// UpdateMessageTemplate 修改消息模板
+// @Summary 修改消息模板
+// @Description 修改指定事件类型在指定语言下的消息模板，标题和内容使用 Go text/template 语法，可用变量见预览接口的示例数据
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.UpdateMessageTemplateRequest true "消息模板"
+// @Router /admin/message_templates [put]
+func UpdateMessageTemplate(c *gin.Context) {
+	var input dto.UpdateMessageTemplateRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	if err := services.UpdateMessageTemplate(email.(string), input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "修改消息模板失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "修改消息模板成功", nil)
+}
+
+// PreviewMessageTemplate 预览消息模板
+// @Summary 预览消息模板
+// @Description 使用提交的变量渲染消息模板，未提交的变量使用示例数据
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.PreviewMessageTemplateRequest true "模板内容"
+// @Router /admin/message_templates/preview [post]
+func PreviewMessageTemplate(c *gin.Context) {
+	var input dto.PreviewMessageTemplateRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	preview, err := services.PreviewMessageTemplate(input)
+	if err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "预览消息模板失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "预览消息模板成功", gin.H{"preview": preview})
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/services"
+	"volunteer-system-backend/utils"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// 保存发起统一身份认证登录的浏览器标识的 Cookie，只发送给统一身份认证接口
+const (
+	oidcBindingCookie = "oidc_binding"
+	oidcCookiePath    = "/api/oidc"
+)
+
+// oidcErrorStatus 根据统一身份认证服务返回的错误选择响应状态码
+func oidcErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrOIDCDisabled):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidOIDCState), errors.Is(err, services.ErrOIDCProvider):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrOIDCEmailNotVerified), errors.Is(err, services.ErrOIDCSignupDisabled):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// OIDCAuthorize 发起统一身份认证登录
+// @Summary 发起统一身份认证登录
+// @Description 返回身份提供方的授权地址（授权码模式 + PKCE）并写入 HttpOnly Cookie，前端保存返回的 state 后跳转到该地址，回调接口需要携带该 Cookie
+// @Tags user
+// @Produce json
+// @Router /api/oidc/authorize [get]
+func OIDCAuthorize(c *gin.Context) {
+	info, binding, err := services.OIDCAuthorize()
+	if err != nil {
+		utils.Respond(c, oidcErrorStatus(err), "error", err.Error(), nil)
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcBindingCookie, binding, int(services.OIDCStateExpiry.Seconds()), oidcCookiePath, "", c.Request.TLS != nil, true)
+	utils.Respond(c, http.StatusOK, "success", "获取授权地址成功", info)
+}
+
+// OIDCCallback 统一身份认证登录回调
+// @Summary 统一身份认证登录回调
+// @Description 提交身份提供方回调地址中的 code 和 state 完成登录，首次登录时按已验证的邮箱关联账户；开启两步验证的用户返回预认证令牌
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param request body dto.OIDCCallbackRequest true "授权码和 state"
+// @Router /api/oidc/callback [post]
+func OIDCCallback(c *gin.Context) {
+	var input dto.OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	// 只有发起登录的浏览器持有该 Cookie，无论登录是否成功都清除
+	binding, _ := c.Cookie(oidcBindingCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcBindingCookie, "", -1, oidcCookiePath, "", c.Request.TLS != nil, true)
+	tokens, err := services.OIDCLogin(input.Code, input.State, binding, c.ClientIP())
+	if err != nil {
+		utils.Respond(c, oidcErrorStatus(err), "error", "登录失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "登录成功", tokens)
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/services"
+	"volunteer-system-backend/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// OrganizationController 组织控制器
+type OrganizationController struct {
+	organizations *services.OrganizationService
+}
+
+// NewOrganizationController 创建新的组织控制器实例
+func NewOrganizationController(organizations *services.OrganizationService) *OrganizationController {
+	return &OrganizationController{organizations: organizations}
+}
+
+// CreateOrganization 创建组织
+// @Summary 创建组织
+// @Description 创建新的组织并生成邀请码
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.CreateOrganizationRequest true "组织信息"
+// @Router /admin/create_org [post]
+func (oc *OrganizationController) CreateOrganization(c *gin.Context) {
+	var input dto.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	org, err := services.CreateOrganization(input.Name)
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "创建组织失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "创建组织成功", gin.H{"organization": org})
+}
+
+// GetUserOrganizations 获取当前用户加入的组织
+// @Summary 获取当前用户加入的组织
+// @Description 获取当前用户加入的组织及其在组织中的角色
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Router /user/organizations [get]
+func (oc *OrganizationController) GetUserOrganizations(c *gin.Context) {
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	orgs, err := services.GetUserOrganizations(email.(string))
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "获取组织列表失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取组织列表成功", gin.H{"organizations": orgs, "current": currentOrgID(c)})
+}
+
+// SwitchOrganization 切换当前组织
+// @Summary 切换当前组织
+// @Description 切换当前所在组织并返回新的 token
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.SwitchOrganizationRequest true "组织信息"
+// @Router /user/switch_org [post]
+func (oc *OrganizationController) SwitchOrganization(c *gin.Context) {
+	var input dto.SwitchOrganizationRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	token, err := services.SwitchOrganization(email.(string), input.OrganizationID, c.GetString("JTI"), c.GetTime("TokenExpiresAt"))
+	if err != nil {
+		utils.Respond(c, http.StatusForbidden, "error", "切换组织失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "切换组织成功", gin.H{"token": token})
+}
+
+// JoinOrganization 凭邀请码加入组织
+// @Summary 加入组织
+// @Description 凭邀请码以志愿者身份加入组织
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.JoinOrganizationRequest true "邀请码"
+// @Router /user/join_org [post]
+func (oc *OrganizationController) JoinOrganization(c *gin.Context) {
+	var input dto.JoinOrganizationRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	org, err := oc.organizations.JoinOrganization(email.(string), input.Code)
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "加入组织失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "加入组织成功", gin.H{"organization": org})
+}
+
+// InviteOrganizationMember 邀请用户加入当前组织或更新成员角色
+// @Summary 邀请组织成员
+// @Description 邀请用户以指定角色加入当前组织，用户接受邀请后才成为成员；已是成员时直接更新其角色
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.OrganizationMemberRequest true "成员信息"
+// @Router /org/invite_member [post]
+func (oc *OrganizationController) InviteOrganizationMember(c *gin.Context) {
+	var input dto.OrganizationMemberRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	if input.Role == "" {
+		input.Role = models.RoleVolunteer
+	}
+	if err := oc.organizations.InviteOrganizationMember(currentOrgID(c), email.(string), input.Email, input.Role); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "邀请组织成员失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "邀请组织成员成功，对方接受邀请后加入组织", nil)
+}
+
+// GetOrganizationInvitations 获取待处理的组织邀请
+// @Summary 获取组织邀请
+// @Description 获取当前用户收到的待处理组织邀请
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Router /user/org_invitations [get]
+func (oc *OrganizationController) GetOrganizationInvitations(c *gin.Context) {
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	invitations, err := services.GetOrganizationInvitations(email.(string))
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "获取组织邀请失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取组织邀请成功", gin.H{"invitations": invitations})
+}
+
+// RespondOrganizationInvitation 答复组织邀请
+// @Summary 答复组织邀请
+// @Description 接受或拒绝组织邀请，接受后以邀请中的角色成为组织成员
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.OrganizationInvitationReply true "答复信息"
+// @Router /user/respond_org_invitation [post]
+func (oc *OrganizationController) RespondOrganizationInvitation(c *gin.Context) {
+	var input dto.OrganizationInvitationReply
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	org, err := services.RespondOrganizationInvitation(email.(string), input.InvitationId, input.Accept)
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "答复组织邀请失败："+err.Error(), nil)
+		return
+	}
+	if !input.Accept {
+		utils.Respond(c, http.StatusOK, "success", "已拒绝组织邀请", nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "加入组织成功", gin.H{"organization": org})
+}
+
+// RemoveOrganizationMember 移除当前组织的成员
+// @Summary 移除组织成员
+// @Description 将用户移出当前组织
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.OrganizationMemberRequest true "成员信息"
+// @Router /org/remove_member [post]
+func (oc *OrganizationController) RemoveOrganizationMember(c *gin.Context) {
+	var input dto.OrganizationMemberRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if err := services.RemoveOrganizationMember(currentOrgID(c), input.Email); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "移除组织成员失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "移除组织成员成功", nil)
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/services"
+	"volunteer-system-backend/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// currentOrgID 获取当前请求所在的组织ID
+func currentOrgID(c *gin.Context) uint {
+	return c.GetUint("OrgID")
+}
+
+// authorizeTaskManager 检查当前用户是否可以管理指定任务，不满足时直接写入错误响应
+func authorizeTaskManager(c *gin.Context, taskId uint) bool {
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return false
+	}
+	ok, err := services.CanManageTask(email.(string), currentOrgID(c), taskId)
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "权限校验失败："+err.Error(), nil)
+		return false
+	}
+	if !ok {
+		utils.Respond(c, http.StatusForbidden, "error", services.ErrNoTaskPermission.Error(), nil)
+		return false
+	}
+	return true
+}
+
+// authorizeTaskViewer 检查当前用户是否可以查看指定任务的报名情况，不满足时直接写入错误响应
+func authorizeTaskViewer(c *gin.Context, taskId uint) bool {
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return false
+	}
+	ok, err := services.CanViewTaskAudit(email.(string), currentOrgID(c), taskId)
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "权限校验失败："+err.Error(), nil)
+		return false
+	}
+	if !ok {
+		utils.Respond(c, http.StatusForbidden, "error", "没有查看该活动报名情况的权限", nil)
+		return false
+	}
+	return true
+}
+
+// currentUser 获取当前登录的用户，获取失败时直接写入错误响应
+func currentUser(c *gin.Context) (*models.User, bool) {
+	email, exists := c.Get("Email")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未登录"})
+		return nil, false
+	}
+	user, err := services.GetUserProfile(email)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return user, true
+}
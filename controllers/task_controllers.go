@@ -9,6 +9,16 @@ import (
 	"strconv"
 )
 
+// TaskController 活动控制器
+type TaskController struct {
+	tasks *services.TaskService
+}
+
+// NewTaskController 创建新的活动控制器实例
+func NewTaskController(tasks *services.TaskService) *TaskController {
+	return &TaskController{tasks: tasks}
+}
+
 // JoinTask 加入任务
 // @Summary 加入任务
 // @Description 用户加入任务
@@ -18,7 +28,7 @@ import (
 // @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
 // @Param task body dto.TaskInfo true "任务信息"
 // @Router /task/join [post]
-func JoinTask(c *gin.Context) {
+func (tc *TaskController) JoinTask(c *gin.Context) {
 	nickname, exists := c.Get("Nickname")
 	if !exists {
 		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
@@ -33,7 +43,7 @@ func JoinTask(c *gin.Context) {
 	}
 
 	// 调用服务层的 JoinTask 方法处理加入任务逻辑
-	err := services.JoinTask(TaskRegistration, nickname, email)
+	err := tc.tasks.JoinTask(currentOrgID(c), TaskRegistration, nickname, email)
 	if err != nil {
 		if err.Error() == "活动不存在" {
 			utils.Respond(c, http.StatusNotFound, "error", err.Error(), nil)
@@ -56,15 +66,15 @@ func JoinTask(c *gin.Context) {
 // @Produce json
 // @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
 // @Param name body dto.TaskInfo true "任务信息"
-// @Router /admin/create_task [post]
-func CreateTask(c *gin.Context) {
+// @Router /task/create_task [post]
+func (tc *TaskController) CreateTask(c *gin.Context) {
 	var input dto.CreateTaskInfo
 	if err := c.ShouldBindJSON(&input); err != nil {
 		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
 		return
 	}
 
-	task, err := services.CreateTask(input.Name, input.StartTime, input.EndTime, input.Location, input.Limit)
+	task, err := services.CreateTask(currentOrgID(c), input.Name, input.StartTime, input.EndTime, input.Location, input.Category, input.Limit)
 	if err != nil {
 		utils.Respond(c, http.StatusInternalServerError, "error", "创建活动失败："+err.Error(), nil)
 		return
@@ -80,8 +90,8 @@ func CreateTask(c *gin.Context) {
 // @Produce json
 // @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
 // @Router /task/tasks [get]
-func GetTasks(c *gin.Context) {
-	tasks, err := services.GetTasks()
+func (tc *TaskController) GetTasks(c *gin.Context) {
+	tasks, err := services.GetTasks(currentOrgID(c))
 	if err != nil {
 		utils.Respond(c, http.StatusInternalServerError, "error", "获取活动列表失败"+err.Error(), nil)
 		return
@@ -97,10 +107,10 @@ func GetTasks(c *gin.Context) {
 // @Produce json
 // @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
 // @Param id path string true "活动ID"
-// @Router /admin/delete_task/{id} [delete]
-func DeleteTask(c *gin.Context) {
+// @Router /task/delete_task [delete]
+func (tc *TaskController) DeleteTask(c *gin.Context) {
 	taskId := c.Query("TaskId")
-	err := services.DeleteTask(taskId)
+	err := tc.tasks.DeleteTask(currentOrgID(c), taskId)
 	if err != nil {
 		utils.Respond(c, http.StatusNotFound, "error", err.Error(), nil)
 		return
@@ -116,15 +126,15 @@ func DeleteTask(c *gin.Context) {
 // @Produce json
 // @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
 // @Param name body dto.TaskInfo true "任务信息"
-// @Router /admin/update [post]
-func UpdateTask(c *gin.Context) {
+// @Router /task/update [post]
+func (tc *TaskController) UpdateTask(c *gin.Context) {
 	var input dto.CreateTaskInfo
 	if err := c.ShouldBindJSON(&input); err != nil {
 		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
 		return
 	}
 
-	task, err := services.UpdateTask(input.Name, input.StartTime, input.StartTime, input.Location, input.Limit)
+	task, err := services.UpdateTask(currentOrgID(c), input.Name, input.StartTime, input.StartTime, input.Location, input.Category, input.Limit)
 	if err != nil {
 		utils.Respond(c, http.StatusInternalServerError, "error", "修改活动失败："+err.Error(), nil)
 		return
@@ -139,9 +149,9 @@ func UpdateTask(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
-// @Router /admin/getTaskDetails [get]
-func GetTaskDetails(c *gin.Context) {
-	taskDetails, err := services.GetTaskDetails()
+// @Router /task/getTaskDetails [get]
+func (tc *TaskController) GetTaskDetails(c *gin.Context) {
+	taskDetails, err := services.GetTaskDetails(currentOrgID(c))
 	if err != nil {
 		utils.Respond(c, http.StatusInternalServerError, "error", "获取详细活动列表失败"+err.Error(), nil)
 		return
@@ -158,7 +168,7 @@ func GetTaskDetails(c *gin.Context) {
 // @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
 // @Param taskID path int true "任务ID"
 // @Router /task/getStatus [get]
-func GetTaskStatus(c *gin.Context) {
+func (tc *TaskController) GetTaskStatus(c *gin.Context) {
 	// 从上下文中获取用户名
 	nickname, exists := c.Get("Nickname")
 	if !exists {
@@ -174,7 +184,7 @@ func GetTaskStatus(c *gin.Context) {
 		return
 	}
 	// 调用服务层的 GetTaskStatus 方法获取任务状态
-	taskStatus, err := services.GetTaskStatus(taskId, nickname.(string))
+	taskStatus, err := services.GetTaskStatus(currentOrgID(c), taskId, nickname.(string))
 	if err != nil {
 		// 如果获取任务状态失败，返回内部服务器错误
 		utils.Respond(c, http.StatusInternalServerError, "error", "获取当前用户任务状态失败："+err.Error(), nil)
@@ -193,14 +203,17 @@ func GetTaskStatus(c *gin.Context) {
 // @Produce json
 // @Param Authorization header dto.AuditRequest true "Bearer 用户令牌"
 // @Param taskId path int true "任务ID"
-// @Router /admin/GetTaskAuditDetail [post]
-func GetTaskAuditDetail(c *gin.Context) {
+// @Router /task/GetTaskAuditDetail [post]
+func (tc *TaskController) GetTaskAuditDetail(c *gin.Context) {
 	var input dto.AuditRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
 		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
 		return
 	}
-	taskDetails, err := services.GetTaskAuditDetail(input.TaskId)
+	if !authorizeTaskViewer(c, input.TaskId) {
+		return
+	}
+	taskDetails, err := services.GetTaskAuditDetail(currentOrgID(c), input.TaskId)
 	if err != nil {
 		utils.Respond(c, http.StatusInternalServerError, "error", "获取活动报名情况列表失败"+err.Error(), nil)
 		return
@@ -216,14 +229,17 @@ func GetTaskAuditDetail(c *gin.Context) {
 // @Produce json
 // @Param Authorization header dto.HandleVolunteerRequest true "Bearer 用户令牌"
 // @Param taskId path int true "任务ID"
-// @Router /admin/approveVolunteer [post]
-func ApproveVolunteer(c *gin.Context) {
+// @Router /task/approveVolunteer [post]
+func (tc *TaskController) ApproveVolunteer(c *gin.Context) {
 	var input dto.HandleVolunteerRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
 		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
 		return
 	}
-	err := services.ApproveVolunteer(input.TaskId, input.Email)
+	if !authorizeTaskManager(c, input.TaskId) {
+		return
+	}
+	err := tc.tasks.ApproveVolunteer(currentOrgID(c), input.TaskId, input.Email)
 	if err != nil {
 		utils.Respond(c, http.StatusInternalServerError, "error", "通过报名人审核失败"+err.Error(), nil)
 		return
@@ -239,17 +255,159 @@ func ApproveVolunteer(c *gin.Context) {
 // @Produce json
 // @Param Authorization header dto.HandleVolunteerRequest true "Bearer 用户令牌"
 // @Param taskId path int true "任务ID"
-// @Router /admin/rejectVolunteer [post]
-func RejectVolunteer(c *gin.Context) {
+// @Router /task/rejectVolunteer [post]
+func (tc *TaskController) RejectVolunteer(c *gin.Context) {
 	var input dto.HandleVolunteerRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
 		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
 		return
 	}
-	err := services.RejectVolunteer(input.TaskId, input.Email)
+	if !authorizeTaskManager(c, input.TaskId) {
+		return
+	}
+	err := tc.tasks.RejectVolunteer(currentOrgID(c), input.TaskId, input.Email)
 	if err != nil {
 		utils.Respond(c, http.StatusInternalServerError, "error", "拒绝报名人审核失败"+err.Error(), nil)
 		return
 	}
 	utils.Respond(c, http.StatusOK, "success", "拒绝报名人审核成功", nil)
 }
+
+// CheckInVolunteer 报名人签到
+// @Summary 报名人签到
+// @Description 管理员或该活动的协调员为审核通过的报名人签到
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.HandleVolunteerRequest true "签到信息"
+// @Router /task/checkInVolunteer [post]
+func (tc *TaskController) CheckInVolunteer(c *gin.Context) {
+	var input dto.HandleVolunteerRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if !authorizeTaskManager(c, input.TaskId) {
+		return
+	}
+	if err := services.CheckInVolunteer(currentOrgID(c), input.TaskId, input.Email); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "签到失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "签到成功", nil)
+}
+
+// ConfirmVolunteerHours 确认报名人志愿时长
+// @Summary 确认报名人志愿时长
+// @Description 管理员或该活动的协调员确认已签到报名人的志愿时长
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.ConfirmHoursRequest true "时长信息"
+// @Router /task/confirmHours [post]
+func (tc *TaskController) ConfirmVolunteerHours(c *gin.Context) {
+	var input dto.ConfirmHoursRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if !authorizeTaskManager(c, input.TaskId) {
+		return
+	}
+	if err := services.ConfirmVolunteerHours(currentOrgID(c), input.TaskId, input.Email, input.Minutes); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "确认志愿时长失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "确认志愿时长成功", nil)
+}
+
+// AssignCoordinator 分配活动协调员
+// @Summary 分配活动协调员
+// @Description 为指定活动分配协调员
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.CoordinatorRequest true "协调员信息"
+// @Router /task/assignCoordinator [post]
+func (tc *TaskController) AssignCoordinator(c *gin.Context) {
+	var input dto.CoordinatorRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if err := services.AssignCoordinator(currentOrgID(c), input.TaskId, input.Email); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "分配协调员失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "分配协调员成功", nil)
+}
+
+// RemoveCoordinator 移除活动协调员
+// @Summary 移除活动协调员
+// @Description 移除指定活动的协调员
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.CoordinatorRequest true "协调员信息"
+// @Router /task/removeCoordinator [post]
+func (tc *TaskController) RemoveCoordinator(c *gin.Context) {
+	var input dto.CoordinatorRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if err := services.RemoveCoordinator(currentOrgID(c), input.TaskId, input.Email); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "移除协调员失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "移除协调员成功", nil)
+}
+
+// GetTaskCoordinators 获取活动协调员列表
+// @Summary 获取活动协调员列表
+// @Description 获取指定活动的协调员列表
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param TaskId query int true "任务ID"
+// @Router /task/coordinators [get]
+func (tc *TaskController) GetTaskCoordinators(c *gin.Context) {
+	taskId, err := strconv.Atoi(c.Query("TaskId"))
+	if err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "任务ID格式错误，必须为有效的整数", nil)
+		return
+	}
+	coordinators, err := services.GetTaskCoordinators(currentOrgID(c), uint(taskId))
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "获取协调员列表失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取协调员列表成功", gin.H{"coordinators": coordinators})
+}
+
+// GetCoordinatorTasks 获取当前用户负责协调的活动
+// @Summary 获取负责协调的活动
+// @Description 获取当前用户作为协调员负责的活动列表
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Router /task/coordinated [get]
+func (tc *TaskController) GetCoordinatorTasks(c *gin.Context) {
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	tasks, err := services.GetCoordinatorTasks(currentOrgID(c), email.(string))
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "获取协调活动列表失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取协调活动列表成功", gin.H{"tasks": tasks})
+}
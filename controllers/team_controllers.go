@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/services"
+	"volunteer-system-backend/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// TeamController 团队控制器
+type TeamController struct {
+	teams *services.TeamService
+}
+
+// NewTeamController 创建新的团队控制器实例
+func NewTeamController(teams *services.TeamService) *TeamController {
+	return &TeamController{teams: teams}
+}
+
+// CreateTeam 创建团队
+// @Summary 创建团队
+// @Description 创建团队，创建者成为队长
+// @Tags team
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.CreateTeamRequest true "团队信息"
+// @Router /team/create [post]
+func (tc *TeamController) CreateTeam(c *gin.Context) {
+	var input dto.CreateTeamRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	team, err := services.CreateTeam(currentOrgID(c), email.(string), input.Name)
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "创建团队失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "创建团队成功", gin.H{"team": team})
+}
+
+// GetUserTeams 获取当前用户的团队
+// @Summary 获取当前用户的团队
+// @Description 获取当前用户在当前组织中加入或被邀请的团队
+// @Tags team
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Router /team/my [get]
+func (tc *TeamController) GetUserTeams(c *gin.Context) {
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	teams, err := services.GetUserTeams(currentOrgID(c), email.(string))
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "获取团队列表失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取团队列表成功", gin.H{"teams": teams})
+}
+
+// InviteTeamMember 邀请团队成员
+// @Summary 邀请团队成员
+// @Description 队长邀请组织内的用户加入团队
+// @Tags team
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.TeamInviteRequest true "邀请信息"
+// @Router /team/invite [post]
+func (tc *TeamController) InviteTeamMember(c *gin.Context) {
+	var input dto.TeamInviteRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	if err := tc.teams.InviteTeamMember(currentOrgID(c), email.(string), input.TeamId, input.Email); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "邀请团队成员失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "邀请团队成员成功", nil)
+}
+
+// RespondTeamInvitation 答复团队邀请
+// @Summary 答复团队邀请
+// @Description 接受或拒绝团队邀请
+// @Tags team
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.TeamInvitationReply true "答复信息"
+// @Router /team/respond [post]
+func (tc *TeamController) RespondTeamInvitation(c *gin.Context) {
+	var input dto.TeamInvitationReply
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	if err := services.RespondTeamInvitation(currentOrgID(c), email.(string), input.TeamId, input.Accept); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "答复团队邀请失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "答复团队邀请成功", nil)
+}
+
+// JoinTaskAsTeam 团队报名活动
+// @Summary 团队报名活动
+// @Description 队长为团队整体报名活动，按成员数占用名额
+// @Tags team
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.TeamTaskRequest true "报名信息"
+// @Router /team/join_task [post]
+func (tc *TeamController) JoinTaskAsTeam(c *gin.Context) {
+	var input dto.TeamTaskRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	err := tc.teams.JoinTaskAsTeam(currentOrgID(c), email.(string), input.TeamId, input.TaskId)
+	if err != nil {
+		if err.Error() == "活动不存在" {
+			utils.Respond(c, http.StatusNotFound, "error", err.Error(), nil)
+		} else if err.Error() == "活动剩余名额不足" {
+			utils.Respond(c, http.StatusForbidden, "error", err.Error(), nil)
+		} else {
+			utils.Respond(c, http.StatusInternalServerError, "error", "团队报名失败："+err.Error(), nil)
+		}
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "团队报名成功", nil)
+}
+
+// ApproveTeam 通过团队报名审核
+// @Summary 通过团队报名审核
+// @Description 整体通过团队报名，团队中每个成员的报名都被通过
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.TeamTaskRequest true "审核信息"
+// @Router /task/approveTeam [post]
+func (tc *TeamController) ApproveTeam(c *gin.Context) {
+	var input dto.TeamTaskRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if !authorizeTaskManager(c, input.TaskId) {
+		return
+	}
+	if err := tc.teams.ApproveTeam(currentOrgID(c), input.TeamId, input.TaskId); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "通过团队报名审核失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "通过团队报名审核成功", nil)
+}
+
+// RejectTeam 拒绝团队报名审核
+// @Summary 拒绝团队报名审核
+// @Description 整体拒绝团队报名并释放占用的名额
+// @Tags task
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.TeamTaskRequest true "审核信息"
+// @Router /task/rejectTeam [post]
+func (tc *TeamController) RejectTeam(c *gin.Context) {
+	var input dto.TeamTaskRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if !authorizeTaskManager(c, input.TaskId) {
+		return
+	}
+	if err := tc.teams.RejectTeam(currentOrgID(c), input.TeamId, input.TaskId); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "拒绝团队报名审核失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "拒绝团队报名审核成功", nil)
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/services"
+	"volunteer-system-backend/utils"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// twoFactorErrorStatus 根据两步验证服务返回的错误选择响应状态码
+func twoFactorErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidTwoFactorCode), errors.Is(err, services.ErrInvalidPreAuthToken):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrLoginThrottled):
+		return http.StatusTooManyRequests
+	case errors.Is(err, services.ErrAccountLocked):
+		return http.StatusLocked
+	}
+	return http.StatusBadRequest
+}
+
+// LoginTwoFactor 两步验证登录
+// @Summary 两步验证登录
+// @Description 使用登录接口返回的预认证令牌和身份验证器中的验证码或恢复码完成登录
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param request body dto.LoginTwoFactorRequest true "预认证令牌和验证码"
+// @Router /api/login_2fa [post]
+func LoginTwoFactor(c *gin.Context) {
+	var input dto.LoginTwoFactorRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	tokens, err := services.LoginTwoFactor(input.PreAuthToken, input.Code, c.ClientIP())
+	if err != nil {
+		utils.Respond(c, twoFactorErrorStatus(err), "error", "登录失败"+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "登录成功", tokens)
+}
+
+// SetupTwoFactor 获取两步验证密钥
+// @Summary 获取两步验证密钥
+// @Description 生成待确认的 TOTP 密钥和 otpauth URI，使用身份验证器扫描后调用开启接口确认
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Router /user/2fa/setup [post]
+func SetupTwoFactor(c *gin.Context) {
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	info, err := services.SetupTwoFactor(email.(string))
+	if err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "获取两步验证密钥失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取两步验证密钥成功", info)
+}
+
+// EnableTwoFactor 开启两步验证
+// @Summary 开启两步验证
+// @Description 提交身份验证器中的验证码确认密钥并开启两步验证，返回只显示一次的恢复码
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.TwoFactorCodeRequest true "验证码"
+// @Router /user/2fa/enable [post]
+func EnableTwoFactor(c *gin.Context) {
+	var input dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	codes, err := services.EnableTwoFactor(email.(string), input.Code)
+	if err != nil {
+		utils.Respond(c, twoFactorErrorStatus(err), "error", "开启两步验证失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "开启两步验证成功，请妥善保存恢复码", gin.H{"recoveryCodes": codes})
+}
+
+// DisableTwoFactor 关闭两步验证
+// @Summary 关闭两步验证
+// @Description 提交验证码或恢复码关闭两步验证，配置要求开启两步验证的管理员不能关闭
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.TwoFactorCodeRequest true "验证码或恢复码"
+// @Router /user/2fa/disable [post]
+func DisableTwoFactor(c *gin.Context) {
+	var input dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	if err := services.DisableTwoFactor(email.(string), input.Code); err != nil {
+		utils.Respond(c, twoFactorErrorStatus(err), "error", "关闭两步验证失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "关闭两步验证成功", nil)
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+// @Summary 重新生成恢复码
+// @Description 提交验证码后重新生成恢复码，之前的恢复码全部作废
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.TwoFactorCodeRequest true "验证码或恢复码"
+// @Router /user/2fa/recovery_codes [post]
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var input dto.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	codes, err := services.RegenerateRecoveryCodes(email.(string), input.Code)
+	if err != nil {
+		utils.Respond(c, twoFactorErrorStatus(err), "error", "生成恢复码失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "生成恢复码成功，请妥善保存恢复码", gin.H{"recoveryCodes": codes})
+}
+
+// ResetTwoFactor 重置用户两步验证
+// @Summary 重置用户两步验证
+// @Description 管理员关闭用户的两步验证并清除恢复码，用于用户丢失身份验证器和恢复码的情况，该用户之前的登录全部失效
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.ResetTwoFactorRequest true "用户邮箱"
+// @Router /admin/reset_2fa [post]
+func ResetTwoFactor(c *gin.Context) {
+	var input dto.ResetTwoFactorRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if err := services.ResetTwoFactor(input.Email); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "重置两步验证失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "重置两步验证成功", nil)
+}
@@ -2,21 +2,43 @@ package controllers
 
 import (
 	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
 	"volunteer-system-backend/services"
 	"volunteer-system-backend/utils"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
 
+// respondPasswordPolicy 密码不符合密码策略时返回400和未满足的规则列表
+func respondPasswordPolicy(c *gin.Context, err error) bool {
+	var policyErr *services.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+	utils.Respond(c, http.StatusBadRequest, "error", policyErr.Error(), gin.H{"violations": policyErr.Violations})
+	return true
+}
+
+// UserController 处理需要发送通知的用户接口
+type UserController struct {
+	users *services.UserService
+}
+
+// NewUserController 创建用户控制器
+func NewUserController(users *services.UserService) *UserController {
+	return &UserController{users: users}
+}
+
 // RegisterUser 注册新用户
 // @Summary 注册新用户
-// @Description 用户注册
+// @Description 用户注册，注册后需要点击验证邮件中的链接验证邮箱才能登录；密码不符合密码策略时返回未满足的规则列表
 // @Tags user
 // @Accept json
 // @Produce json
 // @Param request body dto.RegisterUserRequest true "注册信息"
 // @Router /api/register [post]
-func RegisterUser(c *gin.Context) {
+func (uc *UserController) RegisterUser(c *gin.Context) {
 	var input dto.RegisterUserRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
 		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
@@ -24,16 +46,19 @@ func RegisterUser(c *gin.Context) {
 	}
 
 	// 调用服务层
-	if err := services.RegisterUser(input.Email, input.Nickname, input.Gender, input.Phone, input.Password); err != nil {
+	if err := uc.users.RegisterUser(input.Email, input.Nickname, input.Gender, input.Phone, input.Password, input.OrgCode); err != nil {
+		if respondPasswordPolicy(c, err) {
+			return
+		}
 		utils.Respond(c, http.StatusInternalServerError, "error", "注册失败"+err.Error(), nil)
 		return
 	}
-	utils.Respond(c, http.StatusOK, "success", "注册成功", nil)
+	utils.Respond(c, http.StatusOK, "success", "注册成功，请查收验证邮件完成邮箱验证", nil)
 }
 
 // LoginUser 登录用户
 // @Summary 登录用户
-// @Description 用户登录并生成 token
+// @Description 用户登录并生成 token，返回上次登录成功后失败的登录尝试，连续登录失败后账户会被临时锁定；开启两步验证的用户返回预认证令牌
 // @Tags user
 // @Accept json
 // @Produce json
@@ -47,12 +72,181 @@ func LoginUser(c *gin.Context) {
 		return
 	}
 
-	token, err := services.LoginUser(input.Email, input.Password)
+	tokens, err := services.LoginUser(input.Email, input.Password, c.ClientIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrLoginThrottled):
+			utils.Respond(c, http.StatusTooManyRequests, "error", "登录失败"+err.Error(), nil)
+		case errors.Is(err, services.ErrAccountLocked):
+			utils.Respond(c, http.StatusLocked, "error", "登录失败"+err.Error(), nil)
+		default:
+			utils.Respond(c, http.StatusUnauthorized, "error", "登录失败"+err.Error(), nil)
+		}
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "登录成功", tokens)
+}
+
+// VerifyEmail 验证邮箱
+// @Summary 验证邮箱
+// @Description 使用验证邮件中的签名令牌完成邮箱验证，验证后才能登录
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param request body dto.VerifyEmailRequest true "验证令牌"
+// @Router /api/verify_email [post]
+func VerifyEmail(c *gin.Context) {
+	var input dto.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if err := services.VerifyEmail(input.Token); err != nil {
+		if errors.Is(err, services.ErrInvalidVerifyToken) {
+			utils.Respond(c, http.StatusBadRequest, "error", err.Error(), nil)
+			return
+		}
+		utils.Respond(c, http.StatusInternalServerError, "error", "验证邮箱失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "邮箱验证成功", nil)
+}
+
+// ResendVerification 重新发送验证邮件
+// @Summary 重新发送验证邮件
+// @Description 向尚未验证的注册邮箱重新发送验证邮件，每个邮箱每分钟最多发送一次，无论邮箱是否注册都返回相同的结果
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param request body dto.ResendVerificationRequest true "注册邮箱"
+// @Router /api/resend_verification [post]
+func ResendVerification(c *gin.Context) {
+	var input dto.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if err := services.ResendVerificationEmail(input.Email); err != nil {
+		if errors.Is(err, services.ErrRateLimited) {
+			utils.Respond(c, http.StatusTooManyRequests, "error", err.Error(), nil)
+			return
+		}
+		utils.Respond(c, http.StatusInternalServerError, "error", "发送验证邮件失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "如果该邮箱已注册且尚未验证，验证邮件已发送到该邮箱", nil)
+}
+
+// ForgotPassword 找回密码
+// @Summary 找回密码
+// @Description 向已注册的邮箱发送一次性的重置密码链接，无论邮箱是否注册都返回相同的结果
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param request body dto.ForgotPasswordRequest true "注册邮箱"
+// @Router /api/forgot_password [post]
+func ForgotPassword(c *gin.Context) {
+	var input dto.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if err := services.RequestPasswordReset(input.Email, c.ClientIP()); err != nil {
+		if errors.Is(err, services.ErrRateLimited) {
+			utils.Respond(c, http.StatusTooManyRequests, "error", err.Error(), nil)
+			return
+		}
+		utils.Respond(c, http.StatusInternalServerError, "error", "申请重置密码失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "如果该邮箱已注册，重置密码的链接已发送到该邮箱", nil)
+}
+
+// ResetPassword 重置密码
+// @Summary 重置密码
+// @Description 使用邮件中的一次性令牌设置新密码，重置后之前的登录全部失效
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param request body dto.ResetPasswordRequest true "重置令牌和新密码"
+// @Router /api/reset_password [post]
+func ResetPassword(c *gin.Context) {
+	var input dto.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if err := services.ResetPassword(input.Token, input.NewPassword); err != nil {
+		if respondPasswordPolicy(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			utils.Respond(c, http.StatusBadRequest, "error", err.Error(), nil)
+			return
+		}
+		utils.Respond(c, http.StatusInternalServerError, "error", "重置密码失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "重置密码成功，请使用新密码登录", nil)
+}
+
+// RefreshToken 刷新令牌
+// @Summary 刷新令牌
+// @Description 使用刷新令牌换取新的访问令牌和刷新令牌，旧的刷新令牌随即失效，重复使用已失效的刷新令牌会使该登录失效
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param request body dto.RefreshTokenRequest true "刷新令牌"
+// @Router /api/refresh [post]
+func RefreshToken(c *gin.Context) {
+	var input dto.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	tokens, err := services.RefreshTokens(input.RefreshToken)
 	if err != nil {
-		utils.Respond(c, http.StatusUnauthorized, "error", "登录失败"+err.Error(), nil)
+		utils.Respond(c, http.StatusUnauthorized, "error", "刷新令牌失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "刷新令牌成功", tokens)
+}
+
+// Logout 退出登录
+// @Summary 退出登录
+// @Description 退出当前设备的登录，当前访问令牌和对应的刷新令牌立即失效
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Router /user/logout [post]
+func Logout(c *gin.Context) {
+	if err := services.Logout(c.GetString("JTI"), c.GetTime("TokenExpiresAt")); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "退出登录失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "退出登录成功", nil)
+}
+
+// LogoutAll 退出所有设备
+// @Summary 退出所有设备
+// @Description 退出当前用户在所有设备上的登录
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Router /user/logout_all [post]
+func LogoutAll(c *gin.Context) {
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	if err := services.LogoutAll(email.(string)); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "退出登录失败："+err.Error(), nil)
 		return
 	}
-	utils.Respond(c, http.StatusOK, "success", "登录成功", gin.H{"token": token})
+	utils.Respond(c, http.StatusOK, "success", "已退出所有设备", nil)
 }
 
 // GetUserProfile 获取用户信息
@@ -82,6 +276,17 @@ func GetUserProfile(c *gin.Context) {
 
 	// 判断用户状态
 	status := utils.GetUserStatus(user.LastLoginTime)
+	role, permissions, err := services.GetUserPermissions(user.Email, currentOrgID(c))
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", err.Error(), nil)
+		return
+	}
+	isAdmin := false
+	for _, p := range permissions {
+		if p == models.PermTaskManage {
+			isAdmin = true
+		}
+	}
 
 	utils.Respond(c, http.StatusOK, "success", "获取成功", gin.H{
 		"email":        user.Email,
@@ -89,9 +294,13 @@ func GetUserProfile(c *gin.Context) {
 		"gender":       user.Gender,
 		"avatar":       user.Avatar,
 		"volunteerID":  user.ID,
+		"orgId":        currentOrgID(c),
 		"phone":        user.Phone,
 		"duration":     user.Duration,
-		"isAdmin":      user.Admin,
+		"skills":       user.Skills,
+		"isAdmin":      isAdmin,
+		"role":         role,
+		"permissions":  permissions,
 		"status":       status,
 		"lastActivity": user.LastLoginTime,
 	})
@@ -99,14 +308,14 @@ func GetUserProfile(c *gin.Context) {
 
 // ChangePassword 修改密码
 // @Summary 修改密码
-// @Description 修改用户密码
+// @Description 修改用户密码，新密码需要符合密码策略，之前签发的令牌全部失效，返回当前设备使用的新令牌
 // @Tags user
 // @Accept json
 // @Produce json
 // @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
 // @Param request body dto.ChangePasswordRequest true "修改密码信息"
 // @Router /user/change_password [put]
-func ChangePassword(c *gin.Context) {
+func (uc *UserController) ChangePassword(c *gin.Context) {
 	var input dto.ChangePasswordRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
 		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
@@ -119,17 +328,16 @@ func ChangePassword(c *gin.Context) {
 		return
 	}
 
-	if email == "admin@admin.com" {
-		utils.Respond(c, http.StatusForbidden, "error", "不允许修改默认管理员用户密码", nil)
-		return
-	}
-
-	if err := services.ChangePassword(email.(string), input.OldPassword, input.NewPassword); err != nil {
+	tokens, err := uc.users.ChangePassword(email.(string), currentOrgID(c), input.OldPassword, input.NewPassword)
+	if err != nil {
+		if respondPasswordPolicy(c, err) {
+			return
+		}
 		utils.Respond(c, http.StatusInternalServerError, "error", "修改密码失败："+err.Error(), nil)
 		return
 	}
 
-	utils.Respond(c, http.StatusOK, "success", "修改密码成功", nil)
+	utils.Respond(c, http.StatusOK, "success", "修改密码成功，其他设备上的登录已失效", tokens)
 }
 
 // GetVolunteerCount 统计志愿者用户个数
@@ -141,7 +349,7 @@ func ChangePassword(c *gin.Context) {
 // @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
 // @Router /user/volunteer_count [get]
 func GetVolunteerCount(c *gin.Context) {
-	volunteers, err := services.GetVolunteerCount()
+	volunteers, err := services.GetVolunteerCount(currentOrgID(c))
 	if err != nil {
 		utils.Respond(c, http.StatusInternalServerError, "error", "获取志愿者信息失败："+err.Error(), nil)
 		return
@@ -203,10 +411,142 @@ func UpdateUserInfo(c *gin.Context) {
 		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
 		return
 	}
-	if err := services.UpdateUserInfo(email, input.Nickname, input.Gender, input.Phone); err != nil {
+	if err := services.UpdateUserInfo(email, input.Nickname, input.Gender, input.Phone, input.Skills); err != nil {
 		utils.Respond(c, http.StatusInternalServerError, "error", "更新用户信息失败："+err.Error(), nil)
 		return
 	}
 
 	utils.Respond(c, http.StatusOK, "success", "更新用户信息成功", nil)
 }
+
+// GetRoles 获取角色列表
+// @Summary 获取角色列表
+// @Description 获取所有角色及其权限
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Router /admin/roles [get]
+func GetRoles(c *gin.Context) {
+	roles, err := services.GetRoles()
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "获取角色列表失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取角色列表成功", gin.H{"roles": roles})
+}
+
+// AssignRole 分配用户角色
+// @Summary 分配用户角色
+// @Description 为指定用户分配角色
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.AssignRoleRequest true "角色信息"
+// @Router /admin/assign_role [post]
+func AssignRole(c *gin.Context) {
+	var input dto.AssignRoleRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if err := services.AssignRole(input.Email, input.Role); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "分配角色失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "分配角色成功", nil)
+}
+
+// AdminVerifyEmail 验证用户邮箱
+// @Summary 验证用户邮箱
+// @Description 管理员直接将用户的邮箱标记为已验证，用于用户无法收到验证邮件的情况
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.AdminVerifyEmailRequest true "用户邮箱"
+// @Router /admin/verify_email [post]
+func AdminVerifyEmail(c *gin.Context) {
+	var input dto.AdminVerifyEmailRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if err := services.AdminVerifyEmail(input.Email); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "验证邮箱失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "验证邮箱成功", nil)
+}
+
+// UnlockUser 解除账户登录锁定
+// @Summary 解除账户登录锁定
+// @Description 管理员解除用户因连续登录失败被临时锁定的状态
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.UnlockUserRequest true "用户邮箱"
+// @Router /admin/unlock_user [post]
+func UnlockUser(c *gin.Context) {
+	var input dto.UnlockUserRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	if err := services.UnlockUser(input.Email); err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "解除锁定失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "解除锁定成功", nil)
+}
+
+// GetNotificationPreferences 获取通知偏好
+// @Summary 获取通知偏好
+// @Description 获取当前用户的免打扰时段、每日摘要设置以及各通知渠道中每类消息的订阅状态
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Router /user/notification_preferences [get]
+func GetNotificationPreferences(c *gin.Context) {
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	preferences, err := services.GetNotificationPreferences(email.(string))
+	if err != nil {
+		utils.Respond(c, http.StatusInternalServerError, "error", "获取通知偏好失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "获取通知偏好成功", preferences)
+}
+
+// UpdateNotificationPreferences 更新通知偏好
+// @Summary 更新通知偏好
+// @Description 更新当前用户的时区、免打扰时段和每日摘要设置，只更新提交的字段，channels 中提交的渠道订阅设置会覆盖原有设置
+// @Tags user
+// @Accept json
+// @Produce json
+// @Param Authorization header dto.UserProfileRequest true "Bearer 用户令牌"
+// @Param request body dto.NotificationPreferencesUpdate true "通知偏好"
+// @Router /user/notification_preferences [put]
+func UpdateNotificationPreferences(c *gin.Context) {
+	var input dto.NotificationPreferencesUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "请求参数错误："+err.Error(), nil)
+		return
+	}
+	email, exists := c.Get("Email")
+	if !exists {
+		utils.Respond(c, http.StatusUnauthorized, "error", "用户未登录", nil)
+		return
+	}
+	if err := services.UpdateNotificationPreferences(email.(string), input); err != nil {
+		utils.Respond(c, http.StatusBadRequest, "error", "更新通知偏好失败："+err.Error(), nil)
+		return
+	}
+	utils.Respond(c, http.StatusOK, "success", "更新通知偏好成功", nil)
+}
@@ -0,0 +1,25 @@
+package dto
+
+// CreateAnnouncementRequest 创建公告请求
+type CreateAnnouncementRequest struct {
+	Title         string `json:"title" binding:"required"`
+	Content       string `json:"content" binding:"required"`
+	AudienceType  string `json:"audienceType" binding:"required" example:"all"` // all、task、skill 或 category
+	AudienceValue string `json:"audienceValue"`                                 // 受众为 task 时为活动ID，为 skill 时为技能标签，为 category 时为活动类别
+	SendAt        string `json:"sendAt" example:"2025-01-01 08:00:00"`          // 为空时立即发送
+	ExpiresAt     string `json:"expiresAt" example:"2025-01-08 08:00:00"`       // 为空时永不过期
+}
+
+// AnnouncementInfo 公告信息及阅读情况
+type AnnouncementInfo struct {
+	ID            uint   `json:"id"`
+	Title         string `json:"title"`
+	Content       string `json:"content"`
+	AudienceType  string `json:"audienceType"`
+	AudienceValue string `json:"audienceValue"`
+	SendAt        string `json:"sendAt"`
+	ExpiresAt     string `json:"expiresAt"`
+	SentAt        string `json:"sentAt"`
+	Recipients    uint   `json:"recipients"`
+	ReadCount     int64  `json:"readCount"`
+}
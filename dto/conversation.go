@@ -0,0 +1,62 @@
+package dto
+
+// StartConversationRequest 发起私信会话请求
+type StartConversationRequest struct {
+	Recipients []string `json:"recipients" binding:"required,min=1,max=20"` // 接收者邮箱，接收者之间也需要能够互相发送私信
+	TaskId     uint     `json:"taskId"`                                     // 会话相关的活动ID
+	Subject    string   `json:"subject"`
+	Content    string   `json:"content" binding:"required"`
+}
+
+// SendDirectMessageRequest 在会话中发送私信请求
+type SendDirectMessageRequest struct {
+	ConversationID uint   `json:"conversationId" binding:"required"`
+	Content        string `json:"content" binding:"required"`
+}
+
+// ConversationReadRequest 标记会话已读请求
+type ConversationReadRequest struct {
+	ConversationID uint `json:"conversationId" binding:"required"`
+}
+
+// DirectMessageModerationRequest 屏蔽私信请求，Value 为 true 时屏蔽
+type DirectMessageModerationRequest struct {
+	MessageID uint `json:"messageId" binding:"required"`
+	Value     bool `json:"value"`
+}
+
+// ConversationMember 会话参与者信息
+type ConversationMember struct {
+	ID       uint   `json:"id"`
+	Email    string `json:"email"`
+	Nickname string `json:"nickname"`
+	Avatar   string `json:"avatar"`
+}
+
+// ConversationInfo 会话信息
+type ConversationInfo struct {
+	ID            uint                 `json:"id"`
+	TaskID        uint                 `json:"taskId"`
+	Subject       string               `json:"subject"`
+	Members       []ConversationMember `json:"members"`
+	LastMessage   string               `json:"lastMessage"`
+	LastMessageAt string               `json:"lastMessageAt"`
+	Unread        int64                `json:"unread"`
+}
+
+// DirectMessageInfo 私信信息
+type DirectMessageInfo struct {
+	ID       uint   `json:"id"`
+	SenderID uint   `json:"senderId"`
+	Nickname string `json:"nickname"`
+	Content  string `json:"content"`
+	Hidden   bool   `json:"hidden,omitempty"`
+	Time     string `json:"time"`
+}
+
+// DirectMessageQuery 私信列表查询条件，BeforeID 不为空时获取该私信之前的历史私信
+type DirectMessageQuery struct {
+	ConversationID uint `form:"conversationId" binding:"required"`
+	BeforeID       uint `form:"beforeId"`
+	Limit          int  `form:"limit"`
+}
@@ -2,9 +2,57 @@ package dto
 
 // MessageResponse 消息响应结构体
 type MessageResponse struct {
-	ID      uint   `json:"id"`
+	ID       uint   `json:"id"`
+	Category string `json:"category"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Time     string `json:"time"`
+	Status   string `json:"status"`
+}
+
+// MessageQuery 消息列表查询条件
+type MessageQuery struct {
+	Page     int    `form:"page"`
+	PageSize int    `form:"pageSize"`
+	Status   string `form:"status"`   // unread 或 read，为空时不过滤
+	Category string `form:"category"` // 消息分类，为空时不过滤
+}
+
+// MessageListResponse 消息列表响应结构体
+type MessageListResponse struct {
+	Total    int64             `json:"total"`
+	Page     int               `json:"page"`
+	PageSize int               `json:"pageSize"`
+	Messages []MessageResponse `json:"messages"`
+}
+
+// MessageTemplateInfo 消息模板信息
+type MessageTemplateInfo struct {
+	EventType string `json:"eventType"`
+	Locale    string `json:"locale"`
+	Category  string `json:"category"`
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// UpdateMessageTemplateRequest 修改消息模板请求，标题和内容使用 Go text/template 语法
+type UpdateMessageTemplateRequest struct {
+	EventType string `json:"eventType" binding:"required" example:"volunteer.approved"`
+	Locale    string `json:"locale" binding:"required" example:"zh-CN"`
+	Title     string `json:"title" binding:"required" example:"申请通过通知"`
+	Content   string `json:"content" binding:"required" example:"您的申请已被通过，活动名称: \"{{.TaskName}}\""`
+}
+
+// PreviewMessageTemplateRequest 预览消息模板请求，Data 为空时使用示例数据
+type PreviewMessageTemplateRequest struct {
+	Title   string            `json:"title" binding:"required"`
+	Content string            `json:"content" binding:"required"`
+	Data    map[string]string `json:"data"`
+}
+
+// MessagePreview 消息模板的预览结果
+type MessagePreview struct {
 	Title   string `json:"title"`
 	Content string `json:"content"`
-	Time    string `json:"time"`
-	Status  string `json:"status"`
 }
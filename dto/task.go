@@ -10,6 +10,7 @@ type TaskInfo struct {
 	StartTime string `json:"start_time" binding:"required"`
 	EndTime   string `json:"end_time" binding:"required"`
 	Location  string `json:"location" binding:"required"`
+	Category  string `json:"category"` // 活动类别
 	Limit     uint   `json:"limit" binding:"required"`
 	Joined    uint   `json:"joined" binding:"required"`
 }
@@ -20,6 +21,7 @@ type CreateTaskInfo struct {
 	StartTime string `json:"startTime" binding:"required"`
 	EndTime   string `json:"endTime" binging:"required"`
 	Location  string `json:"location" binging:"required"`
+	Category  string `json:"category"` // 活动类别，可以按类别向参加过该类活动的成员发布公告
 	Limit     uint   `json:"limit" binging:"required"`
 }
 
@@ -85,11 +87,59 @@ type JoinTaskVolunteer struct {
 }
 
 type AuditResponse struct {
-	TaskId     uint                `json:"taskId" binding:"required"`
-	Volunteers []JoinTaskVolunteer `json:"volunteers"`
+	TaskId     uint                   `json:"taskId" binding:"required"`
+	Volunteers []JoinTaskVolunteer    `json:"volunteers"`
+	Teams      []TeamRegistrationInfo `json:"teams"`
 }
 
 type HandleVolunteerRequest struct {
 	TaskId uint   `json:"taskId" binding:"required"`
 	Email  string `json:"email" binding:"required"`
 }
+
+// CoordinatorRequest 协调员分配请求
+type CoordinatorRequest struct {
+	TaskId uint   `json:"taskId" binding:"required"`
+	Email  string `json:"email" binding:"required"`
+}
+
+// CoordinatorInfo 协调员信息
+type CoordinatorInfo struct {
+	ID       uint   `json:"id"`
+	Email    string `json:"email"`
+	Nickname string `json:"nickname"`
+}
+
+// ConfirmHoursRequest 志愿时长确认请求，Minutes 为空时按活动时长计算
+type ConfirmHoursRequest struct {
+	TaskId  uint   `json:"taskId" binding:"required"`
+	Email   string `json:"email" binding:"required"`
+	Minutes uint   `json:"minutes"`
+}
+
+// TaskCommentRequest 发布讨论请求，ParentID 为空时发布新的提问
+type TaskCommentRequest struct {
+	TaskId   uint   `json:"taskId" binding:"required"`
+	ParentID uint   `json:"parentId"`
+	Content  string `json:"content" binding:"required"`
+}
+
+// CommentModerationRequest 讨论管理请求，Value 表示置顶或隐藏的目标状态
+type CommentModerationRequest struct {
+	CommentID uint `json:"commentId" binding:"required"`
+	Value     bool `json:"value"`
+}
+
+// TaskCommentInfo 讨论信息，顶层提问包含其下的回复
+type TaskCommentInfo struct {
+	ID        uint              `json:"id"`
+	ParentID  uint              `json:"parentId"`
+	UserID    uint              `json:"userId"`
+	Nickname  string            `json:"nickname"`
+	Avatar    string            `json:"avatar"`
+	Content   string            `json:"content"`
+	Pinned    bool              `json:"pinned"`
+	Hidden    bool              `json:"hidden"`
+	CreatedAt string            `json:"createdAt"`
+	Replies   []TaskCommentInfo `json:"replies,omitempty"`
+}
@@ -0,0 +1,49 @@
+package dto
+
+// TeamInfo 团队信息
+type TeamInfo struct {
+	ID       uint             `json:"id"`
+	Name     string           `json:"name"`
+	LeaderID uint             `json:"leaderId"`
+	Status   uint             `json:"status"` // 当前用户在团队中的状态，0表示已邀请 1表示已加入
+	Members  []TeamMemberInfo `json:"members,omitempty"`
+}
+
+// TeamMemberInfo 团队成员信息
+type TeamMemberInfo struct {
+	ID       uint   `json:"id"`
+	Email    string `json:"email"`
+	Nickname string `json:"nickname"`
+	Status   uint   `json:"status"` // 0表示已邀请 1表示已加入
+}
+
+// TeamRegistrationInfo 团队报名信息
+type TeamRegistrationInfo struct {
+	TeamID   uint   `json:"teamId"`
+	TeamName string `json:"teamName"`
+	Slots    uint   `json:"slots"`
+	Status   uint   `json:"status"` // 0表示待审核 1表示审核通过 2表示审核不通过
+}
+
+// CreateTeamRequest 创建团队请求
+type CreateTeamRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// TeamInviteRequest 邀请团队成员请求
+type TeamInviteRequest struct {
+	TeamId uint   `json:"teamId" binding:"required"`
+	Email  string `json:"email" binding:"required"`
+}
+
+// TeamInvitationReply 团队邀请答复请求
+type TeamInvitationReply struct {
+	TeamId uint `json:"teamId" binding:"required"`
+	Accept bool `json:"accept"`
+}
+
+// TeamTaskRequest 团队报名和团队审核请求
+type TeamTaskRequest struct {
+	TeamId uint `json:"teamId" binding:"required"`
+	TaskId uint `json:"taskId" binding:"required"`
+}
@@ -2,11 +2,12 @@ package dto
 
 // RegisterUserRequest 用户注册请求
 type RegisterUserRequest struct {
-	Email    string `json:"email" binding:"required" example:"123@qq.com"`
+	Email    string `json:"email" binding:"required,email" example:"123@qq.com"`
 	Nickname string `json:"nickname" binding:"required" example:"user01"`
 	Gender   string `json:"gender" binding:"required" example:"男"`
 	Phone    string `json:"phone" binding:"required" example:"13812345678"`
 	Password string `json:"password" binding:"required" example:"123456"`
+	OrgCode  string `json:"orgCode" example:"default"`
 }
 
 // LoginUserRequest 用户登录请求
@@ -15,6 +16,50 @@ type LoginUserRequest struct {
 	Password string `json:"password" binding:"required" example:"123456"`
 }
 
+// ForgotPasswordRequest 找回密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email" example:"123@qq.com"`
+}
+
+// VerifyEmailRequest 验证邮箱请求
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationRequest 重新发送验证邮件请求
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email" example:"123@qq.com"`
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+// RefreshTokenRequest 刷新令牌请求
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// TokenPair 登录和刷新令牌后返回的令牌
+type TokenPair struct {
+	Token        string `json:"token"`        // 访问令牌
+	RefreshToken string `json:"refreshToken"` // 刷新令牌，每次刷新后旧的刷新令牌失效
+	ExpiresAt    string `json:"expiresAt"`    // 访问令牌过期时间
+
+	TwoFactorRequired  bool              `json:"twoFactorRequired,omitempty"`  // 为 true 时需要使用 preAuthToken 提交两步验证码完成登录
+	PreAuthToken       string            `json:"preAuthToken,omitempty"`       // 预认证令牌，只能用于提交两步验证码
+	MustChangePassword bool              `json:"mustChangePassword,omitempty"` // 为 true 时需要先修改初始密码才能使用其他接口
+	FailedLogins       []FailedLoginInfo `json:"failedLogins,omitempty"`       // 上次登录成功后失败的登录尝试，仅登录时返回
+}
+
+// FailedLoginInfo 失败的登录尝试
+type FailedLoginInfo struct {
+	IP   string `json:"ip"`
+	Time string `json:"time"`
+}
+
 // UserProfileRequest 用户信息请求
 type UserProfileRequest struct {
 	Authorization string `json:"authorization" binding:"required" example:"Bearer eyJhbGciOiJIsInR5cCI6IkpXJ9..."`
@@ -37,4 +82,137 @@ type UpdateUserInfoRequest struct {
 	Nickname string `json:"nickname"`
 	Gender   string `json:"gender"`
 	Phone    string `json:"phone"`
+	Skills   string `json:"skills"` // 技能标签，多个标签以逗号分隔
+}
+
+// RoleInfo 角色信息
+type RoleInfo struct {
+	Name        string   `json:"name"`
+	DisplayName string   `json:"displayName"`
+	Permissions []string `json:"permissions"`
+}
+
+// AdminVerifyEmailRequest 管理员直接验证用户邮箱请求
+type AdminVerifyEmailRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// LoginTwoFactorRequest 两步验证登录请求，code 为身份验证器中的验证码或恢复码
+type LoginTwoFactorRequest struct {
+	PreAuthToken string `json:"preAuthToken" binding:"required"`
+	Code         string `json:"code" binding:"required" example:"123456"`
+}
+
+// TwoFactorCodeRequest 两步验证码请求
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+// TwoFactorSetupInfo 设置两步验证时返回的密钥
+type TwoFactorSetupInfo struct {
+	Secret     string `json:"secret"`     // Base32 编码的密钥，可以手动输入身份验证器
+	OtpauthURI string `json:"otpauthUri"` // otpauth URI，前端渲染为二维码供身份验证器扫描
+}
+
+// OIDCAuthorizeInfo 发起统一身份认证登录时返回的授权地址
+type OIDCAuthorizeInfo struct {
+	Name  string `json:"name"`  // 身份提供方名称
+	URL   string `json:"url"`   // 身份提供方的授权地址，前端跳转到该地址
+	State string `json:"state"` // 前端保存，回调时核对地址中的 state 与其一致
+}
+
+// OIDCCallbackRequest 统一身份认证回调请求，code 和 state 取自身份提供方跳转回前端时的地址参数
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// ResetTwoFactorRequest 管理员重置用户两步验证请求
+type ResetTwoFactorRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// UnlockUserRequest 解除账户登录锁定请求
+type UnlockUserRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// AssignRoleRequest 用户角色分配请求
+type AssignRoleRequest struct {
+	Email string `json:"email" binding:"required"`
+	Role  string `json:"role" binding:"required"`
+}
+
+// OrganizationInfo 组织信息
+type OrganizationInfo struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Code string `json:"code,omitempty"`
+	Role string `json:"role,omitempty"`
+}
+
+// CreateOrganizationRequest 创建组织请求
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// SwitchOrganizationRequest 切换组织请求
+type SwitchOrganizationRequest struct {
+	OrganizationID uint `json:"organizationId" binding:"required"`
+}
+
+// JoinOrganizationRequest 加入组织请求
+type JoinOrganizationRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// OrganizationInvitationInfo 待处理的组织邀请
+type OrganizationInvitationInfo struct {
+	ID               uint   `json:"id"`
+	OrganizationID   uint   `json:"organizationId"`
+	OrganizationName string `json:"organizationName"`
+	Role             string `json:"role"`
+	InvitedBy        string `json:"invitedBy"` // 邀请人姓名
+	CreatedAt        string `json:"createdAt"`
+}
+
+// OrganizationInvitationReply 组织邀请答复请求
+type OrganizationInvitationReply struct {
+	InvitationId uint `json:"invitationId" binding:"required"`
+	Accept       bool `json:"accept"`
+}
+
+// OrganizationMemberRequest 组织成员管理请求
+type OrganizationMemberRequest struct {
+	Email string `json:"email" binding:"required"`
+	Role  string `json:"role"`
+}
+
+// ChannelPreference 某个通知渠道中某类消息的订阅设置
+type ChannelPreference struct {
+	Channel  string `json:"channel" binding:"required" example:"email"`
+	Category string `json:"category" binding:"required" example:"announcement"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// NotificationPreferences 用户通知偏好设置
+type NotificationPreferences struct {
+	Timezone        string              `json:"timezone" example:"Asia/Shanghai"`
+	QuietHoursStart string              `json:"quietHoursStart" example:"22:00"` // 免打扰开始时间，为空表示不开启
+	QuietHoursEnd   string              `json:"quietHoursEnd" example:"07:00"`   // 免打扰结束时间
+	DailyDigest     bool                `json:"dailyDigest"`                     // 将非紧急通知合并为每日摘要
+	DigestHour      uint                `json:"digestHour" example:"8"`          // 每日摘要的发送时间（小时）
+	Locale          string              `json:"locale" example:"zh-CN"`          // 接收通知使用的语言，为空时保持不变
+	Channels        []ChannelPreference `json:"channels"`
+}
+
+// NotificationPreferencesUpdate 更新通知偏好的请求，只更新提交的字段，未提交的字段保持不变
+type NotificationPreferencesUpdate struct {
+	Timezone        *string             `json:"timezone" example:"Asia/Shanghai"`
+	QuietHoursStart *string             `json:"quietHoursStart" example:"22:00"` // 免打扰开始时间，需要与结束时间一起提交，都为空字符串表示关闭
+	QuietHoursEnd   *string             `json:"quietHoursEnd" example:"07:00"`   // 免打扰结束时间
+	DailyDigest     *bool               `json:"dailyDigest"`                     // 将非紧急通知合并为每日摘要
+	DigestHour      *uint               `json:"digestHour" example:"8"`          // 每日摘要的发送时间（小时）
+	Locale          *string             `json:"locale" example:"zh-CN"`          // 接收通知使用的语言
+	Channels        []ChannelPreference `json:"channels"`                        // 提交的渠道订阅设置覆盖原有设置，未提交的保持不变
 }
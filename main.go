@@ -6,6 +6,13 @@ import (
 	"volunteer-system-backend/config"
 	"volunteer-system-backend/models"
 	"volunteer-system-backend/routes"
+	"volunteer-system-backend/services"
+	"volunteer-system-backend/utils"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
 )
 
 // @title 志愿者系统
@@ -20,13 +27,62 @@ import (
 
 // @host localhost:8080
 func main() {
+	bootstrapAdmin := flag.String("bootstrap-admin", "", "创建初始超级管理员的邮箱，密码从环境变量 ADMIN_PASSWORD 读取，未设置时生成一次性随机密码")
+	adminNickname := flag.String("admin-nickname", "管理员", "初始超级管理员的姓名")
+	flag.Parse()
+
 	//加载配置
 	config.LoadConfig()
+	//加载访问令牌签名密钥
+	if err := utils.LoadJWTKeys(); err != nil {
+		log.Fatal("加载JWT密钥失败:", err)
+	}
+	//加载两步验证密钥的加密密钥
+	if err := utils.LoadTOTPKey(); err != nil {
+		log.Fatal("加载两步验证加密密钥失败:", err)
+	}
 	//初始化数据库
 	models.InitDB()
+	//加密以明文保存的两步验证密钥
+	if err := services.EncryptTOTPSecrets(); err != nil {
+		log.Fatal("加密两步验证密钥失败:", err)
+	}
+	//创建初始超级管理员后退出
+	if *bootstrapAdmin != "" {
+		runBootstrapAdmin(*bootstrapAdmin, *adminNickname)
+		return
+	}
+	if ok, err := services.HasSuperAdmin(); err == nil && !ok {
+		log.Println("系统中还没有可用的超级管理员，请使用 -bootstrap-admin 参数创建，已使用初始密码创建的管理员需要登录后修改密码")
+	}
+	//初始化通知服务，注入到各个需要发送消息的服务中
+	notifications := services.NewNotificationService(models.DB, services.DefaultHub)
+	//启动定时公告发送
+	services.StartAnnouncementDispatcher(time.Minute, notifications)
+	//启动活动提醒
+	services.StartReminderScheduler(time.Minute, notifications)
+	//启动邮件发送
+	services.StartEmailOutboxWorker(time.Minute, utils.NewMailer())
+	//启动过期令牌清理
+	services.StartTokenCleaner(time.Hour)
+	//启动未验证邮箱账户清理
+	services.StartUnverifiedUserCleaner(time.Hour)
 	//初始化路由
-	router := routes.SetupRouter()
-	routes.MessageRoutes(router)
+	router := routes.SetupRouter(notifications)
+	routes.MessageRoutes(router, notifications)
 
 	router.Run(":8080")
 }
+
+// runBootstrapAdmin 创建初始超级管理员，生成的一次性密码只在此处输出一次
+func runBootstrapAdmin(email, nickname string) {
+	generated, err := services.BootstrapAdmin(email, nickname, os.Getenv("ADMIN_PASSWORD"))
+	if err != nil {
+		log.Fatal("创建超级管理员失败: ", err)
+	}
+	fmt.Println("已创建超级管理员:", email)
+	if generated != "" {
+		fmt.Println("一次性密码:", generated)
+		fmt.Println("该密码只显示一次，登录后需要先修改密码")
+	}
+}
@@ -1,13 +1,55 @@
 package middlewares
 
 import (
-	"volunteer-system-backend/models"
+	"volunteer-system-backend/services"
 	"volunteer-system-backend/utils"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strings"
 )
 
+// StreamAuthMiddleware 消息推送接口的鉴权，EventSource 无法设置请求头，浏览器通过 ticket 查询参数传递一次性推送凭证，
+// 访问令牌不会出现在地址中；能够设置请求头的客户端仍然可以使用 Authorization 请求头
+func StreamAuthMiddleware() gin.HandlerFunc {
+	auth := AuthMiddleware()
+	return func(c *gin.Context) {
+		ticket := c.Query("ticket")
+		if ticket == "" {
+			auth(c)
+			return
+		}
+		user, record, err := services.RedeemStreamTicket(ticket)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		c.Set("Email", user.Email)
+		c.Set("Nickname", user.Nickname)
+		c.Set("OrgID", record.OrganizationID)
+		c.Set("TokenVersion", record.TokenVersion)
+		c.Next()
+	}
+}
+
+// passwordChangeAllowedPaths 需要修改初始密码的用户在修改密码前可以访问的接口
+var passwordChangeAllowedPaths = map[string]bool{
+	"/user/profile":         true,
+	"/user/change_password": true,
+	"/user/logout":          true,
+	"/user/logout_all":      true,
+}
+
+// twoFactorSetupAllowedPaths 要求开启两步验证的用户在开启前可以访问的接口
+var twoFactorSetupAllowedPaths = map[string]bool{
+	"/user/profile":         true,
+	"/user/change_password": true,
+	"/user/logout":          true,
+	"/user/logout_all":      true,
+	"/user/2fa/setup":       true,
+	"/user/2fa/enable":      true,
+}
+
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -24,54 +66,86 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		// 已退出登录或被轮换的令牌不能再使用
+		revoked, err := services.IsTokenRevoked(claims.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token 已失效"})
+			c.Abort()
+			return
+		}
+		// 修改密码或角色后，之前签发的令牌不能再使用
+		user, err := services.CheckTokenVersion(claims.Email, claims.TokenVersion)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		// 使用一次性初始密码登录的用户修改密码前只能访问少数接口
+		if user.MustChangePassword && !passwordChangeAllowedPaths[c.FullPath()] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "请先修改初始密码"})
+			c.Abort()
+			return
+		}
+		// 要求开启两步验证的管理员在开启前只能访问设置两步验证的接口
+		if !twoFactorSetupAllowedPaths[c.FullPath()] {
+			required, err := services.TwoFactorSetupRequired(user)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			if required {
+				c.JSON(http.StatusForbidden, gin.H{"error": "请先开启两步验证"})
+				c.Abort()
+				return
+			}
+		}
 		// 将用户信息保存到上下文
 		c.Set("Email", claims.Email)
 		c.Set("Nickname", claims.Nickname)
+		c.Set("OrgID", claims.OrganizationID)
 		c.Set("LoginTime", claims.LoginTime.Format("2006-01-02 15:04:05"))
+		c.Set("JTI", claims.ID)
+		c.Set("TokenVersion", claims.TokenVersion)
+		c.Set("TokenExpiresAt", claims.ExpiresAt.Time)
 		c.Next()
 	}
 }
 
-func AdminMiddleware() gin.HandlerFunc {
+// RequirePermission 校验当前用户是否拥有指定权限，需要在 AuthMiddleware 之后使用
+func RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 1. 首先验证 JWT token
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+		email, exists := c.Get("Email")
+		if !exists {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "非法的请求"})
 			c.Abort()
 			return
 		}
 
-		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-		claims, err := utils.ParseJWT(tokenStr)
-		if err != nil {
+		// 权限变化后令牌立即失效，管理类接口在鉴权时再次确认令牌版本
+		if _, err := services.CheckTokenVersion(email.(string), c.GetUint("TokenVersion")); err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
-		// 2. 查询用户信息，验证管理员权限
-		var user models.User
-		result := models.DB.Where("email = ?", claims.Email).First(&user)
-		if result.Error != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		ok, err := services.HasPermission(email.(string), c.GetUint("OrgID"), permission)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
-
-		// 3. 检查用户是否是管理员
-		if !user.Admin {
-			c.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "没有访问权限: " + permission})
 			c.Abort()
 			return
 		}
 
-		// 4. 将用户信息保存到上下文
-		c.Set("Email", claims.Email)
-		c.Set("Nickname", claims.Nickname)
-		c.Set("LoginTime", claims.LoginTime.Format("2006-01-02 15:04:05"))
-		c.Set("IsAdmin", true)
-
 		c.Next()
 	}
 }
@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// redactedQueryParams 请求日志中需要隐藏取值的查询参数
+var redactedQueryParams = []string{"ticket", "token"}
+
+// redactPath 隐藏请求路径中凭证类查询参数的取值
+func redactPath(path string) string {
+	base, rawQuery, found := strings.Cut(path, "?")
+	if !found {
+		return path
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return base + "?[REDACTED]"
+	}
+	changed := false
+	for _, name := range redactedQueryParams {
+		if query.Has(name) {
+			query.Set(name, "[REDACTED]")
+			changed = true
+		}
+	}
+	if !changed {
+		return path
+	}
+	return base + "?" + query.Encode()
+}
+
+// Logger 与 gin 默认格式相同的请求日志，但不会记录地址中的推送凭证等敏感参数
+func Logger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		var statusColor, methodColor, resetColor string
+		if param.IsOutputColor() {
+			statusColor = param.StatusCodeColor()
+			methodColor = param.MethodColor()
+			resetColor = param.ResetColor()
+		}
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, param.StatusCode, resetColor,
+			param.Latency,
+			param.ClientIP,
+			methodColor, param.Method, resetColor,
+			redactPath(param.Path),
+			param.ErrorMessage,
+		)
+	})
+}
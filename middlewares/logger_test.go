@@ -0,0 +1,17 @@
+package middlewares
+
+import "testing"
+
+func TestRedactPath(t *testing.T) {
+	cases := map[string]string{
+		"/message/stream":                            "/message/stream",
+		"/message/stream?lastEventId=3":              "/message/stream?lastEventId=3",
+		"/message/stream?ticket=secret":              "/message/stream?ticket=%5BREDACTED%5D",
+		"/message/stream?token=secret&lastEventId=3": "/message/stream?lastEventId=3&token=%5BREDACTED%5D",
+	}
+	for path, want := range cases {
+		if got := redactPath(path); got != want {
+			t.Errorf("redactPath(%q) = %q，应为 %q", path, got, want)
+		}
+	}
+}
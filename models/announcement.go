@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// 公告受众类型
+const (
+	AudienceAll      = "all"      // 组织内所有成员
+	AudienceTask     = "task"     // 指定活动中审核通过的报名人，AudienceValue 为活动ID
+	AudienceSkill    = "skill"    // 拥有指定技能标签的成员，AudienceValue 为技能标签
+	AudienceCategory = "category" // 参加过指定类别活动并审核通过的成员，AudienceValue 为活动类别
+)
+
+// Announcement 公告，发送时按受众展开为每个接收者的一条消息
+type Announcement struct {
+	ID             uint       `gorm:"primaryKey"`
+	OrganizationID uint       `gorm:"index;not null"`     // 所属组织ID
+	Title          string     `gorm:"not null"`           // 公告标题
+	Content        string     `gorm:"type:text;not null"` // 公告内容
+	AudienceType   string     `gorm:"size:16;not null"`   // 受众类型
+	AudienceValue  string     `gorm:"size:255"`           // 受众参数
+	SendAt         time.Time  `gorm:"not null;index"`     // 计划发送时间
+	ExpiresAt      *time.Time // 过期时间，过期后接收者不再看到该公告
+	SentAt         *time.Time `gorm:"index"`     // 实际发送时间，未发送为空
+	Recipients     uint       `gorm:"default:0"` // 接收人数
+	CreatedBy      uint       `gorm:"not null"`  // 创建人的用户ID
+	CreatedAt      time.Time  // 创建时间
+}
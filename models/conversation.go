@@ -0,0 +1,37 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Conversation 用户之间的私信会话
+type Conversation struct {
+	ID             uint      `gorm:"primaryKey"`
+	OrganizationID uint      `gorm:"index;not null"` // 所属组织ID
+	TaskID         uint      `gorm:"default:0"`      // 会话相关的活动ID，0表示不关联活动
+	Subject        string    `gorm:"size:255"`       // 会话主题
+	CreatedBy      uint      `gorm:"not null"`       // 发起人的用户ID
+	LastMessageAt  time.Time `gorm:"index"`          // 最后一条私信的时间
+	CreatedAt      time.Time // 创建时间
+}
+
+// ConversationParticipant 会话参与者及其阅读进度
+type ConversationParticipant struct {
+	ID                uint      `gorm:"primaryKey"`
+	ConversationID    uint      `gorm:"not null;uniqueIndex:idx_conversation_participant"`       // 会话ID
+	UserID            uint      `gorm:"not null;uniqueIndex:idx_conversation_participant;index"` // 参与者的用户ID
+	LastReadMessageID uint      `gorm:"default:0"`                                               // 已读到的最后一条私信ID
+	CreatedAt         time.Time // 加入时间
+}
+
+// DirectMessage 会话中的一条私信
+type DirectMessage struct {
+	ID             uint           `gorm:"primaryKey"`
+	ConversationID uint           `gorm:"index;not null"`     // 会话ID
+	SenderID       uint           `gorm:"not null"`           // 发送者的用户ID
+	Content        string         `gorm:"type:text;not null"` // 私信内容
+	Hidden         bool           `gorm:"default:false"`      // 是否被管理员屏蔽，屏蔽后参与者不可见
+	CreatedAt      time.Time      // 发送时间
+	DeletedAt      gorm.DeletedAt `gorm:"index"` // 删除时间
+}
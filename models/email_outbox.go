@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// 邮件发送状态
+const (
+	EmailStatusPending = "pending" // 待发送
+	EmailStatusSent    = "sent"    // 已发送
+	EmailStatusFailed  = "failed"  // 多次重试后仍发送失败
+	EmailStatusDigest  = "digest"  // 等待合并到每日摘要
+	EmailStatusMerged  = "merged"  // 已合并到每日摘要
+)
+
+// EmailOutbox 待发送的邮件，与消息在同一事务中写入，由后台任务异步发送并在失败时重试
+type EmailOutbox struct {
+	ID            uint       `gorm:"primaryKey"`
+	UserID        uint       `gorm:"index;not null"`                                          // 接收者的用户ID
+	MessageID     uint       `gorm:"index;default:0"`                                         // 关联的消息ID
+	To            string     `gorm:"size:255;not null"`                                       // 收件人地址
+	Subject       string     `gorm:"not null"`                                                // 邮件主题
+	Body          string     `gorm:"type:text;not null"`                                      // HTML 邮件正文，等待合并到摘要时为消息原文
+	Status        string     `gorm:"size:16;not null;default:'pending';index:idx_outbox_due"` // 发送状态
+	Attempts      int        `gorm:"default:0"`                                               // 已尝试发送次数
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_outbox_due"`                           // 下次尝试发送的时间，等待合并到摘要时为摘要的发送时间
+	LastError     string     `gorm:"size:512"`                                                // 最近一次发送失败的原因
+	Sensitive     bool       `gorm:"default:false"`                                           // 正文包含重置密码等一次性链接，发送成功或最终失败后清空正文
+	SentAt        *time.Time // 发送成功的时间
+	CreatedAt     time.Time  // 创建时间
+}
+
+// accountEmailSubjects 包含一次性链接的账户邮件主题，用于清理加入 Sensitive 字段之前写入的邮件
+var accountEmailSubjects = []string{"重置密码", "验证邮箱"}
+
+// migrateSensitiveEmails 首次加入 Sensitive 字段时标记已有的账户邮件，并清空已经发送或最终失败的账户邮件的正文
+func migrateSensitiveEmails(missingColumn bool) error {
+	if !missingColumn {
+		return nil
+	}
+	if err := DB.Model(&EmailOutbox{}).Where("subject IN ?", accountEmailSubjects).Update("sensitive", true).Error; err != nil {
+		return errors.New("无法标记账户邮件")
+	}
+	if err := DB.Model(&EmailOutbox{}).
+		Where("sensitive = ? AND status IN ?", true, []string{EmailStatusSent, EmailStatusFailed}).
+		Update("body", "").Error; err != nil {
+		return errors.New("无法清理已发送的账户邮件")
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ExternalIdentity 用户在外部身份提供方的身份，通过统一身份认证登录时按 身份提供方+用户标识 找到对应的用户
+type ExternalIdentity struct {
+	ID          uint      `gorm:"primaryKey"`
+	UserID      uint      `gorm:"not null;index"`                                     // 所属用户
+	Provider    string    `gorm:"size:255;not null;uniqueIndex:idx_provider_subject"` // 身份提供方的 issuer
+	Subject     string    `gorm:"size:255;not null;uniqueIndex:idx_provider_subject"` // 身份提供方中的用户标识
+	Email       string    `gorm:"size:255"`                                           // 最近一次登录时身份提供方返回的邮箱
+	CreatedAt   time.Time // 关联时间
+	LastLoginAt time.Time // 最近一次登录时间
+}
+
+// OIDCLoginState 统一身份认证登录过程中保存的状态，回调时校验 state 并取出 nonce 和 PKCE 校验码，只能使用一次
+type OIDCLoginState struct {
+	ID           uint      `gorm:"primaryKey"`
+	StateHash    string    `gorm:"size:64;not null;uniqueIndex"` // state 的 SHA-256 摘要
+	BindingHash  string    `gorm:"size:64;not null"`             // 发起登录的浏览器 Cookie 中随机值的 SHA-256 摘要，防止登录 CSRF
+	Nonce        string    `gorm:"size:64;not null"`             // 写入 ID Token 的随机数，防止 ID Token 被重放
+	CodeVerifier string    `gorm:"size:128;not null"`            // PKCE 校验码
+	ExpiresAt    time.Time `gorm:"not null;index"`               // 过期时间
+	CreatedAt    time.Time // 创建时间
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"volunteer-system-backend/config"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
@@ -23,56 +25,150 @@ func InitDB() {
 	if err != nil {
 		log.Fatalf("无法连接到数据库: %v", err)
 	}
+	if err := Migrate(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Migrate 自动迁移表结构并写入内置角色、消息模板等初始数据
+func Migrate() error {
+	// 邮箱验证字段加入前已存在的用户需要在迁移后标记为已验证
+	unverifiedColumn := DB.Migrator().HasTable(&User{}) && !DB.Migrator().HasColumn(&User{}, "email_verified_at")
+	// 发件箱加入 Sensitive 字段前写入的账户邮件需要在迁移后清理
+	sensitiveColumn := DB.Migrator().HasTable(&EmailOutbox{}) && !DB.Migrator().HasColumn(&EmailOutbox{}, "sensitive")
+	// 报名记录的唯一索引加入前需要先清理重复的报名
+	if err := dedupeTaskParticipants(); err != nil {
+		return err
+	}
 	// 自动迁移
-	err = DB.AutoMigrate(&User{}, &Task{}, &TaskParticipant{}, &Message{})
+	err := DB.AutoMigrate(&User{}, &Task{}, &TaskParticipant{}, &Message{}, &TaskCoordinator{}, &Role{}, &Permission{},
+		&Organization{}, &OrganizationMember{}, &Team{}, &TeamMember{}, &TeamRegistration{},
+		&Announcement{}, &EmailOutbox{}, &NotificationPreference{}, &TaskReminder{}, &MessageTemplate{}, &TaskComment{},
+		&Conversation{}, &ConversationParticipant{}, &DirectMessage{}, &RefreshToken{}, &RevokedToken{}, &PasswordResetToken{}, &LoginAttempt{}, &RecoveryCode{},
+		&ExternalIdentity{}, &OIDCLoginState{}, &OrganizationInvitation{}, &MessageStreamTicket{})
 	if err != nil {
-		log.Fatalf("数据库自动迁移失败: %v", err)
+		return fmt.Errorf("数据库自动迁移失败: %v", err)
 	}
-	err = CreateAdminUser()
-	if err != nil {
-		log.Fatal(err)
+	if err := SeedRoles(); err != nil {
+		return err
+	}
+	if err := SeedMessageTemplates(); err != nil {
+		return err
+	}
+	if err := migrateEmailVerified(unverifiedColumn); err != nil {
+		return err
+	}
+	if err := migrateSensitiveEmails(sensitiveColumn); err != nil {
+		return err
+	}
+	if err := migrateAdminColumn(); err != nil {
+		return err
+	}
+	if err := flagLegacyAdminPassword(); err != nil {
+		return err
+	}
+	if err := migrateDefaultOrganization(); err != nil {
+		return err
+	}
+	return CreateTestTask()
+}
+
+// dedupeTaskParticipants 删除同一用户对同一活动的重复报名，只保留最早的一条，并释放重复报名占用的名额
+func dedupeTaskParticipants() error {
+	if !DB.Migrator().HasTable(&TaskParticipant{}) || DB.Migrator().HasIndex(&TaskParticipant{}, "idx_task_participant") {
+		return nil
+	}
+	var duplicates []TaskParticipant
+	if err := DB.Unscoped().
+		Where("id NOT IN (?)", DB.Unscoped().Model(&TaskParticipant{}).Select("MIN(id)").Group("task_id, email")).
+		Find(&duplicates).Error; err != nil {
+		return fmt.Errorf("无法查询重复的报名记录: %v", err)
 	}
-	err = CreateTestTask()
+	if len(duplicates) == 0 {
+		return nil
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, duplicate := range duplicates {
+			if err := tx.Unscoped().Delete(&TaskParticipant{}, duplicate.ID).Error; err != nil {
+				return err
+			}
+			// 待审核和审核通过的报名占用了名额
+			if duplicate.DeletedAt.Valid || (duplicate.Status != 0 && duplicate.Status != 1) {
+				continue
+			}
+			if err := tx.Model(&Task{}).Where("id = ? AND joined > 0", duplicate.TaskID).
+				Update("joined", gorm.Expr("joined - 1")).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("无法删除重复的报名记录: %v", err)
 	}
+	log.Printf("已删除 %d 条重复的报名记录", len(duplicates))
+	return nil
 }
 
-func CreateAdminUser() error {
-	// 加密密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.DefaultCost)
+// 旧版本启动时自动创建的默认管理员账号
+const (
+	legacyAdminEmail    = "admin@admin.com"
+	legacyAdminPassword = "123456"
+)
+
+// flagLegacyAdminPassword 旧版本创建的默认管理员仍在使用默认密码时，将密码替换为随机值并使已签发的令牌失效
+// 默认密码是公开的，仅要求登录后修改密码并不能阻止其他人先登录，管理员需要重置密码或重新创建管理员后才能登录
+func flagLegacyAdminPassword() error {
+	var user User
+	if err := DB.Where("email = ?", legacyAdminEmail).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("无法查询默认管理员: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(legacyAdminPassword)) != nil {
+		return nil
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return fmt.Errorf("无法生成随机密码: %v", err)
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(random)), bcrypt.DefaultCost)
 	if err != nil {
-		return errors.New("无法对密码进行哈希处理")
-	}
-	// 创建新用户
-	user := User{
-		Email:         "admin@admin.com",
-		Password:      string(hashedPassword),
-		Nickname:      "管理员",
-		Gender:        "保密",
-		Avatar:        "",
-		Phone:         "188888888888",
-		CreatedAt:     time.Now().Local(),
-		Duration:      999,
-		Admin:         true,
-		LastLoginTime: time.Now().Local(),
-	}
-	if err := DB.Where("email = ?", user.Email).First(&user).Error; err != nil {
-		if err = DB.Create(&user).Error; err != nil {
-			return errors.New("无法创建管理员用户")
+		return fmt.Errorf("无法对密码进行哈希处理: %v", err)
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]any{
+			"password":             string(hashed),
+			"must_change_password": true,
+			"token_version":        gorm.Expr("token_version + 1"),
+		}).Error; err != nil {
+			return err
 		}
+		// 访问令牌通过令牌版本失效，刷新令牌需要单独撤销
+		return tx.Model(&RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", time.Now()).Error
+	})
+	if err != nil {
+		return fmt.Errorf("无法停用默认管理员密码: %v", err)
 	}
+	log.Printf("默认管理员 %s 仍在使用默认密码，已停用该密码并使其登录失效，请通过找回密码重置，或使用 -bootstrap-admin 参数创建新的超级管理员", legacyAdminEmail)
 	return nil
 }
 
 func CreateTestTask() error {
+	var org Organization
+	if err := DB.Where("name = ?", DefaultOrganizationName).First(&org).Error; err != nil {
+		return nil
+	}
 	task := Task{
-		Name:      "冬至晚会",
-		CreatedAt: time.Now().Local(),
-		StartTime: time.Now().Local(),
-		EndTime:   time.Now().Add(60 * time.Minute).Local(),
-		Location:  "大礼堂",
-		Limit:     50,
+		OrganizationID: org.ID,
+		Name:           "冬至晚会",
+		CreatedAt:      time.Now().Local(),
+		StartTime:      time.Now().Local(),
+		EndTime:        time.Now().Add(60 * time.Minute).Local(),
+		Location:       "大礼堂",
+		Limit:          50,
 	}
 	if err := DB.Where("name = ?", task.Name).First(&Task{}).Error; err != nil {
 		if err = DB.Create(&task).Error; err != nil {
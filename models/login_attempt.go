@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// LoginAttempt 登录记录，用于按账户和IP限制失败的登录尝试，并在登录成功后向用户展示期间的失败记录
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"index;default:0"`            // 登录的用户ID，邮箱未注册时为0
+	Email     string    `gorm:"size:255;index"`             // 登录时填写的邮箱
+	IP        string    `gorm:"size:64;index:idx_login_ip"` // 登录IP
+	Success   bool      `gorm:"default:false"`              // 是否登录成功
+	CreatedAt time.Time `gorm:"index:idx_login_ip"`         // 登录时间
+}
@@ -1,15 +1,40 @@
 package models
 
 import (
+	"gorm.io/gorm"
 	"time"
 )
 
+// 消息分类
+const (
+	MessageCategorySystem       = "system"       // 系统通知
+	MessageCategoryApproval     = "approval"     // 报名审核
+	MessageCategoryReminder     = "reminder"     // 活动提醒
+	MessageCategoryAnnouncement = "announcement" // 公告
+)
+
 // Message 表示用户消息
 type Message struct {
-	ID      uint      `gorm:"primaryKey"`
-	UserID  uint      `gorm:"not null"`                  // 关联的用户ID
-	Title   string    `gorm:"not null"`                  // 消息标题
-	Content string    `gorm:"not null"`                  // 消息内容
-	Time    time.Time `gorm:"not null"`                  // 消息时间
-	Status  string    `gorm:"not null;default:'unread'"` // 消息状态，默认未读
+	ID             uint           `gorm:"primaryKey"`
+	UserID         uint           `gorm:"not null;index"`            // 关联的用户ID
+	OrganizationID uint           `gorm:"index;default:0"`           // 所属组织ID，0表示系统消息
+	Category       string         `gorm:"size:32;default:'system'"`  // 消息分类
+	Title          string         `gorm:"not null"`                  // 消息标题
+	Content        string         `gorm:"not null"`                  // 消息内容
+	Time           time.Time      `gorm:"not null"`                  // 消息时间
+	Status         string         `gorm:"not null;default:'unread'"` // 消息状态，默认未读
+	AnnouncementID uint           `gorm:"index;default:0"`           // 来源公告ID，非公告消息为0
+	ExpiresAt      *time.Time     // 过期时间，过期后不再展示
+	DeletedAt      gorm.DeletedAt `gorm:"index"` // 删除时间，用户删除消息时软删除
+}
+
+// MessageStreamTicket 建立消息推送连接使用的一次性凭证，EventSource 无法设置请求头，浏览器通过查询参数传递凭证而不是访问令牌
+type MessageStreamTicket struct {
+	ID             uint      `gorm:"primaryKey"`
+	TicketHash     string    `gorm:"size:64;not null;uniqueIndex"` // 凭证的 SHA-256 摘要，不保存明文
+	UserID         uint      `gorm:"not null;index"`               // 所属用户
+	OrganizationID uint      // 签发时所在的组织
+	TokenVersion   uint      // 签发时用户的令牌版本
+	ExpiresAt      time.Time `gorm:"not null;index"` // 过期时间
+	CreatedAt      time.Time // 创建时间
 }
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// 支持的语言
+const (
+	LocaleZhCN    = "zh-CN"
+	LocaleEnUS    = "en-US"
+	DefaultLocale = LocaleZhCN
+)
+
+// 消息模板对应的事件类型
+const (
+	EventTaskJoined            = "task.joined"            // 有用户报名活动，通知活动管理员
+	EventRegistrationSubmitted = "registration.submitted" // 报名已提交，通知报名人等待审核
+	EventVolunteerApproved     = "volunteer.approved"     // 报名审核通过
+	EventVolunteerRejected     = "volunteer.rejected"     // 报名审核拒绝
+	EventTeamInvited           = "team.invited"           // 被邀请加入团队
+	EventOrgInvited            = "org.invited"            // 被邀请加入组织
+	EventTeamJoined            = "team.joined"            // 团队报名活动，通知活动管理员
+	EventTeamApproved          = "team.approved"          // 团队报名审核通过
+	EventTeamRejected          = "team.rejected"          // 团队报名审核拒绝
+	EventTaskReminder          = "task.reminder"          // 活动开始提醒
+	EventTaskCancelled         = "task.cancelled"         // 活动被取消，通知已报名的用户
+	EventCommentReplied        = "comment.replied"        // 讨论有新的回复，通知参与讨论的用户
+	EventOrgJoined             = "org.joined"             // 注册或凭邀请码加入组织，通知加入的用户
+	EventPasswordChanged       = "password.changed"       // 密码已修改，提醒用户确认是本人操作
+)
+
+// MessageTemplate 消息模板，标题和内容使用 Go text/template 语法
+type MessageTemplate struct {
+	ID        uint      `gorm:"primaryKey"`
+	EventType string    `gorm:"size:64;not null;uniqueIndex:idx_message_template"` // 事件类型
+	Locale    string    `gorm:"size:16;not null;uniqueIndex:idx_message_template"` // 语言
+	Category  string    `gorm:"size:32;not null"`                                  // 消息分类
+	Title     string    `gorm:"not null"`                                          // 标题模板
+	Content   string    `gorm:"type:text;not null"`                                // 内容模板
+	UpdatedBy uint      `gorm:"default:0"`                                         // 最近一次修改人的用户ID，0表示内置模板
+	UpdatedAt time.Time // 修改时间
+}
+
+// builtinMessageTemplates 内置消息模板
+var builtinMessageTemplates = []MessageTemplate{
+	{EventType: EventTaskJoined, Locale: LocaleZhCN, Category: MessageCategoryApproval,
+		Title: "新的待审核通知", Content: `管理员您好，{{.Nickname}}报名了活动"{{.TaskName}}"，请审核`},
+	{EventType: EventTaskJoined, Locale: LocaleEnUS, Category: MessageCategoryApproval,
+		Title: "New registration to review", Content: `{{.Nickname}} has registered for "{{.TaskName}}". Please review the application.`},
+	{EventType: EventRegistrationSubmitted, Locale: LocaleZhCN, Category: MessageCategoryApproval,
+		Title: "报名提交通知", Content: `您已报名活动"{{.TaskName}}"，请等待审核`},
+	{EventType: EventRegistrationSubmitted, Locale: LocaleEnUS, Category: MessageCategoryApproval,
+		Title: "Registration submitted", Content: `Your registration for "{{.TaskName}}" has been submitted and is awaiting review.`},
+	{EventType: EventVolunteerApproved, Locale: LocaleZhCN, Category: MessageCategoryApproval,
+		Title: "申请通过通知", Content: `您的申请已被通过，活动名称: "{{.TaskName}}"`},
+	{EventType: EventVolunteerApproved, Locale: LocaleEnUS, Category: MessageCategoryApproval,
+		Title: "Application approved", Content: `Your application for "{{.TaskName}}" has been approved.`},
+	{EventType: EventVolunteerRejected, Locale: LocaleZhCN, Category: MessageCategoryApproval,
+		Title: "申请拒绝通知", Content: `很抱歉，您的申请已被拒绝，活动名称: "{{.TaskName}}"`},
+	{EventType: EventVolunteerRejected, Locale: LocaleEnUS, Category: MessageCategoryApproval,
+		Title: "Application rejected", Content: `Sorry, your application for "{{.TaskName}}" has been rejected.`},
+	{EventType: EventTeamInvited, Locale: LocaleZhCN, Category: MessageCategorySystem,
+		Title: "团队邀请通知", Content: `您被邀请加入团队: "{{.TeamName}}"`},
+	{EventType: EventTeamInvited, Locale: LocaleEnUS, Category: MessageCategorySystem,
+		Title: "Team invitation", Content: `You have been invited to join the team "{{.TeamName}}".`},
+	{EventType: EventOrgInvited, Locale: LocaleZhCN, Category: MessageCategorySystem,
+		Title: "组织邀请通知", Content: `您被邀请以"{{.RoleName}}"身份加入组织: "{{.OrgName}}"，请在组织邀请中接受或拒绝`},
+	{EventType: EventOrgInvited, Locale: LocaleEnUS, Category: MessageCategorySystem,
+		Title: "Organization invitation", Content: `You have been invited to join "{{.OrgName}}" as {{.RoleName}}. Accept or decline it in your organization invitations.`},
+	{EventType: EventTeamJoined, Locale: LocaleZhCN, Category: MessageCategoryApproval,
+		Title: "新的团队待审核通知", Content: `管理员您好，团队"{{.TeamName}}"报名了活动"{{.TaskName}}"，请审核`},
+	{EventType: EventTeamJoined, Locale: LocaleEnUS, Category: MessageCategoryApproval,
+		Title: "New team registration to review", Content: `The team "{{.TeamName}}" has registered for "{{.TaskName}}". Please review the application.`},
+	{EventType: EventTeamApproved, Locale: LocaleZhCN, Category: MessageCategoryApproval,
+		Title: "申请通过通知", Content: `您所在团队"{{.TeamName}}"的申请已被通过，活动名称: "{{.TaskName}}"`},
+	{EventType: EventTeamApproved, Locale: LocaleEnUS, Category: MessageCategoryApproval,
+		Title: "Application approved", Content: `The application of your team "{{.TeamName}}" for "{{.TaskName}}" has been approved.`},
+	{EventType: EventTeamRejected, Locale: LocaleZhCN, Category: MessageCategoryApproval,
+		Title: "申请拒绝通知", Content: `很抱歉，您所在团队"{{.TeamName}}"的申请已被拒绝，活动名称: "{{.TaskName}}"`},
+	{EventType: EventTeamRejected, Locale: LocaleEnUS, Category: MessageCategoryApproval,
+		Title: "Application rejected", Content: `Sorry, the application of your team "{{.TeamName}}" for "{{.TaskName}}" has been rejected.`},
+	{EventType: EventTaskReminder, Locale: LocaleZhCN, Category: MessageCategoryReminder,
+		Title: "活动开始提醒", Content: `您报名的活动"{{.TaskName}}"将于 {{.StartTime}} 开始，地点: {{.Location}}，请准时参加`},
+	{EventType: EventTaskReminder, Locale: LocaleEnUS, Category: MessageCategoryReminder,
+		Title: "Upcoming task reminder", Content: `"{{.TaskName}}" starts at {{.StartTime}} at {{.Location}}. Please arrive on time.`},
+	{EventType: EventTaskCancelled, Locale: LocaleZhCN, Category: MessageCategorySystem,
+		Title: "活动取消通知", Content: `很抱歉，您报名的活动"{{.TaskName}}"已被取消`},
+	{EventType: EventTaskCancelled, Locale: LocaleEnUS, Category: MessageCategorySystem,
+		Title: "Task cancelled", Content: `Sorry, "{{.TaskName}}" that you registered for has been cancelled.`},
+	{EventType: EventCommentReplied, Locale: LocaleZhCN, Category: MessageCategorySystem,
+		Title: "讨论回复通知", Content: `{{.Nickname}}在活动"{{.TaskName}}"的讨论中回复: {{.Content}}`},
+	{EventType: EventCommentReplied, Locale: LocaleEnUS, Category: MessageCategorySystem,
+		Title: "New reply", Content: `{{.Nickname}} replied in the discussion of "{{.TaskName}}": {{.Content}}`},
+	{EventType: EventOrgJoined, Locale: LocaleZhCN, Category: MessageCategorySystem,
+		Title: "加入组织通知", Content: `您已以"{{.RoleName}}"身份加入组织: "{{.OrgName}}"`},
+	{EventType: EventOrgJoined, Locale: LocaleEnUS, Category: MessageCategorySystem,
+		Title: "Joined organization", Content: `You have joined "{{.OrgName}}" as {{.RoleName}}.`},
+	{EventType: EventPasswordChanged, Locale: LocaleZhCN, Category: MessageCategorySystem,
+		Title: "密码修改通知", Content: `您的账户密码已于 {{.Time}} 修改，其他设备上的登录已失效，如果不是您本人操作，请立即找回密码`},
+	{EventType: EventPasswordChanged, Locale: LocaleEnUS, Category: MessageCategorySystem,
+		Title: "Password changed", Content: `Your password was changed at {{.Time}} and your other sessions have been signed out. If this wasn't you, reset your password immediately.`},
+}
+
+// SeedMessageTemplates 初始化内置消息模板，已存在的模板保留管理员修改后的内容
+func SeedMessageTemplates() error {
+	for _, builtin := range builtinMessageTemplates {
+		template := builtin
+		template.UpdatedAt = time.Now().Local()
+		if err := DB.Where(MessageTemplate{EventType: builtin.EventType, Locale: builtin.Locale}).FirstOrCreate(&template).Error; err != nil {
+			return errors.New("无法初始化消息模板")
+		}
+	}
+	return nil
+}
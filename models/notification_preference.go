@@ -0,0 +1,10 @@
+package models
+
+// NotificationPreference 用户对某个通知渠道中某类消息的订阅设置，没有设置时使用渠道的默认值
+type NotificationPreference struct {
+	ID       uint   `gorm:"primaryKey"`
+	UserID   uint   `gorm:"not null;uniqueIndex:idx_notification_preference"`         // 用户ID
+	Channel  string `gorm:"size:16;not null;uniqueIndex:idx_notification_preference"` // 通知渠道
+	Category string `gorm:"size:32;not null;uniqueIndex:idx_notification_preference"` // 消息分类
+	Enabled  bool   `gorm:"not null"`                                                 // 是否接收
+}
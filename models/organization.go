@@ -0,0 +1,126 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+)
+
+// Organization 组织（学生社团等），不同组织之间的志愿者、活动和消息互相隔离
+type Organization struct {
+	ID        uint      `gorm:"primaryKey"`
+	Name      string    `gorm:"unique;size:128;not null"` // 组织名称
+	Code      string    `gorm:"unique;size:32;not null"`  // 邀请码，用户凭邀请码加入组织
+	CreatedAt time.Time // 创建时间
+}
+
+// OrganizationMember 表示用户在组织中的成员身份
+type OrganizationMember struct {
+	ID             uint      `gorm:"primaryKey"`
+	OrganizationID uint      `gorm:"not null;uniqueIndex:idx_org_member"` // 关联的组织ID
+	UserID         uint      `gorm:"not null;uniqueIndex:idx_org_member"` // 关联的用户ID
+	RoleID         uint      `gorm:"not null"`                            // 用户在该组织中的角色
+	CreatedAt      time.Time // 加入时间
+}
+
+// OrganizationInvitation 组织管理员发出的加入邀请，被邀请的用户接受后才成为组织成员
+type OrganizationInvitation struct {
+	ID             uint      `gorm:"primaryKey"`
+	OrganizationID uint      `gorm:"not null;uniqueIndex:idx_org_invitation"` // 关联的组织ID
+	UserID         uint      `gorm:"not null;uniqueIndex:idx_org_invitation"` // 被邀请的用户ID
+	RoleID         uint      `gorm:"not null"`                                // 接受邀请后在组织中的角色
+	InvitedBy      uint      `gorm:"not null"`                                // 邀请人的用户ID
+	CreatedAt      time.Time // 邀请时间
+}
+
+// DefaultOrganizationName 升级前已有的数据会被迁移到该组织
+const DefaultOrganizationName = "默认组织"
+
+// legacyDefaultOrganizationCode 旧版本迁移时默认组织使用的固定邀请码
+const legacyDefaultOrganizationCode = "default"
+
+// newOrganizationCode 生成12位的随机邀请码
+func newOrganizationCode() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.New("无法生成邀请码")
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// rotateLegacyDefaultCode 将旧版本默认组织的固定邀请码替换为随机邀请码，固定邀请码可以被任何注册用户猜到
+func rotateLegacyDefaultCode() error {
+	code, err := newOrganizationCode()
+	if err != nil {
+		return err
+	}
+	result := DB.Model(&Organization{}).Where("code = ?", legacyDefaultOrganizationCode).Update("code", code)
+	if result.Error != nil {
+		return errors.New("无法更换默认组织的邀请码")
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("默认组织的邀请码已更换为 %s", code)
+	}
+	return nil
+}
+
+// migrateDefaultOrganization 首次启用多组织时创建默认组织，并将已有的活动和用户归入其中
+func migrateDefaultOrganization() error {
+	var count int64
+	if err := DB.Model(&Organization{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return rotateLegacyDefaultCode()
+	}
+	code, err := newOrganizationCode()
+	if err != nil {
+		return err
+	}
+	org := Organization{
+		Name:      DefaultOrganizationName,
+		Code:      code,
+		CreatedAt: time.Now().Local(),
+	}
+	if err := DB.Create(&org).Error; err != nil {
+		return errors.New("无法创建默认组织")
+	}
+	if err := DB.Model(&Task{}).Where("organization_id = 0").Update("organization_id", org.ID).Error; err != nil {
+		return errors.New("无法迁移已有活动")
+	}
+	if err := DB.Model(&Message{}).Where("organization_id = 0").Update("organization_id", org.ID).Error; err != nil {
+		return errors.New("无法迁移已有消息")
+	}
+
+	superAdmin, err := GetRoleByName(RoleSuperAdmin)
+	if err != nil {
+		return err
+	}
+	orgAdmin, err := GetRoleByName(RoleOrgAdmin)
+	if err != nil {
+		return err
+	}
+	var users []User
+	if err := DB.Find(&users).Error; err != nil {
+		return err
+	}
+	for _, user := range users {
+		// 超级管理员在组织内以组织管理员身份出现，其他用户保留原有角色
+		roleID := user.RoleID
+		if roleID == superAdmin.ID {
+			roleID = orgAdmin.ID
+		}
+		member := OrganizationMember{
+			OrganizationID: org.ID,
+			UserID:         user.ID,
+			RoleID:         roleID,
+			CreatedAt:      time.Now().Local(),
+		}
+		if err := DB.Create(&member).Error; err != nil {
+			return errors.New("无法迁移已有用户")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// PasswordResetToken 找回密码时发送的一次性重置令牌，只保存令牌的摘要
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index"`               // 所属用户
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex"` // 令牌的 SHA-256 摘要
+	ExpiresAt time.Time  `gorm:"not null;index"`               // 过期时间
+	UsedAt    *time.Time // 使用时间，未使用为空
+	RequestIP string     `gorm:"size:64"` // 申请重置时的IP
+	CreatedAt time.Time  // 创建时间
+}
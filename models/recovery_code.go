@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RecoveryCode 两步验证的恢复码，无法使用身份验证器时代替验证码登录，每个恢复码只能使用一次
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index"`   // 所属用户
+	CodeHash  string     `gorm:"size:64;not null"` // 恢复码的 SHA-256 摘要
+	UsedAt    *time.Time // 使用时间，未使用为空
+	CreatedAt time.Time  // 生成时间
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// RefreshToken 服务端保存的刷新令牌，每次刷新都会轮换，同一次登录产生的令牌属于同一个家族
+type RefreshToken struct {
+	ID             uint       `gorm:"primaryKey"`
+	UserID         uint       `gorm:"not null;index"`                  // 所属用户
+	TokenHash      string     `gorm:"size:64;not null;uniqueIndex"`    // 令牌的 SHA-256 摘要，不保存明文
+	FamilyID       string     `gorm:"size:36;not null;index"`          // 令牌家族，同一次登录轮换出的令牌共用
+	AccessJTI      string     `gorm:"column:access_jti;size:64;index"` // 与该刷新令牌一同签发的访问令牌的 jti
+	OrganizationID uint       // 刷新时进入的组织
+	ExpiresAt      time.Time  `gorm:"not null;index"` // 过期时间
+	RevokedAt      *time.Time // 撤销或被轮换的时间，为空表示仍然有效
+	ReplacedBy     uint       // 轮换后新令牌的ID
+	CreatedAt      time.Time  // 创建时间
+}
+
+// RevokedToken 已撤销但尚未过期的访问令牌，过期后可以清理
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	JTI       string    `gorm:"column:jti;size:64;not null;uniqueIndex"` // 访问令牌的 jti
+	ExpiresAt time.Time `gorm:"not null;index"`                          // 访问令牌的过期时间
+}
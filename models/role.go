@@ -0,0 +1,175 @@
+package models
+
+import (
+	"errors"
+)
+
+// 内置角色
+const (
+	RoleSuperAdmin  = "super_admin" // 超级管理员
+	RoleOrgAdmin    = "org_admin"   // 组织管理员
+	RoleCoordinator = "coordinator" // 活动协调员
+	RoleVolunteer   = "volunteer"   // 志愿者
+	RoleAuditor     = "auditor"     // 只读审计员
+)
+
+// 权限标识
+const (
+	PermTaskRead         = "task:read"         // 查看活动
+	PermTaskJoin         = "task:join"         // 报名活动
+	PermTaskCreate       = "task:create"       // 创建活动
+	PermTaskUpdate       = "task:update"       // 修改活动
+	PermTaskDelete       = "task:delete"       // 删除活动
+	PermTaskAudit        = "task:audit"        // 查看所有活动的报名情况
+	PermTaskManage       = "task:manage"       // 管理所有活动的审核、签到和时长
+	PermTaskCoordinate   = "task:coordinate"   // 管理被分配活动的审核、签到和时长
+	PermCoordinatorAdmin = "coordinator:admin" // 分配活动协调员
+	PermVolunteerRead    = "volunteer:read"    // 查看志愿者列表
+	PermMessageSend      = "message:send"      // 发送消息
+	PermRoleAssign       = "role:assign"       // 分配用户角色
+	PermOrgCreate        = "org:create"        // 创建组织
+	PermOrgMember        = "org:member"        // 管理组织成员
+	PermAnnouncement     = "announcement:send" // 发布公告
+	PermTemplateManage   = "template:manage"   // 管理消息模板
+	PermMessageModerate  = "message:moderate"  // 管理组织内的私信
+)
+
+// Role 角色
+type Role struct {
+	ID          uint         `gorm:"primaryKey"`
+	Name        string       `gorm:"unique;size:64;not null"`    // 角色标识
+	DisplayName string       `gorm:"not null"`                   // 角色名称
+	Permissions []Permission `gorm:"many2many:role_permissions"` // 角色拥有的权限
+}
+
+// Permission 权限
+type Permission struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"unique;size:64;not null"` // 权限标识
+	Description string // 权限说明
+}
+
+// HasPermission 判断角色是否拥有指定权限
+func (r Role) HasPermission(name string) bool {
+	for _, p := range r.Permissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+var permissionDescriptions = map[string]string{
+	PermTaskRead:         "查看活动",
+	PermTaskJoin:         "报名活动",
+	PermTaskCreate:       "创建活动",
+	PermTaskUpdate:       "修改活动",
+	PermTaskDelete:       "删除活动",
+	PermTaskAudit:        "查看所有活动的报名情况",
+	PermTaskManage:       "管理所有活动的审核、签到和时长",
+	PermTaskCoordinate:   "管理被分配活动的审核、签到和时长",
+	PermCoordinatorAdmin: "分配活动协调员",
+	PermVolunteerRead:    "查看志愿者列表",
+	PermMessageSend:      "发送消息",
+	PermRoleAssign:       "分配用户角色",
+	PermOrgCreate:        "创建组织",
+	PermOrgMember:        "管理组织成员",
+	PermAnnouncement:     "发布公告",
+	PermTemplateManage:   "管理消息模板",
+	PermMessageModerate:  "管理组织内的私信",
+}
+
+// builtinRoles 内置角色及其默认权限
+var builtinRoles = []struct {
+	Name        string
+	DisplayName string
+	Permissions []string
+}{
+	{RoleSuperAdmin, "超级管理员", []string{
+		PermTaskRead, PermTaskJoin, PermTaskCreate, PermTaskUpdate, PermTaskDelete, PermTaskAudit,
+		PermTaskManage, PermTaskCoordinate, PermCoordinatorAdmin, PermVolunteerRead, PermMessageSend, PermRoleAssign,
+		PermOrgCreate, PermOrgMember, PermAnnouncement, PermTemplateManage, PermMessageModerate,
+	}},
+	{RoleOrgAdmin, "组织管理员", []string{
+		PermTaskRead, PermTaskJoin, PermTaskCreate, PermTaskUpdate, PermTaskDelete, PermTaskAudit,
+		PermTaskManage, PermTaskCoordinate, PermCoordinatorAdmin, PermVolunteerRead, PermMessageSend,
+		PermOrgMember, PermAnnouncement, PermMessageModerate,
+	}},
+	{RoleCoordinator, "活动协调员", []string{
+		PermTaskRead, PermTaskJoin, PermTaskCoordinate, PermVolunteerRead,
+	}},
+	{RoleVolunteer, "志愿者", []string{
+		PermTaskRead, PermTaskJoin, PermVolunteerRead,
+	}},
+	{RoleAuditor, "审计员", []string{
+		PermTaskRead, PermTaskAudit, PermVolunteerRead,
+	}},
+}
+
+// SeedRoles 初始化内置角色和权限
+func SeedRoles() error {
+	permissions := make(map[string]Permission)
+	for name, description := range permissionDescriptions {
+		permission := Permission{Name: name, Description: description}
+		if err := DB.Where(Permission{Name: name}).FirstOrCreate(&permission).Error; err != nil {
+			return errors.New("无法初始化权限")
+		}
+		permissions[name] = permission
+	}
+	for _, builtin := range builtinRoles {
+		role := Role{Name: builtin.Name, DisplayName: builtin.DisplayName}
+		if err := DB.Where(Role{Name: builtin.Name}).FirstOrCreate(&role).Error; err != nil {
+			return errors.New("无法初始化角色")
+		}
+		rolePermissions := make([]Permission, 0, len(builtin.Permissions))
+		for _, name := range builtin.Permissions {
+			rolePermissions = append(rolePermissions, permissions[name])
+		}
+		if err := DB.Model(&role).Association("Permissions").Replace(rolePermissions); err != nil {
+			return errors.New("无法初始化角色权限")
+		}
+	}
+	return nil
+}
+
+// GetRoleByName 根据角色标识查询角色
+func GetRoleByName(name string) (Role, error) {
+	var role Role
+	if err := DB.Where("name = ?", name).First(&role).Error; err != nil {
+		return Role{}, errors.New("角色不存在")
+	}
+	return role, nil
+}
+
+// migrateAdminColumn 将旧的 admin 布尔字段迁移为角色，并为没有角色的用户设置默认角色
+func migrateAdminColumn() error {
+	superAdmin, err := GetRoleByName(RoleSuperAdmin)
+	if err != nil {
+		return err
+	}
+	volunteer, err := GetRoleByName(RoleVolunteer)
+	if err != nil {
+		return err
+	}
+	if DB.Migrator().HasColumn(&User{}, "admin") {
+		if err := DB.Model(&User{}).Where("admin = ?", true).Update("role_id", superAdmin.ID).Error; err != nil {
+			return errors.New("无法迁移管理员角色")
+		}
+		if err := DB.Migrator().DropColumn(&User{}, "admin"); err != nil {
+			return errors.New("无法删除旧的管理员字段")
+		}
+	}
+	if err := DB.Model(&User{}).Where("role_id = 0 OR role_id IS NULL").Update("role_id", volunteer.ID).Error; err != nil {
+		return errors.New("无法设置默认角色")
+	}
+	return nil
+}
+
+// GetRoleWithPermissions 根据角色ID查询角色及其权限
+func GetRoleWithPermissions(id uint) (Role, error) {
+	var role Role
+	if err := DB.Preload("Permissions").Where("id = ?", id).First(&role).Error; err != nil {
+		return Role{}, errors.New("角色不存在")
+	}
+	return role, nil
+}
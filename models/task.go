@@ -6,14 +6,16 @@ import (
 
 // Task 志愿活动
 type Task struct {
-	ID        uint      `gorm:"primaryKey"`
-	Name      string    `gorm:"not null"`               // 活动名称
-	CreatedAt time.Time `gorm:"type:datetime;not null"` // 活动创建时间
-	StartTime time.Time `gorm:"type:datetime;not null"` // 活动开始时间
-	EndTime   time.Time `gorm:"type:datetime;not null"` // 活动结束时间
-	Location  string    `gorm:"size:255"`               // 活动举行地点
-	Limit     uint      `gorm:"not null;default:0"`     // 限制人数
-	Joined    uint      `gorm:"default:0"`              // 已参加人数
+	ID             uint      `gorm:"primaryKey"`
+	OrganizationID uint      `gorm:"index;not null;default:0"` // 所属组织ID
+	Name           string    `gorm:"not null"`                 // 活动名称
+	CreatedAt      time.Time `gorm:"type:datetime;not null"`   // 活动创建时间
+	StartTime      time.Time `gorm:"type:datetime;not null"`   // 活动开始时间
+	EndTime        time.Time `gorm:"type:datetime;not null"`   // 活动结束时间
+	Location       string    `gorm:"size:255"`                 // 活动举行地点
+	Category       string    `gorm:"size:64;index"`            // 活动类别，例如支教、环保
+	Limit          uint      `gorm:"not null;default:0"`       // 限制人数
+	Joined         uint      `gorm:"default:0"`                // 已参加人数
 	// 新增关联关系
 	Participants []TaskParticipant `gorm:"foreignKey:TaskID"`
 }
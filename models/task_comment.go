@@ -0,0 +1,19 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// TaskComment 活动讨论区中的提问或回复，回复只挂在顶层提问下，形成两级的讨论串
+type TaskComment struct {
+	ID        uint           `gorm:"primaryKey"`
+	TaskID    uint           `gorm:"index;not null"`     // 所属活动ID
+	ParentID  uint           `gorm:"index;default:0"`    // 所属提问的ID，0表示顶层提问
+	UserID    uint           `gorm:"index;not null"`     // 发布者的用户ID
+	Content   string         `gorm:"type:text;not null"` // 内容
+	Pinned    bool           `gorm:"default:false"`      // 是否被管理员置顶
+	Hidden    bool           `gorm:"default:false"`      // 是否被管理员隐藏，隐藏后只有管理员可见
+	CreatedAt time.Time      // 发布时间
+	DeletedAt gorm.DeletedAt `gorm:"index"` // 删除时间
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// TaskCoordinator 表示任务的协调员，协调员只能管理被分配的任务
+type TaskCoordinator struct {
+	ID        uint      `gorm:"primaryKey"`
+	TaskID    uint      `gorm:"not null;uniqueIndex:idx_task_coordinator"` // 关联的任务ID
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_task_coordinator"` // 协调员的用户ID
+	CreatedAt time.Time // 分配时间
+}
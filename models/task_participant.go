@@ -1,12 +1,19 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"time"
+)
 
 // TaskParticipant 表示任务的已参加人员信息
 type TaskParticipant struct {
 	gorm.Model
-	TaskID   uint   `gorm:"not null"`           // 关联的任务ID
-	Nickname string `gorm:"not null"`           // 参加人员的用户名
-	Email    string `gorm:"not null"`           //参加人员的邮箱
-	Status   uint   `gorm:"not null default:3"` // 0表示待审核 1表示审核通过 2表示审核不通过 3表示未参加
+	TaskID           uint       `gorm:"not null;uniqueIndex:idx_task_participant"`          // 关联的任务ID
+	Nickname         string     `gorm:"not null"`                                           // 参加人员的用户名
+	Email            string     `gorm:"size:255;not null;uniqueIndex:idx_task_participant"` //参加人员的邮箱，同一用户对同一活动只能报名一次
+	Status           uint       `gorm:"not null default:3"`                                 // 0表示待审核 1表示审核通过 2表示审核不通过 3表示未参加
+	TeamID           uint       `gorm:"index;default:0"`                                    // 团队报名时关联的团队ID，个人报名为0
+	CheckInTime      *time.Time // 签到时间，未签到为空
+	ConfirmedMinutes uint       `gorm:"default:0"`     // 已确认的志愿时长（分钟）
+	HoursConfirmed   bool       `gorm:"default:false"` // 志愿时长是否已确认
 }
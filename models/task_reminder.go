@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// TaskReminder 活动开始前的提醒计划，每个活动的每个提醒时间点一条记录
+type TaskReminder struct {
+	ID            uint       `gorm:"primaryKey"`
+	TaskID        uint       `gorm:"not null;uniqueIndex:idx_task_reminder"` // 活动ID
+	OffsetMinutes int        `gorm:"not null;uniqueIndex:idx_task_reminder"` // 活动开始前多少分钟发送
+	RemindAt      time.Time  `gorm:"not null;index"`                         // 计划发送时间
+	SentAt        *time.Time // 实际发送时间，未发送为空
+	Recipients    uint       `gorm:"default:0"` // 接收人数
+	CreatedAt     time.Time  // 创建时间
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Team 志愿者团队（宿舍、社团等），可以整体报名活动
+type Team struct {
+	ID             uint      `gorm:"primaryKey"`
+	OrganizationID uint      `gorm:"index;not null"` // 所属组织ID
+	Name           string    `gorm:"not null"`       // 团队名称
+	LeaderID       uint      `gorm:"not null"`       // 队长的用户ID
+	CreatedAt      time.Time // 创建时间
+}
+
+// TeamMember 表示团队成员
+type TeamMember struct {
+	ID        uint      `gorm:"primaryKey"`
+	TeamID    uint      `gorm:"not null;uniqueIndex:idx_team_member"` // 关联的团队ID
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_team_member"` // 关联的用户ID
+	Status    uint      `gorm:"not null;default:0"`                   // 0表示已邀请 1表示已加入
+	CreatedAt time.Time // 邀请时间
+}
+
+// TeamRegistration 表示团队对活动的整体报名
+type TeamRegistration struct {
+	ID        uint      `gorm:"primaryKey"`
+	TeamID    uint      `gorm:"not null;uniqueIndex:idx_team_task"` // 关联的团队ID
+	TaskID    uint      `gorm:"not null;uniqueIndex:idx_team_task"` // 关联的任务ID
+	Slots     uint      `gorm:"not null"`                           // 占用的名额
+	Status    uint      `gorm:"not null;default:0"`                 // 0表示待审核 1表示审核通过 2表示审核不通过
+	CreatedAt time.Time // 报名时间
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"time"
 )
 
@@ -13,7 +14,36 @@ type User struct {
 	Avatar        string    `gorm:"default:null"`          // 头像地址
 	Phone         string    `gorm:"default:null"`          // 联系方式手机号码（默认+86）
 	CreatedAt     time.Time // 注册时间
-	Duration      uint      `gorm:"default:0"`     // 志愿时长（分钟）
-	Admin         bool      `gorm:"default:false"` // 是否管理员
+	Duration      uint      `gorm:"default:0"` // 志愿时长（分钟）
+	RoleID        uint      `gorm:"default:0"` // 角色ID
+	Skills        string    `gorm:"size:255"`  // 技能标签，多个标签以英文逗号分隔
 	LastLoginTime time.Time // 最近一次登录时间
+	TokenVersion  uint      `gorm:"default:0"` // 令牌版本，修改密码或角色时递增，之前签发的令牌随即失效
+
+	EmailVerifiedAt    *time.Time // 邮箱验证时间，为空表示邮箱尚未验证，不能登录
+	FailedLoginCount   uint       `gorm:"default:0"` // 上次登录成功后连续登录失败的次数
+	LockedUntil        *time.Time // 登录锁定的截止时间，为空表示未锁定
+	MustChangePassword bool       `gorm:"default:false"` // 使用一次性初始密码的管理员需要在首次登录后修改密码
+
+	TOTPSecret   string `gorm:"column:totp_secret;size:128"`       // 加密后的两步验证 TOTP 密钥，开启前为待确认的密钥
+	TOTPEnabled  bool   `gorm:"column:totp_enabled;default:false"` // 是否已开启两步验证
+	TOTPLastStep int64  `gorm:"column:totp_last_step;default:0"`   // 最近一次使用的验证码时间步，防止验证码被重放
+
+	Timezone        string `gorm:"size:64;default:'Asia/Shanghai'"` // 时区，用于计算免打扰时段和摘要发送时间
+	QuietHoursStart string `gorm:"size:5"`                          // 免打扰开始时间（HH:MM），为空表示不开启
+	QuietHoursEnd   string `gorm:"size:5"`                          // 免打扰结束时间（HH:MM）
+	DailyDigest     bool   `gorm:"default:false"`                   // 是否将非紧急通知合并为每日摘要
+	DigestHour      uint   `gorm:"default:8"`                       // 每日摘要的发送时间（小时）
+	Locale          string `gorm:"size:16;default:'zh-CN'"`         // 接收通知使用的语言
+}
+
+// migrateEmailVerified 邮箱验证上线前注册的用户视为已经验证，existing 表示迁移前用户表已存在但还没有验证字段
+func migrateEmailVerified(existing bool) error {
+	if !existing {
+		return nil
+	}
+	if err := DB.Model(&User{}).Where("email_verified_at IS NULL").Update("email_verified_at", time.Now().Local()).Error; err != nil {
+		return errors.New("无法迁移邮箱验证状态")
+	}
+	return nil
 }
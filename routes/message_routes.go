@@ -8,7 +8,7 @@ import (
 )
 
 // MessageRoutes 消息相关的路由
-func MessageRoutes(r *gin.Engine) {
+func MessageRoutes(r *gin.Engine, notifications services.NotificationService) {
 	//// 配置跨域中间件
 	//r.Use(cors.New(cors.Config{
 	//	AllowOrigins:     []string{"http://localhost:5173"}, // 前端地址
@@ -16,14 +16,22 @@ func MessageRoutes(r *gin.Engine) {
 	//	AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 	//	AllowCredentials: true,
 	//}))
-	messageService := services.NewMessageService()
+	messageService := services.NewMessageService(notifications)
 	messageController := controllers.NewMessageController(messageService)
 
+	// 消息的归属由 JWT 中的用户决定，发送权限由消息服务校验
 	messageGroup := r.Group("/message")
 	messageGroup.Use(middlewares.AuthMiddleware()) // 应用 JWT 中间件
 	{
-		messageGroup.GET("/:userID", messageController.GetMessagesByUserID)
+		messageGroup.GET("/me", messageController.GetMyMessages)
+		messageGroup.GET("/me/unread_count", messageController.GetUnreadCount)
+		messageGroup.PUT("/me/read_all", messageController.MarkAllAsRead)
+		messageGroup.PUT("/me/:messageID/read", messageController.MarkMessageAsRead)
+		messageGroup.DELETE("/me/:messageID", messageController.DeleteMessage)
 		messageGroup.POST("/", messageController.CreateMessage)
-		messageGroup.PUT("/:messageID/read", messageController.MarkMessageAsRead)
+		messageGroup.POST("/stream_ticket", messageController.CreateStreamTicket)
 	}
+
+	// 消息实时推送，EventSource 无法设置请求头，通过 ticket 查询参数传递一次性推送凭证
+	r.GET("/message/stream", middlewares.StreamAuthMiddleware(), messageController.StreamMessages)
 }
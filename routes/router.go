@@ -1,18 +1,29 @@
 package routes
 
 import (
+	"volunteer-system-backend/config"
 	"volunteer-system-backend/controllers"
 	"volunteer-system-backend/middlewares"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/services"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"log"
 	"time"
 )
 
-func SetupRouter() *gin.Engine {
+// SetupRouter 创建路由，notifications 注入到需要发出领域事件的服务中
+func SetupRouter(notifications services.NotificationService) *gin.Engine {
 	gin.SetMode(gin.DebugMode)
-	r := gin.Default()
+	r := gin.New()
+	// 请求日志隐藏地址中的推送凭证
+	r.Use(middlewares.Logger(), gin.Recovery())
+	// 只信任配置的反向代理转发的客户端IP，否则登录和重置密码的IP限制可以通过伪造 X-Forwarded-For 绕过
+	if err := r.SetTrustedProxies(config.ProjectConfig.Server.TrustedProxies); err != nil {
+		log.Fatalf("可信代理配置无效: %v", err)
+	}
 
 	//// 配置跨域中间件
 	//r.Use(cors.New(cors.Config{
@@ -22,61 +33,155 @@ func SetupRouter() *gin.Engine {
 	//	AllowCredentials: true,
 	//}))
 	// CORS 中间件配置
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:5173"} // 前端地址
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
-	config.AllowCredentials = true
-	config.ExposeHeaders = []string{"Content-Length"}
-	config.MaxAge = 12 * time.Hour
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = []string{"http://localhost:5173"} // 前端地址
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	corsConfig.AllowCredentials = true
+	corsConfig.ExposeHeaders = []string{"Content-Length"}
+	corsConfig.MaxAge = 12 * time.Hour
+
+	r.Use(cors.New(corsConfig))
 
-	r.Use(cors.New(config))
+	userController := controllers.NewUserController(services.NewUserService(notifications))
+	taskController := controllers.NewTaskController(services.NewTaskService(notifications))
+	teamController := controllers.NewTeamController(services.NewTeamService(notifications))
+	orgController := controllers.NewOrganizationController(services.NewOrganizationService(notifications))
+	commentController := controllers.NewCommentController(services.NewCommentService(notifications))
+	announcementController := controllers.NewAnnouncementController(services.NewAnnouncementService(notifications))
 
 	// Swagger API 文档路由
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 
+	// 访问令牌公钥，供其他服务校验令牌
+	r.GET("/.well-known/jwks.json", controllers.JWKS)
+
 	// 公共 API 路由（无需鉴权）
 	api := r.Group("/api")
 	{
-		api.POST("/register", controllers.RegisterUser) //用户注册
-		api.POST("/login", controllers.LoginUser)       //用户登录
+		api.POST("/register", userController.RegisterUser)               //用户注册
+		api.POST("/login", controllers.LoginUser)                        //用户登录
+		api.POST("/login_2fa", controllers.LoginTwoFactor)               //两步验证登录
+		api.POST("/refresh", controllers.RefreshToken)                   //刷新令牌
+		api.POST("/forgot_password", controllers.ForgotPassword)         //找回密码
+		api.POST("/reset_password", controllers.ResetPassword)           //重置密码
+		api.POST("/verify_email", controllers.VerifyEmail)               //验证邮箱
+		api.POST("/resend_verification", controllers.ResendVerification) //重新发送验证邮件
+		api.GET("/oidc/authorize", controllers.OIDCAuthorize)            //发起统一身份认证登录
+		api.POST("/oidc/callback", controllers.OIDCCallback)             //统一身份认证登录回调
 	}
 
 	user := r.Group("/user")
 	user.Use(middlewares.AuthMiddleware())
 	{
-		user.GET("/profile", controllers.GetUserProfile)            // 获取用户信息
-		user.GET("/volunteer_count", controllers.GetVolunteerCount) // 统计志愿者用户个数
-		user.POST("/upload_avatar", controllers.UploadAvatar)       // 新增上传头像接口
-		user.PUT("/change_password", controllers.ChangePassword)    // 修改密码
-		user.PUT("/update_profile", controllers.UpdateUserInfo)     // 更新用户信息
+		user.GET("/profile", controllers.GetUserProfile)                                                                     // 获取用户信息
+		user.GET("/volunteer_count", middlewares.RequirePermission(models.PermVolunteerRead), controllers.GetVolunteerCount) // 统计志愿者用户个数
+		user.POST("/upload_avatar", controllers.UploadAvatar)                                                                // 新增上传头像接口
+		user.PUT("/change_password", userController.ChangePassword)                                                          // 修改密码
+		user.PUT("/update_profile", controllers.UpdateUserInfo)                                                              // 更新用户信息
+		user.GET("/organizations", orgController.GetUserOrganizations)                                                       // 获取加入的组织
+		user.POST("/switch_org", orgController.SwitchOrganization)                                                           // 切换当前组织
+		user.POST("/join_org", orgController.JoinOrganization)                                                               // 凭邀请码加入组织
+		user.GET("/org_invitations", orgController.GetOrganizationInvitations)                                               // 获取组织邀请
+		user.POST("/respond_org_invitation", orgController.RespondOrganizationInvitation)                                    // 答复组织邀请
+		user.GET("/notification_preferences", controllers.GetNotificationPreferences)                                        // 获取通知偏好
+		user.PUT("/notification_preferences", controllers.UpdateNotificationPreferences)                                     // 更新通知偏好
+		user.POST("/logout", controllers.Logout)                                                                             // 退出登录
+		user.POST("/logout_all", controllers.LogoutAll)                                                                      // 退出所有设备
+		user.POST("/2fa/setup", controllers.SetupTwoFactor)                                                                  // 获取两步验证密钥
+		user.POST("/2fa/enable", controllers.EnableTwoFactor)                                                                // 开启两步验证
+		user.POST("/2fa/disable", controllers.DisableTwoFactor)                                                              // 关闭两步验证
+		user.POST("/2fa/recovery_codes", controllers.RegenerateRecoveryCodes)                                                // 重新生成恢复码
 	}
-	// 需要 JWT 鉴权的路由
+	// 需要 JWT 鉴权的路由，每个路由声明所需的权限
+	// 审核、签到和时长确认等按活动划分的权限由控制器校验
 	task := r.Group("/task")
 	task.Use(middlewares.AuthMiddleware()) // 应用 JWT 中间件
 	{
-		task.GET("/tasks", controllers.GetTasks)                // 获取志愿活动列表
-		task.GET("/getTaskStatus", controllers.GetTaskStatus)   // 获取任务状态
-		task.GET("/getTaskDetails", controllers.GetTaskDetails) // 获取任务详情
-		task.POST("/join", controllers.JoinTask)                // 参加志愿者活动
+		task.GET("/tasks", middlewares.RequirePermission(models.PermTaskRead), taskController.GetTasks)                               // 获取志愿活动列表
+		task.GET("/getTaskStatus", middlewares.RequirePermission(models.PermTaskRead), taskController.GetTaskStatus)                  // 获取任务状态
+		task.GET("/getTaskDetails", middlewares.RequirePermission(models.PermTaskRead), taskController.GetTaskDetails)                // 获取任务详情
+		task.POST("/join", middlewares.RequirePermission(models.PermTaskJoin), taskController.JoinTask)                               // 参加志愿者活动
+		task.POST("/create_task", middlewares.RequirePermission(models.PermTaskCreate), taskController.CreateTask)                    // 创建志愿活动
+		task.POST("/update", middlewares.RequirePermission(models.PermTaskUpdate), taskController.UpdateTask)                         // 修改志愿活动
+		task.DELETE("/delete_task", middlewares.RequirePermission(models.PermTaskDelete), taskController.DeleteTask)                  // 删除志愿活动
+		task.GET("/coordinated", middlewares.RequirePermission(models.PermTaskCoordinate), taskController.GetCoordinatorTasks)        // 获取负责协调的活动
+		task.GET("/coordinators", middlewares.RequirePermission(models.PermCoordinatorAdmin), taskController.GetTaskCoordinators)     // 获取活动协调员列表
+		task.POST("/assignCoordinator", middlewares.RequirePermission(models.PermCoordinatorAdmin), taskController.AssignCoordinator) // 分配活动协调员
+		task.POST("/removeCoordinator", middlewares.RequirePermission(models.PermCoordinatorAdmin), taskController.RemoveCoordinator) // 移除活动协调员
+		task.POST("/GetTaskAuditDetail", taskController.GetTaskAuditDetail)                                                           //获取任务报名详情
+		task.POST("/approveVolunteer", taskController.ApproveVolunteer)                                                               //审核通过
+		task.POST("/rejectVolunteer", taskController.RejectVolunteer)                                                                 //审核拒绝
+		task.POST("/checkInVolunteer", taskController.CheckInVolunteer)                                                               // 报名人签到
+		task.POST("/confirmHours", taskController.ConfirmVolunteerHours)                                                              // 确认志愿时长
+		task.POST("/approveTeam", teamController.ApproveTeam)                                                                         // 通过团队报名审核
+		task.POST("/rejectTeam", teamController.RejectTeam)                                                                           // 拒绝团队报名审核
+		task.GET("/comments", middlewares.RequirePermission(models.PermTaskRead), commentController.GetTaskComments)                  // 获取活动讨论
+		task.POST("/comment", middlewares.RequirePermission(models.PermTaskRead), commentController.CreateTaskComment)                // 发布活动讨论
+		task.POST("/pinComment", commentController.PinTaskComment)                                                                    // 置顶活动讨论
+		task.POST("/hideComment", commentController.HideTaskComment)                                                                  // 隐藏活动讨论
+		task.DELETE("/deleteComment", commentController.DeleteTaskComment)                                                            // 删除活动讨论
+	}
+
+	// 团队路由
+	team := r.Group("/team")
+	team.Use(middlewares.AuthMiddleware())
+	{
+		team.GET("/my", teamController.GetUserTeams)                                                               // 获取我的团队
+		team.POST("/create", teamController.CreateTeam)                                                            // 创建团队
+		team.POST("/invite", teamController.InviteTeamMember)                                                      // 邀请团队成员
+		team.POST("/respond", teamController.RespondTeamInvitation)                                                // 答复团队邀请
+		team.POST("/join_task", middlewares.RequirePermission(models.PermTaskJoin), teamController.JoinTaskAsTeam) // 团队报名活动
+	}
+
+	// 公告路由
+	announcement := r.Group("/announcement")
+	announcement.Use(middlewares.AuthMiddleware(), middlewares.RequirePermission(models.PermAnnouncement))
+	{
+		announcement.POST("/create", announcementController.CreateAnnouncement) // 发布公告
+		announcement.GET("/list", announcementController.GetAnnouncements)      // 获取公告列表及阅读情况
+	}
+
+	// 私信路由，作用于当前所在组织
+	conversation := r.Group("/conversation")
+	conversation.Use(middlewares.AuthMiddleware())
+	{
+		conversation.GET("/list", controllers.GetConversations)      // 获取我的私信会话
+		conversation.POST("/start", controllers.StartConversation)   // 发起私信会话
+		conversation.POST("/send", controllers.SendDirectMessage)    // 发送私信
+		conversation.GET("/messages", controllers.GetDirectMessages) // 获取会话中的私信
+		conversation.POST("/read", controllers.MarkConversationRead) // 标记会话已读
+	}
+	moderation := conversation.Group("/moderation")
+	moderation.Use(middlewares.RequirePermission(models.PermMessageModerate))
+	{
+		moderation.GET("/list", controllers.GetOrgConversations)            // 获取组织内的私信会话
+		moderation.GET("/messages", controllers.GetModeratedDirectMessages) // 查看会话中的私信
+		moderation.POST("/hide", controllers.HideDirectMessage)             // 屏蔽私信
+		moderation.DELETE("/delete", controllers.DeleteConversation)        // 删除会话
+	}
+
+	// 组织成员管理路由，作用于当前所在组织
+	org := r.Group("/org")
+	org.Use(middlewares.AuthMiddleware(), middlewares.RequirePermission(models.PermOrgMember))
+	{
+		org.POST("/invite_member", orgController.InviteOrganizationMember) // 邀请组织成员
+		org.POST("/remove_member", orgController.RemoveOrganizationMember) // 移除组织成员
 	}
 
-	//管理员特定操作路由
+	// 平台管理路由
 	admin := r.Group("/admin")
-	admin.Use(middlewares.AdminMiddleware()) // 应用管理员中间件
+	admin.Use(middlewares.AuthMiddleware())
 	{
-		admin.GET("/volunteer_count", controllers.GetVolunteerCount)      // 统计志愿者用户个数
-		admin.GET("/profile", controllers.GetUserProfile)                 // 获取用户信息
-		admin.GET("/tasks", controllers.GetTasks)                         // 获取志愿活动列表
-		admin.GET("/getTaskDetails", controllers.GetTaskDetails)          // 获取任务详情
-		admin.GET("/getTaskStatus", controllers.GetTaskStatus)            // 获取任务状态
-		admin.POST("/GetTaskAuditDetail", controllers.GetTaskAuditDetail) //获取任务报名详情
-		admin.POST("/approveVolunteer", controllers.ApproveVolunteer)     //审核通过
-		admin.POST("/rejectVolunteer", controllers.RejectVolunteer)       //审核拒绝
-		admin.POST("/create_task", controllers.CreateTask)                // 创建志愿活动
-		admin.POST("/update", controllers.UpdateTask)                     // 修改志愿活动
-		admin.POST("/join", controllers.JoinTask)                         // 参加志愿者活动
-		admin.DELETE("/delete_task", controllers.DeleteTask)              // 删除志愿活动
+		admin.GET("/roles", middlewares.RequirePermission(models.PermRoleAssign), controllers.GetRoles)                                        // 获取角色列表
+		admin.POST("/assign_role", middlewares.RequirePermission(models.PermRoleAssign), controllers.AssignRole)                               // 分配用户角色
+		admin.POST("/verify_email", middlewares.RequirePermission(models.PermRoleAssign), controllers.AdminVerifyEmail)                        // 验证用户邮箱
+		admin.POST("/unlock_user", middlewares.RequirePermission(models.PermRoleAssign), controllers.UnlockUser)                               // 解除账户登录锁定
+		admin.POST("/reset_2fa", middlewares.RequirePermission(models.PermRoleAssign), controllers.ResetTwoFactor)                             // 重置用户两步验证
+		admin.POST("/create_org", middlewares.RequirePermission(models.PermOrgCreate), orgController.CreateOrganization)                       // 创建组织
+		admin.GET("/message_templates", middlewares.RequirePermission(models.PermTemplateManage), controllers.GetMessageTemplates)             // 获取消息模板
+		admin.PUT("/message_templates", middlewares.RequirePermission(models.PermTemplateManage), controllers.UpdateMessageTemplate)           // 修改消息模板
+		admin.POST("/message_templates/preview", middlewares.RequirePermission(models.PermTemplateManage), controllers.PreviewMessageTemplate) // 预览消息模板
 	}
 
 	return r
@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"volunteer-system-backend/config"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func clientIPFor(t *testing.T, trustedProxies []string) string {
+	t.Helper()
+	saved := config.ProjectConfig
+	defer func() { config.ProjectConfig = saved }()
+	config.ProjectConfig = &config.Config{}
+	config.ProjectConfig.Server.TrustedProxies = trustedProxies
+
+	r := SetupRouter(nil)
+	r.GET("/test/client_ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+	req := httptest.NewRequest(http.MethodGet, "/test/client_ip", nil)
+	req.RemoteAddr = "10.0.0.2:40000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Body.String()
+}
+
+func TestClientIPIgnoresForwardedHeaderFromUntrustedPeer(t *testing.T) {
+	if ip := clientIPFor(t, nil); ip != "10.0.0.2" {
+		t.Fatalf("未配置可信代理时应使用连接的来源IP，实际为 %s", ip)
+	}
+	if ip := clientIPFor(t, []string{"10.0.0.0/8"}); ip != "203.0.113.7" {
+		t.Fatalf("来自可信代理的请求应使用 X-Forwarded-For 中的IP，实际为 %s", ip)
+	}
+}
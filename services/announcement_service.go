@@ -0,0 +1,251 @@
+package services
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/utils"
+	"errors"
+	"gorm.io/gorm"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// announcementBatchSize 公告展开为消息时每批写入的条数
+const announcementBatchSize = 500
+
+// AnnouncementService 发布公告，到发送时间的公告立即展开为消息
+type AnnouncementService struct {
+	notifications NotificationService
+}
+
+// NewAnnouncementService 创建公告服务
+func NewAnnouncementService(notifications NotificationService) *AnnouncementService {
+	return &AnnouncementService{notifications: notifications}
+}
+
+// CreateAnnouncement 创建公告，发送时间为空或已到时立即发送
+func (s *AnnouncementService) CreateAnnouncement(orgID uint, creatorEmail string, input dto.CreateAnnouncementRequest) (dto.AnnouncementInfo, error) {
+	creatorId, err := GetUserIDByEmail(creatorEmail)
+	if err != nil {
+		return dto.AnnouncementInfo{}, err
+	}
+	switch input.AudienceType {
+	case models.AudienceAll:
+	case models.AudienceTask:
+		taskId, err := strconv.Atoi(input.AudienceValue)
+		if err != nil {
+			return dto.AnnouncementInfo{}, errors.New("受众活动ID无效")
+		}
+		if _, err := getOrgTask(orgID, uint(taskId)); err != nil {
+			return dto.AnnouncementInfo{}, err
+		}
+	case models.AudienceSkill:
+		if strings.TrimSpace(input.AudienceValue) == "" {
+			return dto.AnnouncementInfo{}, errors.New("受众技能标签不能为空")
+		}
+	case models.AudienceCategory:
+		if strings.TrimSpace(input.AudienceValue) == "" {
+			return dto.AnnouncementInfo{}, errors.New("受众活动类别不能为空")
+		}
+	default:
+		return dto.AnnouncementInfo{}, errors.New("无效的受众类型")
+	}
+
+	now := time.Now().Local()
+	sendAt := now
+	if input.SendAt != "" {
+		sendAt = utils.FormatStr2Time(input.SendAt)
+	}
+	announcement := models.Announcement{
+		OrganizationID: orgID,
+		Title:          input.Title,
+		Content:        input.Content,
+		AudienceType:   input.AudienceType,
+		AudienceValue:  strings.TrimSpace(input.AudienceValue),
+		SendAt:         sendAt,
+		CreatedBy:      creatorId,
+		CreatedAt:      now,
+	}
+	if input.ExpiresAt != "" {
+		expiresAt := utils.FormatStr2Time(input.ExpiresAt)
+		if !expiresAt.After(sendAt) {
+			return dto.AnnouncementInfo{}, errors.New("过期时间必须晚于发送时间")
+		}
+		announcement.ExpiresAt = &expiresAt
+	}
+	if err := models.DB.Create(&announcement).Error; err != nil {
+		return dto.AnnouncementInfo{}, errors.New("无法创建公告")
+	}
+
+	if !sendAt.After(now) {
+		if err := dispatchAnnouncement(s.notifications, announcement.ID); err != nil {
+			return dto.AnnouncementInfo{}, err
+		}
+		if err := models.DB.First(&announcement, announcement.ID).Error; err != nil {
+			return dto.AnnouncementInfo{}, err
+		}
+	}
+	return toAnnouncementInfo(announcement, 0), nil
+}
+
+// GetAnnouncements 获取组织内的公告及阅读情况
+func GetAnnouncements(orgID uint) ([]dto.AnnouncementInfo, error) {
+	var announcements []models.Announcement
+	if err := models.DB.Where("organization_id = ?", orgID).Order("send_at DESC").Find(&announcements).Error; err != nil {
+		return nil, err
+	}
+	infos := make([]dto.AnnouncementInfo, len(announcements))
+	for i, announcement := range announcements {
+		readCount, err := countAnnouncementReads(announcement.ID)
+		if err != nil {
+			return nil, err
+		}
+		infos[i] = toAnnouncementInfo(announcement, readCount)
+	}
+	return infos, nil
+}
+
+// countAnnouncementReads 统计公告的已读人数，接收者删除的已读消息同样计入
+func countAnnouncementReads(announcementId uint) (int64, error) {
+	var count int64
+	err := models.DB.Unscoped().Model(&models.Message{}).
+		Where("announcement_id = ? AND status = ?", announcementId, "read").
+		Count(&count).Error
+	return count, err
+}
+
+func toAnnouncementInfo(announcement models.Announcement, readCount int64) dto.AnnouncementInfo {
+	info := dto.AnnouncementInfo{
+		ID:            announcement.ID,
+		Title:         announcement.Title,
+		Content:       announcement.Content,
+		AudienceType:  announcement.AudienceType,
+		AudienceValue: announcement.AudienceValue,
+		SendAt:        utils.FormatTime2Str(announcement.SendAt),
+		Recipients:    announcement.Recipients,
+		ReadCount:     readCount,
+	}
+	if announcement.ExpiresAt != nil {
+		info.ExpiresAt = utils.FormatTime2Str(*announcement.ExpiresAt)
+	}
+	if announcement.SentAt != nil {
+		info.SentAt = utils.FormatTime2Str(*announcement.SentAt)
+	}
+	return info
+}
+
+// announcementAudience 查询公告的接收者ID
+func announcementAudience(tx *gorm.DB, announcement models.Announcement) ([]uint, error) {
+	var userIds []uint
+	members := tx.Model(&models.OrganizationMember{}).Where("organization_id = ?", announcement.OrganizationID)
+	switch announcement.AudienceType {
+	case models.AudienceAll:
+		if err := members.Pluck("user_id", &userIds).Error; err != nil {
+			return nil, err
+		}
+	case models.AudienceTask:
+		if err := tx.Table("task_participants").
+			Joins("JOIN users ON users.email = task_participants.email").
+			Where("task_participants.task_id = ? AND task_participants.status = 1 AND task_participants.deleted_at IS NULL", announcement.AudienceValue).
+			Distinct().Pluck("users.id", &userIds).Error; err != nil {
+			return nil, err
+		}
+	case models.AudienceSkill:
+		if err := members.Joins("JOIN users ON users.id = organization_members.user_id").
+			Where("FIND_IN_SET(?, users.skills) > 0", announcement.AudienceValue).
+			Pluck("organization_members.user_id", &userIds).Error; err != nil {
+			return nil, err
+		}
+	case models.AudienceCategory:
+		// 只选择仍是组织成员、且在本组织该类别活动中审核通过的报名人
+		if err := tx.Model(&models.OrganizationMember{}).
+			Joins("JOIN users ON users.id = organization_members.user_id").
+			Joins("JOIN task_participants ON task_participants.email = users.email AND task_participants.status = 1 AND task_participants.deleted_at IS NULL").
+			Joins("JOIN tasks ON tasks.id = task_participants.task_id AND tasks.organization_id = organization_members.organization_id").
+			Where("organization_members.organization_id = ? AND tasks.category = ?", announcement.OrganizationID, announcement.AudienceValue).
+			Distinct().Pluck("organization_members.user_id", &userIds).Error; err != nil {
+			return nil, err
+		}
+	}
+	return userIds, nil
+}
+
+// dispatchAnnouncement 将公告展开为每个接收者的消息
+// 通过条件更新 sent_at 认领公告，并与消息和邮件发件箱的写入放在同一事务中，保证公告只会被发送一次
+func dispatchAnnouncement(notifications NotificationService, announcementId uint) error {
+	push := func() {}
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().Local()
+		result := tx.Model(&models.Announcement{}).Where("id = ? AND sent_at IS NULL", announcementId).Update("sent_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		var announcement models.Announcement
+		if err := tx.First(&announcement, announcementId).Error; err != nil {
+			return err
+		}
+		userIds, err := announcementAudience(tx, announcement)
+		if err != nil {
+			return err
+		}
+		messages := make([]models.Message, len(userIds))
+		for i, userId := range userIds {
+			messages[i] = models.Message{
+				UserID:         userId,
+				OrganizationID: announcement.OrganizationID,
+				Category:       models.MessageCategoryAnnouncement,
+				Title:          announcement.Title,
+				Content:        announcement.Content,
+				Time:           now,
+				Status:         "unread",
+				AnnouncementID: announcement.ID,
+				ExpiresAt:      announcement.ExpiresAt,
+			}
+		}
+		if _, push, err = notifications.SendTx(tx, messages); err != nil {
+			return errors.New("无法发送公告")
+		}
+		return tx.Model(&announcement).Update("recipients", len(messages)).Error
+	})
+	if err != nil {
+		return err
+	}
+	push()
+	return nil
+}
+
+// DispatchDueAnnouncements 发送所有已到发送时间且未过期的公告
+func DispatchDueAnnouncements(notifications NotificationService) error {
+	now := time.Now().Local()
+	var ids []uint
+	if err := models.DB.Model(&models.Announcement{}).
+		Where("sent_at IS NULL AND send_at <= ?", now).
+		Where("(expires_at IS NULL OR expires_at > ?)", now).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := dispatchAnnouncement(notifications, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartAnnouncementDispatcher 启动定时发送公告的后台任务
+func StartAnnouncementDispatcher(interval time.Duration, notifications NotificationService) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := DispatchDueAnnouncements(notifications); err != nil {
+				log.Println("发送定时公告失败:", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"volunteer-system-backend/models"
+	"testing"
+)
+
+func TestCategoryAudienceSelectsApprovedParticipants(t *testing.T) {
+	setupTestDB(t)
+	orgID := createTestOrg(t, "环保协会")
+	otherOrgID := createTestOrg(t, "支教协会")
+	approved := createTestUser(t, "approved@example.com")
+	pending := createTestUser(t, "pending@example.com")
+	otherCategory := createTestUser(t, "other@example.com")
+	otherOrg := createTestUser(t, "outsider@example.com")
+	for _, user := range []*models.User{approved, pending, otherCategory, otherOrg} {
+		addTestMember(t, orgID, user, models.RoleVolunteer)
+	}
+
+	cleanup := createTestTask(t, orgID, "河道清理", 10)
+	teaching := createTestTask(t, orgID, "周末支教", 10)
+	// 另一个组织的同类活动不计入本组织的受众
+	otherOrgTask := createTestTask(t, otherOrgID, "外部河道清理", 10)
+	for _, task := range []*models.Task{cleanup, otherOrgTask} {
+		if err := models.DB.Model(task).Update("category", "环保").Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	participants := []models.TaskParticipant{
+		{TaskID: cleanup.ID, Nickname: "approved", Email: approved.Email, Status: 1},
+		{TaskID: cleanup.ID, Nickname: "pending", Email: pending.Email, Status: 0},
+		{TaskID: teaching.ID, Nickname: "other", Email: otherCategory.Email, Status: 1},
+		{TaskID: otherOrgTask.ID, Nickname: "outsider", Email: otherOrg.Email, Status: 1},
+	}
+	if err := models.DB.Create(&participants).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	userIds, err := announcementAudience(models.DB, models.Announcement{
+		OrganizationID: orgID,
+		AudienceType:   models.AudienceCategory,
+		AudienceValue:  "环保",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(userIds) != 1 || userIds[0] != approved.ID {
+		t.Fatalf("expected only user %d, got %v", approved.ID, userIds)
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"volunteer-system-backend/models"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// HasSuperAdmin 判断系统中是否已经存在可用的超级管理员
+// 需要修改密码的超级管理员不计入，升级时被停用默认密码的 admin@admin.com 没有人知道密码，不能阻止创建新的超级管理员
+func HasSuperAdmin() (bool, error) {
+	role, err := models.GetRoleByName(models.RoleSuperAdmin)
+	if err != nil {
+		return false, err
+	}
+	var count int64
+	if err := models.DB.Model(&models.User{}).Where("role_id = ? AND must_change_password = ?", role.ID, false).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// BootstrapAdmin 首次部署时创建初始的超级管理员，系统中已经存在可用的超级管理员时拒绝创建
+// 未提供密码时生成一次性的随机密码并返回，使用该密码登录后必须先修改密码
+func BootstrapAdmin(email, nickname, password string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", errors.New("管理员邮箱不能为空")
+	}
+	exists, err := HasSuperAdmin()
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", errors.New("系统中已经存在超级管理员，请使用已有的管理员账号分配角色")
+	}
+	if _, err := GetUserIDByEmail(email); err == nil {
+		return "", errors.New("该邮箱已被注册")
+	}
+
+	generated := ""
+	if password == "" {
+		buf := make([]byte, 12)
+		if _, err := rand.Read(buf); err != nil {
+			return "", errors.New("无法生成随机密码")
+		}
+		generated = base64.RawURLEncoding.EncodeToString(buf)
+		password = generated
+	} else if err := validatePassword(password, email, ""); err != nil {
+		return "", err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", errors.New("无法对密码进行哈希处理")
+	}
+	superAdmin, err := models.GetRoleByName(models.RoleSuperAdmin)
+	if err != nil {
+		return "", err
+	}
+	orgAdmin, err := models.GetRoleByName(models.RoleOrgAdmin)
+	if err != nil {
+		return "", err
+	}
+	if nickname == "" {
+		nickname = "管理员"
+	}
+	now := time.Now().Local()
+	user := models.User{
+		Email:              email,
+		Nickname:           nickname,
+		Gender:             "保密",
+		Password:           string(hashedPassword),
+		CreatedAt:          now,
+		RoleID:             superAdmin.ID,
+		LastLoginTime:      now,
+		EmailVerifiedAt:    &now,
+		MustChangePassword: generated != "",
+	}
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return errors.New("无法创建管理员用户")
+		}
+		// 超级管理员在默认组织内以组织管理员身份出现
+		var org models.Organization
+		if err := tx.Where("name = ?", models.DefaultOrganizationName).First(&org).Error; err != nil {
+			return nil
+		}
+		return tx.Create(&models.OrganizationMember{
+			OrganizationID: org.ID,
+			UserID:         user.ID,
+			RoleID:         orgAdmin.ID,
+			CreatedAt:      now,
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return generated, nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"volunteer-system-backend/models"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+	"time"
+)
+
+func TestMigrateDisablesLegacyAdminPassword(t *testing.T) {
+	setupTestDB(t)
+	admin := createTestUser(t, "admin@admin.com")
+	hashed, err := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.DB.Model(admin).Update("password", string(hashed)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := models.DB.Create(&models.RefreshToken{UserID: admin.ID, TokenHash: hashToken("legacy"), FamilyID: "legacy", ExpiresAt: admin.CreatedAt.AddDate(1, 0, 0)}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := models.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoginUser("admin@admin.com", "123456", "192.0.2.1"); err == nil {
+		t.Fatal("迁移后默认管理员不能再使用默认密码登录")
+	}
+	var saved models.User
+	if err := models.DB.First(&saved, admin.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.TokenVersion != admin.TokenVersion+1 || !saved.MustChangePassword {
+		t.Fatalf("应使已签发的令牌失效并要求修改密码，实际令牌版本 %d，需要修改密码 %v", saved.TokenVersion, saved.MustChangePassword)
+	}
+	var active int64
+	models.DB.Model(&models.RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", admin.ID).Count(&active)
+	if active != 0 {
+		t.Fatalf("默认管理员的刷新令牌应全部撤销，仍有 %d 个有效", active)
+	}
+}
+
+func TestBootstrapAdminAfterUpgradingLegacyAdmin(t *testing.T) {
+	setupTestDB(t)
+	volunteer, err := models.GetRoleByName(models.RoleVolunteer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte("123456"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 旧版本的用户表使用 admin 字段标记管理员，默认管理员的密码为 123456
+	if err := models.DB.Exec("ALTER TABLE users ADD COLUMN admin boolean DEFAULT false").Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Local()
+	legacy := models.User{Email: "admin@admin.com", Nickname: "admin", Password: string(hashed), RoleID: volunteer.ID,
+		CreatedAt: now, LastLoginTime: now, EmailVerifiedAt: &now}
+	if err := models.DB.Create(&legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := models.DB.Exec("UPDATE users SET admin = ? WHERE id = ?", true, legacy.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := models.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	var upgraded models.User
+	if err := models.DB.First(&upgraded, legacy.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	superAdmin, err := models.GetRoleByName(models.RoleSuperAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upgraded.RoleID != superAdmin.ID || !upgraded.MustChangePassword {
+		t.Fatalf("默认管理员应迁移为超级管理员并停用默认密码: %+v", upgraded)
+	}
+
+	password, err := BootstrapAdmin("root@example.com", "", "")
+	if err != nil {
+		t.Fatalf("默认密码被停用后应能创建新的超级管理员: %v", err)
+	}
+	if _, err := LoginUser("root@example.com", password, "192.0.2.1"); err != nil {
+		t.Fatalf("应能使用生成的密码登录新的超级管理员: %v", err)
+	}
+	if err := models.DB.Model(&models.User{}).Where("email = ?", "root@example.com").Update("must_change_password", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := BootstrapAdmin("other@example.com", "", ""); err == nil {
+		t.Fatal("已经存在可用的超级管理员时不能再次创建")
+	}
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/utils"
+	"errors"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+)
+
+// commentPreviewLength 回复通知中引用回复内容的最大长度
+const commentPreviewLength = 100
+
+// getOrgComment 查询属于指定组织活动的讨论
+func getOrgComment(orgID uint, commentId uint) (models.TaskComment, error) {
+	var comment models.TaskComment
+	if err := models.DB.Joins("JOIN tasks ON tasks.id = task_comments.task_id").
+		Where("task_comments.id = ? AND tasks.organization_id = ?", commentId, orgID).
+		First(&comment).Error; err != nil {
+		return models.TaskComment{}, errors.New("讨论不存在")
+	}
+	return comment, nil
+}
+
+// canPostComment 判断用户是否可以在活动讨论区发言，报名了该活动的用户和可以管理该活动的用户可以发言
+func canPostComment(email string, orgID uint, taskId uint) (bool, error) {
+	var count int64
+	if err := models.DB.Model(&models.TaskParticipant{}).
+		Where("task_id = ? AND email = ? AND status IN ?", taskId, email, []uint{0, 1}).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	return CanManageTask(email, orgID, taskId)
+}
+
+// GetCommentTaskID 获取讨论所属的活动ID
+func GetCommentTaskID(orgID uint, commentId uint) (uint, error) {
+	comment, err := getOrgComment(orgID, commentId)
+	if err != nil {
+		return 0, err
+	}
+	return comment.TaskID, nil
+}
+
+// GetTaskComments 获取活动的讨论，置顶的提问和回复排在前面，被隐藏的讨论只有可以管理该活动的用户可见
+func GetTaskComments(orgID uint, email string, taskId uint) ([]dto.TaskCommentInfo, error) {
+	if _, err := getOrgTask(orgID, taskId); err != nil {
+		return nil, err
+	}
+	moderator, err := CanManageTask(email, orgID, taskId)
+	if err != nil {
+		return nil, err
+	}
+	query := models.DB.Table("task_comments").
+		Select("task_comments.*, users.nickname, users.avatar").
+		Joins("JOIN users ON users.id = task_comments.user_id").
+		Where("task_comments.task_id = ? AND task_comments.deleted_at IS NULL", taskId)
+	if !moderator {
+		query = query.Where("task_comments.hidden = ?", false)
+	}
+	var rows []struct {
+		models.TaskComment
+		Nickname string
+		Avatar   string
+	}
+	if err := query.Order("task_comments.pinned DESC").Order("task_comments.id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var threads []dto.TaskCommentInfo
+	replies := make(map[uint][]dto.TaskCommentInfo)
+	for _, row := range rows {
+		info := dto.TaskCommentInfo{
+			ID:        row.ID,
+			ParentID:  row.ParentID,
+			UserID:    row.UserID,
+			Nickname:  row.Nickname,
+			Avatar:    row.Avatar,
+			Content:   row.Content,
+			Pinned:    row.Pinned,
+			Hidden:    row.Hidden,
+			CreatedAt: utils.FormatTime2Str(row.CreatedAt),
+		}
+		if row.ParentID == 0 {
+			threads = append(threads, info)
+		} else {
+			replies[row.ParentID] = append(replies[row.ParentID], info)
+		}
+	}
+	for i := range threads {
+		threads[i].Replies = replies[threads[i].ID]
+	}
+	// 提问按置顶优先、发布时间倒序排列，回复按置顶优先、发布时间正序排列
+	sort.SliceStable(threads, func(i, j int) bool {
+		if threads[i].Pinned != threads[j].Pinned {
+			return threads[i].Pinned
+		}
+		return threads[i].ID > threads[j].ID
+	})
+	return threads, nil
+}
+
+// CommentService 发布活动讨论，回复会通知讨论串中的其他参与者
+type CommentService struct {
+	notifications NotificationService
+}
+
+// NewCommentService 创建活动讨论服务
+func NewCommentService(notifications NotificationService) *CommentService {
+	return &CommentService{notifications: notifications}
+}
+
+// CreateTaskComment 在活动讨论区发布提问或回复，回复会通知该讨论串中的其他参与者
+func (s *CommentService) CreateTaskComment(orgID uint, email string, input dto.TaskCommentRequest) (dto.TaskCommentInfo, error) {
+	task, err := getOrgTask(orgID, input.TaskId)
+	if err != nil {
+		return dto.TaskCommentInfo{}, err
+	}
+	content := strings.TrimSpace(input.Content)
+	if content == "" {
+		return dto.TaskCommentInfo{}, errors.New("内容不能为空")
+	}
+	ok, err := canPostComment(email, orgID, task.ID)
+	if err != nil {
+		return dto.TaskCommentInfo{}, err
+	}
+	if !ok {
+		return dto.TaskCommentInfo{}, errors.New("只有报名该活动的用户和活动管理员可以参与讨论")
+	}
+	user, err := GetUserProfile(email)
+	if err != nil {
+		return dto.TaskCommentInfo{}, err
+	}
+
+	// 回复统一挂在顶层提问下
+	var parent models.TaskComment
+	if input.ParentID != 0 {
+		if err := models.DB.Where("id = ? AND task_id = ?", input.ParentID, task.ID).First(&parent).Error; err != nil {
+			return dto.TaskCommentInfo{}, errors.New("回复的讨论不存在")
+		}
+		if parent.ParentID != 0 {
+			if err := models.DB.First(&parent, parent.ParentID).Error; err != nil {
+				return dto.TaskCommentInfo{}, errors.New("回复的讨论不存在")
+			}
+		}
+	}
+	comment := models.TaskComment{
+		TaskID:   task.ID,
+		ParentID: parent.ID,
+		UserID:   user.ID,
+		Content:  content,
+	}
+
+	push := func() {}
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return errors.New("无法发布讨论")
+		}
+		if parent.ID == 0 {
+			return nil
+		}
+		var participantIds []uint
+		if err := tx.Model(&models.TaskComment{}).
+			Where("(id = ? OR parent_id = ?) AND user_id <> ?", parent.ID, parent.ID, user.ID).
+			Distinct().Pluck("user_id", &participantIds).Error; err != nil {
+			return err
+		}
+		preview := []rune(content)
+		if len(preview) > commentPreviewLength {
+			preview = append(preview[:commentPreviewLength], []rune("...")...)
+		}
+		_, p, err := s.notifications.EmitTx(tx, Event{
+			Type:           models.EventCommentReplied,
+			OrganizationID: orgID,
+			Recipients:     participantIds,
+			Data:           MessageData{"Nickname": user.Nickname, "TaskName": task.Name, "Content": string(preview)},
+		})
+		if err != nil {
+			return errors.New("创建消息失败")
+		}
+		push = p
+		return nil
+	})
+	if err != nil {
+		return dto.TaskCommentInfo{}, err
+	}
+	push()
+	return dto.TaskCommentInfo{
+		ID:        comment.ID,
+		ParentID:  comment.ParentID,
+		UserID:    user.ID,
+		Nickname:  user.Nickname,
+		Avatar:    user.Avatar,
+		Content:   comment.Content,
+		CreatedAt: utils.FormatTime2Str(comment.CreatedAt),
+	}, nil
+}
+
+// PinTaskComment 置顶或取消置顶讨论
+func PinTaskComment(orgID uint, commentId uint, pinned bool) error {
+	comment, err := getOrgComment(orgID, commentId)
+	if err != nil {
+		return err
+	}
+	if err := models.DB.Model(&comment).Update("pinned", pinned).Error; err != nil {
+		return errors.New("无法更新讨论")
+	}
+	return nil
+}
+
+// HideTaskComment 隐藏或取消隐藏讨论
+func HideTaskComment(orgID uint, commentId uint, hidden bool) error {
+	comment, err := getOrgComment(orgID, commentId)
+	if err != nil {
+		return err
+	}
+	if err := models.DB.Model(&comment).Update("hidden", hidden).Error; err != nil {
+		return errors.New("无法更新讨论")
+	}
+	return nil
+}
+
+// DeleteTaskComment 删除讨论，发布者和可以管理该活动的用户可以删除，删除提问时一并删除其下的回复
+func DeleteTaskComment(orgID uint, email string, commentId uint) error {
+	comment, err := getOrgComment(orgID, commentId)
+	if err != nil {
+		return err
+	}
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return err
+	}
+	if comment.UserID != userId {
+		ok, err := CanManageTask(email, orgID, comment.TaskID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNoTaskPermission
+		}
+	}
+	if comment.ParentID != 0 {
+		return models.DB.Delete(&comment).Error
+	}
+	return models.DB.Where("id = ? OR parent_id = ?", comment.ID, comment.ID).Delete(&models.TaskComment{}).Error
+}
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/utils"
+	"errors"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrConversationForbidden 没有访问该会话的权限
+	ErrConversationForbidden = errors.New("没有访问该会话的权限")
+	// ErrRateLimited 操作过于频繁
+	ErrRateLimited = errors.New("发送过于频繁，请稍后再试")
+)
+
+const (
+	defaultDirectMessageLimit = 50
+	maxDirectMessageLimit     = 100
+)
+
+// directMessageLimiter 每个用户每分钟最多发送20条私信
+var directMessageLimiter = utils.NewRateLimiter(20, time.Minute)
+
+// canDirectMessage 判断发送者是否可以向接收者发起私信
+// 拥有 task:manage 权限的用户可以联系组织内所有成员；其他用户只能联系与自己存在 活动协调员-报名人 关系的成员，
+// 因此志愿者只能联系自己报名的活动的协调员，志愿者之间不能互相发起私信
+func canDirectMessage(orgID uint, sender *models.User, recipient *models.User) (bool, error) {
+	if sender.ID == recipient.ID || !IsOrganizationMember(recipient.ID, orgID) {
+		return false, nil
+	}
+	ok, err := HasPermission(sender.Email, orgID, models.PermTaskManage)
+	if err != nil || ok {
+		return ok, err
+	}
+	var count int64
+	if err := models.DB.Table("task_coordinators").
+		Joins("JOIN tasks ON tasks.id = task_coordinators.task_id").
+		Joins("JOIN task_participants ON task_participants.task_id = task_coordinators.task_id").
+		Joins("JOIN users ON users.email = task_participants.email").
+		Where("tasks.organization_id = ? AND task_participants.status IN ? AND task_participants.deleted_at IS NULL", orgID, []uint{0, 1}).
+		Where("(task_coordinators.user_id = ? AND users.id = ?) OR (task_coordinators.user_id = ? AND users.id = ?)",
+			sender.ID, recipient.ID, recipient.ID, sender.ID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// canConverse 判断两个用户之间是否存在私信关系，任意一方可以向另一方发起私信即可
+func canConverse(orgID uint, a *models.User, b *models.User) (bool, error) {
+	ok, err := canDirectMessage(orgID, a, b)
+	if err != nil || ok {
+		return ok, err
+	}
+	return canDirectMessage(orgID, b, a)
+}
+
+// allowDirectMessage 检查用户发送私信的频率
+func allowDirectMessage(userId uint) error {
+	if !directMessageLimiter.Allow(strconv.FormatUint(uint64(userId), 10)) {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// getParticipantConversation 查询用户参与的当前组织中的会话
+func getParticipantConversation(orgID uint, userId uint, conversationId uint) (models.Conversation, error) {
+	var conversation models.Conversation
+	if err := models.DB.Joins("JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id").
+		Where("conversations.id = ? AND conversations.organization_id = ? AND conversation_participants.user_id = ?", conversationId, orgID, userId).
+		First(&conversation).Error; err != nil {
+		return models.Conversation{}, ErrConversationForbidden
+	}
+	return conversation, nil
+}
+
+// StartConversation 发起私信会话并发送第一条私信，返回会话ID
+func StartConversation(orgID uint, email string, input dto.StartConversationRequest) (uint, error) {
+	sender, err := GetUserProfile(email)
+	if err != nil {
+		return 0, err
+	}
+	content := strings.TrimSpace(input.Content)
+	if content == "" {
+		return 0, errors.New("私信内容不能为空")
+	}
+	if input.TaskId != 0 {
+		if _, err := getOrgTask(orgID, input.TaskId); err != nil {
+			return 0, err
+		}
+	}
+	var recipients []models.User
+	if err := models.DB.Where("email IN ?", input.Recipients).Find(&recipients).Error; err != nil {
+		return 0, err
+	}
+	if len(recipients) != len(input.Recipients) {
+		return 0, errors.New("接收者不存在")
+	}
+	// 会话中的私信所有参与者都能看到，因此接收者之间也必须存在私信关系，避免志愿者通过多人会话互相联系
+	for i := range recipients {
+		ok, err := canDirectMessage(orgID, sender, &recipients[i])
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, errors.New("不能向" + recipients[i].Nickname + "发送私信")
+		}
+		for j := range recipients[:i] {
+			ok, err := canConverse(orgID, &recipients[j], &recipients[i])
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
+				return 0, errors.New(recipients[j].Nickname + "和" + recipients[i].Nickname + "之间不能互相发送私信，不能加入同一个会话")
+			}
+		}
+	}
+	if err := allowDirectMessage(sender.ID); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().Local()
+	conversation := models.Conversation{
+		OrganizationID: orgID,
+		TaskID:         input.TaskId,
+		Subject:        strings.TrimSpace(input.Subject),
+		CreatedBy:      sender.ID,
+		LastMessageAt:  now,
+		CreatedAt:      now,
+	}
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&conversation).Error; err != nil {
+			return errors.New("无法创建会话")
+		}
+		participants := []models.ConversationParticipant{{ConversationID: conversation.ID, UserID: sender.ID, CreatedAt: now}}
+		for _, recipient := range recipients {
+			participants = append(participants, models.ConversationParticipant{ConversationID: conversation.ID, UserID: recipient.ID, CreatedAt: now})
+		}
+		if err := tx.Create(&participants).Error; err != nil {
+			return errors.New("无法创建会话")
+		}
+		message := models.DirectMessage{ConversationID: conversation.ID, SenderID: sender.ID, Content: content, CreatedAt: now}
+		if err := tx.Create(&message).Error; err != nil {
+			return errors.New("无法发送私信")
+		}
+		return tx.Model(&models.ConversationParticipant{}).
+			Where("conversation_id = ? AND user_id = ?", conversation.ID, sender.ID).
+			Update("last_read_message_id", message.ID).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return conversation.ID, nil
+}
+
+// SendDirectMessage 在会话中发送私信，会话参与者都可以回复
+func SendDirectMessage(orgID uint, email string, input dto.SendDirectMessageRequest) (dto.DirectMessageInfo, error) {
+	sender, err := GetUserProfile(email)
+	if err != nil {
+		return dto.DirectMessageInfo{}, err
+	}
+	conversation, err := getParticipantConversation(orgID, sender.ID, input.ConversationID)
+	if err != nil {
+		return dto.DirectMessageInfo{}, err
+	}
+	content := strings.TrimSpace(input.Content)
+	if content == "" {
+		return dto.DirectMessageInfo{}, errors.New("私信内容不能为空")
+	}
+	if err := allowDirectMessage(sender.ID); err != nil {
+		return dto.DirectMessageInfo{}, err
+	}
+	message := models.DirectMessage{ConversationID: conversation.ID, SenderID: sender.ID, Content: content, CreatedAt: time.Now().Local()}
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&message).Error; err != nil {
+			return errors.New("无法发送私信")
+		}
+		if err := tx.Model(&conversation).Update("last_message_at", message.CreatedAt).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.ConversationParticipant{}).
+			Where("conversation_id = ? AND user_id = ?", conversation.ID, sender.ID).
+			Update("last_read_message_id", message.ID).Error
+	})
+	if err != nil {
+		return dto.DirectMessageInfo{}, err
+	}
+	return dto.DirectMessageInfo{
+		ID:       message.ID,
+		SenderID: sender.ID,
+		Nickname: sender.Nickname,
+		Content:  message.Content,
+		Time:     utils.FormatTime2Str(message.CreatedAt),
+	}, nil
+}
+
+// GetConversations 获取当前用户在当前组织中的会话，按最后一条私信的时间倒序排列
+func GetConversations(orgID uint, email string) ([]dto.ConversationInfo, error) {
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	var conversations []models.Conversation
+	if err := models.DB.Joins("JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id").
+		Where("conversations.organization_id = ? AND conversation_participants.user_id = ?", orgID, userId).
+		Order("conversations.last_message_at DESC").
+		Find(&conversations).Error; err != nil {
+		return nil, err
+	}
+	return toConversationInfos(conversations, userId)
+}
+
+// GetOrgConversations 获取组织内的所有会话，用于管理员管理私信
+func GetOrgConversations(orgID uint) ([]dto.ConversationInfo, error) {
+	var conversations []models.Conversation
+	if err := models.DB.Where("organization_id = ?", orgID).Order("last_message_at DESC").Find(&conversations).Error; err != nil {
+		return nil, err
+	}
+	return toConversationInfos(conversations, 0)
+}
+
+// toConversationInfos 组装会话的参与者、最后一条私信和未读数，viewerId 为0时不统计未读数
+func toConversationInfos(conversations []models.Conversation, viewerId uint) ([]dto.ConversationInfo, error) {
+	infos := make([]dto.ConversationInfo, len(conversations))
+	for i, conversation := range conversations {
+		var members []dto.ConversationMember
+		if err := models.DB.Table("conversation_participants").
+			Select("users.id, users.email, users.nickname, users.avatar").
+			Joins("JOIN users ON users.id = conversation_participants.user_id").
+			Where("conversation_participants.conversation_id = ?", conversation.ID).
+			Scan(&members).Error; err != nil {
+			return nil, err
+		}
+		visible := models.DB.Model(&models.DirectMessage{}).Where("conversation_id = ?", conversation.ID)
+		if viewerId != 0 {
+			visible = visible.Where("hidden = ?", false)
+		}
+		var last models.DirectMessage
+		if err := visible.Session(&gorm.Session{}).Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+			return nil, err
+		}
+		info := dto.ConversationInfo{
+			ID:            conversation.ID,
+			TaskID:        conversation.TaskID,
+			Subject:       conversation.Subject,
+			Members:       members,
+			LastMessage:   last.Content,
+			LastMessageAt: utils.FormatTime2Str(conversation.LastMessageAt),
+		}
+		if viewerId != 0 {
+			var participant models.ConversationParticipant
+			if err := models.DB.Where("conversation_id = ? AND user_id = ?", conversation.ID, viewerId).First(&participant).Error; err != nil {
+				return nil, err
+			}
+			if err := visible.Session(&gorm.Session{}).Where("id > ? AND sender_id <> ?", participant.LastReadMessageID, viewerId).
+				Count(&info.Unread).Error; err != nil {
+				return nil, err
+			}
+		}
+		infos[i] = info
+	}
+	return infos, nil
+}
+
+// listDirectMessages 按ID倒序分页查询会话中的私信，返回时按时间正序排列
+func listDirectMessages(conversationId uint, query dto.DirectMessageQuery, includeHidden bool) ([]dto.DirectMessageInfo, error) {
+	if query.Limit < 1 {
+		query.Limit = defaultDirectMessageLimit
+	}
+	if query.Limit > maxDirectMessageLimit {
+		query.Limit = maxDirectMessageLimit
+	}
+	db := models.DB.Table("direct_messages").
+		Select("direct_messages.id, direct_messages.sender_id, users.nickname, direct_messages.content, direct_messages.hidden, direct_messages.created_at").
+		Joins("JOIN users ON users.id = direct_messages.sender_id").
+		Where("direct_messages.conversation_id = ? AND direct_messages.deleted_at IS NULL", conversationId)
+	if !includeHidden {
+		db = db.Where("direct_messages.hidden = ?", false)
+	}
+	if query.BeforeID != 0 {
+		db = db.Where("direct_messages.id < ?", query.BeforeID)
+	}
+	var rows []struct {
+		ID        uint
+		SenderID  uint
+		Nickname  string
+		Content   string
+		Hidden    bool
+		CreatedAt time.Time
+	}
+	if err := db.Order("direct_messages.id DESC").Limit(query.Limit).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	messages := make([]dto.DirectMessageInfo, len(rows))
+	for i, row := range rows {
+		messages[len(rows)-1-i] = dto.DirectMessageInfo{
+			ID:       row.ID,
+			SenderID: row.SenderID,
+			Nickname: row.Nickname,
+			Content:  row.Content,
+			Hidden:   row.Hidden,
+			Time:     utils.FormatTime2Str(row.CreatedAt),
+		}
+	}
+	return messages, nil
+}
+
+// GetDirectMessages 获取会话中的私信，获取最新的私信时将会话标记为已读
+func GetDirectMessages(orgID uint, email string, query dto.DirectMessageQuery) ([]dto.DirectMessageInfo, error) {
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	conversation, err := getParticipantConversation(orgID, userId, query.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := listDirectMessages(conversation.ID, query, false)
+	if err != nil {
+		return nil, err
+	}
+	if query.BeforeID == 0 {
+		if err := markConversationRead(conversation.ID, userId); err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
+// MarkConversationRead 将会话标记为已读
+func MarkConversationRead(orgID uint, email string, conversationId uint) error {
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return err
+	}
+	conversation, err := getParticipantConversation(orgID, userId, conversationId)
+	if err != nil {
+		return err
+	}
+	return markConversationRead(conversation.ID, userId)
+}
+
+// markConversationRead 将用户在会话中的阅读进度更新到最新一条私信
+func markConversationRead(conversationId uint, userId uint) error {
+	var lastId uint
+	if err := models.DB.Model(&models.DirectMessage{}).Where("conversation_id = ?", conversationId).
+		Select("COALESCE(MAX(id), 0)").Scan(&lastId).Error; err != nil {
+		return err
+	}
+	return models.DB.Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ? AND last_read_message_id < ?", conversationId, userId, lastId).
+		Update("last_read_message_id", lastId).Error
+}
+
+// GetModeratedDirectMessages 管理员查看组织内会话中的私信，包括被屏蔽的私信
+func GetModeratedDirectMessages(orgID uint, query dto.DirectMessageQuery) ([]dto.DirectMessageInfo, error) {
+	var conversation models.Conversation
+	if err := models.DB.Where("id = ? AND organization_id = ?", query.ConversationID, orgID).First(&conversation).Error; err != nil {
+		return nil, errors.New("会话不存在")
+	}
+	return listDirectMessages(conversation.ID, query, true)
+}
+
+// HideDirectMessage 屏蔽或取消屏蔽组织内的私信
+func HideDirectMessage(orgID uint, messageId uint, hidden bool) error {
+	result := models.DB.Model(&models.DirectMessage{}).
+		Where("id = ? AND conversation_id IN (?)", messageId,
+			models.DB.Model(&models.Conversation{}).Select("id").Where("organization_id = ?", orgID)).
+		Update("hidden", hidden)
+	if result.Error != nil {
+		return errors.New("无法更新私信")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("私信不存在或状态未变化")
+	}
+	return nil
+}
+
+// DeleteConversation 删除组织内的会话及其中的私信
+func DeleteConversation(orgID uint, conversationId uint) error {
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND organization_id = ?", conversationId, orgID).Delete(&models.Conversation{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("会话不存在")
+		}
+		if err := tx.Where("conversation_id = ?", conversationId).Delete(&models.ConversationParticipant{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("conversation_id = ?", conversationId).Delete(&models.DirectMessage{}).Error
+	})
+}
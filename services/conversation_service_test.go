@@ -0,0 +1,55 @@
+package services
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"testing"
+	"time"
+)
+
+func TestGroupConversationRequiresEveryPairToBeAllowed(t *testing.T) {
+	setupTestDB(t)
+	orgID := createTestOrg(t, "社团")
+	admin := createTestUser(t, "admin@example.com")
+	coordinator := createTestUser(t, "coordinator@example.com")
+	volunteerA := createTestUser(t, "a@example.com")
+	volunteerB := createTestUser(t, "b@example.com")
+	addTestMember(t, orgID, admin, models.RoleOrgAdmin)
+	addTestMember(t, orgID, coordinator, models.RoleCoordinator)
+	addTestMember(t, orgID, volunteerA, models.RoleVolunteer)
+	addTestMember(t, orgID, volunteerB, models.RoleVolunteer)
+
+	task := createTestTask(t, orgID, "河道清理", 10)
+	if err := models.DB.Create(&models.TaskCoordinator{TaskID: task.ID, UserID: coordinator.ID, CreatedAt: time.Now().Local()}).Error; err != nil {
+		t.Fatal(err)
+	}
+	participants := []models.TaskParticipant{
+		{TaskID: task.ID, Nickname: "a", Email: volunteerA.Email, Status: 1},
+		{TaskID: task.ID, Nickname: "b", Email: volunteerB.Email, Status: 1},
+	}
+	if err := models.DB.Create(&participants).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 管理员可以联系每个志愿者，但两个志愿者之间不能互相私信，不能放进同一个会话
+	if _, err := StartConversation(orgID, admin.Email, dto.StartConversationRequest{
+		Recipients: []string{volunteerA.Email, volunteerB.Email},
+		Content:    "大家好",
+	}); err == nil {
+		t.Fatal("志愿者之间不能通过多人会话互相联系")
+	}
+	// 协调员发起的多人会话同样不能包含多个志愿者
+	if _, err := StartConversation(orgID, coordinator.Email, dto.StartConversationRequest{
+		Recipients: []string{volunteerA.Email, volunteerB.Email},
+		Content:    "大家好",
+	}); err == nil {
+		t.Fatal("志愿者之间不能通过协调员发起的会话互相联系")
+	}
+	// 志愿者与活动协调员之间存在私信关系，可以和管理员在同一个会话中
+	if _, err := StartConversation(orgID, admin.Email, dto.StartConversationRequest{
+		Recipients: []string{volunteerA.Email, coordinator.Email},
+		Content:    "请协调员跟进",
+	}); err != nil {
+		t.Fatalf("接收者之间存在私信关系时应能发起会话: %v", err)
+	}
+}
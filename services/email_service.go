@@ -0,0 +1,247 @@
+package services
+
+import (
+	"volunteer-system-backend/config"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/templates"
+	"volunteer-system-backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultEmailMaxAttempts = 5
+	emailOutboxBatchSize    = 100
+	// emailSendLease 认领邮件后的租约时长，发送进程异常退出时邮件会在租约到期后被重新发送
+	emailSendLease = 5 * time.Minute
+	// emailMaxBackoff 重试间隔的上限
+	emailMaxBackoff = time.Hour
+)
+
+// messageCategoryLabels 消息分类在邮件中的显示名称
+var messageCategoryLabels = map[string]string{
+	models.MessageCategorySystem:       "系统通知",
+	models.MessageCategoryApproval:     "报名审核",
+	models.MessageCategoryReminder:     "活动提醒",
+	models.MessageCategoryAnnouncement: "公告",
+}
+
+// EmailNotifier 邮件通知渠道，将需要发送邮件的消息写入邮件发件箱
+type EmailNotifier struct{}
+
+// Channel 渠道名称
+func (n *EmailNotifier) Channel() string {
+	return "email"
+}
+
+// DefaultEnabled 用户没有设置时，配置中列出的消息分类默认发送邮件
+func (n *EmailNotifier) DefaultEnabled(category string) bool {
+	return emailEnabledFor(category)
+}
+
+// Notify 在邮件通知开启时渲染邮件并写入发件箱
+// 处于用户免打扰时段的邮件推迟到免打扰结束后发送，开启每日摘要时非紧急消息等待合并到摘要中
+func (n *EmailNotifier) Notify(tx *gorm.DB, user models.User, message models.Message) error {
+	if !config.ProjectConfig.Email.Enabled || user.Email == "" {
+		return nil
+	}
+	now := time.Now().Local()
+	if user.DailyDigest && !isUrgentCategory(message.Category) {
+		return tx.Create(&models.EmailOutbox{
+			UserID:        user.ID,
+			MessageID:     message.ID,
+			To:            user.Email,
+			Subject:       message.Title,
+			Body:          message.Content,
+			Status:        models.EmailStatusDigest,
+			NextAttemptAt: nextDigestTime(user, now),
+			CreatedAt:     now,
+		}).Error
+	}
+	body, err := templates.RenderEmail("notification.html", map[string]string{
+		"Label":    messageCategoryLabels[message.Category],
+		"Title":    message.Title,
+		"Nickname": user.Nickname,
+		"Content":  message.Content,
+		"Time":     utils.FormatTime2Str(message.Time),
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.EmailOutbox{
+		UserID:        user.ID,
+		MessageID:     message.ID,
+		To:            user.Email,
+		Subject:       message.Title,
+		Body:          body,
+		Status:        models.EmailStatusPending,
+		NextAttemptAt: deferQuietHours(user, now),
+		CreatedAt:     now,
+	}).Error
+}
+
+// digestItem 每日摘要中的一条消息
+type digestItem struct {
+	Title   string
+	Content string
+	Time    string
+}
+
+// mergeEmailDigests 将到达摘要发送时间的消息按用户合并为一封待发送的摘要邮件
+func mergeEmailDigests() error {
+	now := time.Now().Local()
+	var userIds []uint
+	if err := models.DB.Model(&models.EmailOutbox{}).
+		Where("status = ? AND next_attempt_at <= ?", models.EmailStatusDigest, now).
+		Distinct().Pluck("user_id", &userIds).Error; err != nil {
+		return err
+	}
+	for _, userId := range userIds {
+		err := models.DB.Transaction(func(tx *gorm.DB) error {
+			var user models.User
+			if err := tx.First(&user, userId).Error; err != nil {
+				return err
+			}
+			var entries []models.EmailOutbox
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("user_id = ? AND status = ? AND next_attempt_at <= ?", userId, models.EmailStatusDigest, now).
+				Order("id").Find(&entries).Error; err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				return nil
+			}
+			items := make([]digestItem, len(entries))
+			ids := make([]uint, len(entries))
+			for i, entry := range entries {
+				items[i] = digestItem{Title: entry.Subject, Content: entry.Body, Time: utils.FormatTime2Str(entry.CreatedAt)}
+				ids[i] = entry.ID
+			}
+			subject := "志愿者系统每日摘要（" + strconv.Itoa(len(items)) + "条新消息）"
+			body, err := templates.RenderEmail("digest.html", map[string]any{
+				"Title":    subject,
+				"Nickname": user.Nickname,
+				"Items":    items,
+			})
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(&models.EmailOutbox{
+				UserID:        user.ID,
+				To:            entries[0].To,
+				Subject:       subject,
+				Body:          body,
+				Status:        models.EmailStatusPending,
+				NextAttemptAt: now,
+				CreatedAt:     now,
+			}).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.EmailOutbox{}).Where("id IN ?", ids).Update("status", models.EmailStatusMerged).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emailEnabledFor 判断指定分类的消息是否需要发送邮件
+func emailEnabledFor(category string) bool {
+	emailConfig := config.ProjectConfig.Email
+	if !emailConfig.Enabled {
+		return false
+	}
+	for _, c := range emailConfig.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// emailBackoff 第 attempts 次发送失败后的重试间隔，按指数增长
+func emailBackoff(attempts int) time.Duration {
+	backoff := time.Minute << uint(attempts-1)
+	if backoff <= 0 || backoff > emailMaxBackoff {
+		return emailMaxBackoff
+	}
+	return backoff
+}
+
+// ProcessEmailOutbox 合并到期的每日摘要并发送发件箱中到期的邮件
+// 每封邮件先通过条件更新下次尝试时间进行认领，保证多个进程同时运行时同一封邮件不会被重复发送
+func ProcessEmailOutbox(mailer utils.Mailer) error {
+	if err := mergeEmailDigests(); err != nil {
+		return err
+	}
+	maxAttempts := config.ProjectConfig.Email.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultEmailMaxAttempts
+	}
+	now := time.Now().Local()
+	var outbox []models.EmailOutbox
+	if err := models.DB.Where("status = ? AND next_attempt_at <= ?", models.EmailStatusPending, now).
+		Order("id").Limit(emailOutboxBatchSize).Find(&outbox).Error; err != nil {
+		return err
+	}
+	for _, email := range outbox {
+		result := models.DB.Model(&models.EmailOutbox{}).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", email.ID, models.EmailStatusPending, email.NextAttemptAt).
+			Update("next_attempt_at", now.Add(emailSendLease))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		sendErr := mailer.Send(utils.Mail{To: email.To, Subject: email.Subject, HTMLBody: email.Body})
+		updates := map[string]any{"attempts": email.Attempts + 1}
+		if sendErr == nil {
+			sentAt := time.Now().Local()
+			updates["status"] = models.EmailStatusSent
+			updates["sent_at"] = &sentAt
+			updates["last_error"] = ""
+		} else {
+			lastError := sendErr.Error()
+			if len(lastError) > 512 {
+				lastError = lastError[:512]
+			}
+			updates["last_error"] = lastError
+			if email.Attempts+1 >= maxAttempts {
+				updates["status"] = models.EmailStatusFailed
+			} else {
+				updates["next_attempt_at"] = time.Now().Local().Add(emailBackoff(email.Attempts + 1))
+			}
+		}
+		// 重置密码等一次性链接不再需要发送后不在数据库中保留
+		if status, _ := updates["status"].(string); email.Sensitive && status != "" {
+			updates["body"] = ""
+		}
+		if err := models.DB.Model(&models.EmailOutbox{}).Where("id = ?", email.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartEmailOutboxWorker 启动发送邮件的后台任务，未开启邮件时不启动
+func StartEmailOutboxWorker(interval time.Duration, mailer utils.Mailer) {
+	if !config.ProjectConfig.Email.Enabled {
+		log.Println("未开启邮件，重置密码、验证邮箱等邮件不会被发送")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := ProcessEmailOutbox(mailer); err != nil {
+				log.Println("发送邮件失败:", err)
+			}
+		}
+	}()
+}
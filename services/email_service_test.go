@@ -0,0 +1,100 @@
+package services
+
+import (
+	"volunteer-system-backend/config"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessEmailOutbox(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "user@example.com")
+	now := time.Now().Local().Add(-time.Second)
+	good := models.EmailOutbox{UserID: user.ID, To: user.Email, Subject: "活动提醒", Body: "<p>明天开始</p>", Status: models.EmailStatusPending, NextAttemptAt: now, CreatedAt: now}
+	injected := models.EmailOutbox{UserID: user.ID, To: user.Email, Subject: "活动提醒\r\nBcc: attacker@example.com", Body: "<p>正文</p>", Status: models.EmailStatusPending, NextAttemptAt: now, CreatedAt: now}
+	for _, email := range []*models.EmailOutbox{&good, &injected} {
+		if err := models.DB.Create(email).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mailer := &utils.MemoryMailer{}
+	if err := ProcessEmailOutbox(mailer); err != nil {
+		t.Fatal(err)
+	}
+	sent := mailer.Sent()
+	if len(sent) != 1 || sent[0].To != user.Email || sent[0].Subject != "活动提醒" {
+		t.Fatalf("应只发送一封正常的邮件: %+v", sent)
+	}
+
+	var saved models.EmailOutbox
+	if err := models.DB.First(&saved, good.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != models.EmailStatusSent || saved.SentAt == nil || saved.Attempts != 1 {
+		t.Fatalf("正常的邮件应标记为已发送: %+v", saved)
+	}
+	var failed models.EmailOutbox
+	if err := models.DB.First(&failed, injected.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if failed.Status != models.EmailStatusPending || failed.Attempts != 1 || failed.LastError != utils.ErrInvalidMailHeader.Error() {
+		t.Fatalf("包含换行符的邮件应记录失败原因并等待重试: %+v", failed)
+	}
+	if !failed.NextAttemptAt.After(time.Now().Local()) {
+		t.Fatal("发送失败的邮件应推迟重试")
+	}
+
+	// 未到重试时间的邮件不会再次发送
+	if err := ProcessEmailOutbox(mailer); err != nil {
+		t.Fatal(err)
+	}
+	if len(mailer.Sent()) != 1 {
+		t.Fatalf("不应重复发送邮件: %+v", mailer.Sent())
+	}
+}
+
+func TestSentResetEmailDoesNotKeepLink(t *testing.T) {
+	setupTestDB(t)
+	config.ProjectConfig.Account.FrontendURL = "https://volunteer.example.com"
+	config.ProjectConfig.Email.MaxAttempts = 1
+	user := createTestUser(t, "reset@example.com")
+	if err := RequestPasswordReset(user.Email, "192.0.2.10"); err != nil {
+		t.Fatal(err)
+	}
+	var reset models.EmailOutbox
+	if err := models.DB.Where("user_id = ?", user.ID).First(&reset).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !reset.Sensitive || !strings.Contains(reset.Body, "/reset-password?token=") {
+		t.Fatalf("重置密码邮件应标记为敏感并在发送前包含重置链接: %+v", reset)
+	}
+	// 最终发送失败的敏感邮件同样不保留正文
+	now := time.Now().Local().Add(-time.Second)
+	failing := models.EmailOutbox{UserID: user.ID, To: user.Email, Subject: "重置密码\r\nBcc: attacker@example.com", Body: reset.Body,
+		Sensitive: true, Status: models.EmailStatusPending, NextAttemptAt: now, CreatedAt: now}
+	if err := models.DB.Create(&failing).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	mailer := &utils.MemoryMailer{}
+	if err := ProcessEmailOutbox(mailer); err != nil {
+		t.Fatal(err)
+	}
+	sent := mailer.Sent()
+	if len(sent) != 1 || !strings.Contains(sent[0].HTMLBody, "/reset-password?token=") {
+		t.Fatalf("发出的邮件应包含重置链接: %+v", sent)
+	}
+	for id, status := range map[uint]string{reset.ID: models.EmailStatusSent, failing.ID: models.EmailStatusFailed} {
+		var saved models.EmailOutbox
+		if err := models.DB.First(&saved, id).Error; err != nil {
+			t.Fatal(err)
+		}
+		if saved.Status != status || saved.Body != "" {
+			t.Fatalf("发送结束后应清空包含重置链接的正文: %+v", saved)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"volunteer-system-backend/config"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/templates"
+	"volunteer-system-backend/utils"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrEmailNotVerified 邮箱尚未验证
+	ErrEmailNotVerified = errors.New("邮箱尚未验证，请先点击验证邮件中的链接")
+	// ErrInvalidVerifyToken 邮箱验证链接无效或已过期
+	ErrInvalidVerifyToken = errors.New("验证链接无效或已过期")
+)
+
+// verificationEmailLimiter 同一个邮箱每分钟最多发送一封验证邮件
+var verificationEmailLimiter = utils.NewRateLimiter(1, time.Minute)
+
+// verifyTokenExpiry 邮箱验证链接的有效期，默认48小时
+func verifyTokenExpiry() time.Duration {
+	if config.ProjectConfig.Account.VerifyExpiry > 0 {
+		return time.Duration(config.ProjectConfig.Account.VerifyExpiry) * time.Hour
+	}
+	return 48 * time.Hour
+}
+
+// signVerifyToken 生成邮箱验证令牌，格式为 用户ID.过期时间.签名，签名中包含邮箱，邮箱变化后旧链接失效
+func signVerifyToken(userId uint, email string, expiresAt time.Time) string {
+	payload := fmt.Sprintf("%d.%d", userId, expiresAt.Unix())
+	return payload + "." + utils.SignHMAC(payload+"."+strings.ToLower(email))
+}
+
+// sendVerificationEmail 在事务中写入邮箱验证邮件
+func sendVerificationEmail(tx *gorm.DB, user *models.User) error {
+	expiry := verifyTokenExpiry()
+	token := signVerifyToken(user.ID, user.Email, time.Now().Add(expiry))
+	body, err := templates.RenderEmail("verify_email.html", map[string]any{
+		"Nickname": user.Nickname,
+		"Link":     accountLink("/verify-email", token),
+		"Hours":    int(expiry.Hours()),
+	})
+	if err != nil {
+		return err
+	}
+	now := time.Now().Local()
+	return tx.Create(&models.EmailOutbox{
+		UserID:        user.ID,
+		To:            user.Email,
+		Subject:       "验证邮箱",
+		Body:          body,
+		Sensitive:     true,
+		Status:        models.EmailStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}).Error
+}
+
+// VerifyEmail 校验邮件中的签名链接并完成邮箱验证，已经验证过的邮箱再次验证时直接成功
+func VerifyEmail(token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrInvalidVerifyToken
+	}
+	userId, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return ErrInvalidVerifyToken
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return ErrInvalidVerifyToken
+	}
+	var user models.User
+	if err := models.DB.First(&user, userId).Error; err != nil {
+		return ErrInvalidVerifyToken
+	}
+	expected := signVerifyToken(user.ID, user.Email, time.Unix(expiresAt, 0))
+	if !hmac.Equal([]byte(expected), []byte(token)) {
+		return ErrInvalidVerifyToken
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+	return models.DB.Model(&user).Update("email_verified_at", time.Now().Local()).Error
+}
+
+// ResendVerificationEmail 重新发送邮箱验证邮件，邮箱未注册或已经验证时同样返回成功，避免探测已注册的邮箱
+func ResendVerificationEmail(email string) error {
+	email = strings.TrimSpace(email)
+	if !verificationEmailLimiter.Allow(strings.ToLower(email)) {
+		return ErrRateLimited
+	}
+	var user models.User
+	if err := models.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+	return sendVerificationEmail(models.DB, &user)
+}
+
+// AdminVerifyEmail 管理员直接将用户的邮箱标记为已验证
+func AdminVerifyEmail(email string) error {
+	result := models.DB.Model(&models.User{}).
+		Where("email = ? AND email_verified_at IS NULL", email).
+		Update("email_verified_at", time.Now().Local())
+	if result.Error != nil {
+		return errors.New("无法更新邮箱验证状态")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("用户不存在或邮箱已经验证")
+	}
+	return nil
+}
+
+// PurgeUnverifiedUsers 删除注册后超过指定天数仍未验证邮箱的账户及其组织成员关系
+func PurgeUnverifiedUsers() error {
+	days := config.ProjectConfig.Account.UnverifiedDays
+	if days <= 0 {
+		days = 7
+	}
+	deadline := time.Now().Local().AddDate(0, 0, -days)
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		var userIds []uint
+		if err := tx.Model(&models.User{}).
+			Where("email_verified_at IS NULL AND created_at < ?", deadline).
+			Pluck("id", &userIds).Error; err != nil {
+			return err
+		}
+		if len(userIds) == 0 {
+			return nil
+		}
+		if err := tx.Where("user_id IN ?", userIds).Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id IN ?", userIds).Delete(&models.EmailOutbox{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", userIds).Delete(&models.User{}).Error
+	})
+}
+
+// StartUnverifiedUserCleaner 启动后台任务，定期删除长期未验证邮箱的账户
+func StartUnverifiedUserCleaner(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := PurgeUnverifiedUsers(); err != nil {
+				log.Println("清理未验证邮箱的账户失败:", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"volunteer-system-backend/config"
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/utils"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLoginThrottled 同一IP登录失败次数过多
+	ErrLoginThrottled = errors.New("登录尝试过于频繁，请稍后再试")
+	// ErrAccountLocked 账户因连续登录失败被临时锁定
+	ErrAccountLocked = errors.New("登录失败次数过多，账户已被临时锁定")
+)
+
+const (
+	loginIPLimit          = 20                  // 同一IP可能有多个用户共用，窗口时间内前20次失败不需要等待
+	loginIPWindow         = maxLoginLockout     // 统计IP登录失败次数的窗口时间，不短于最长锁定时间，否则等待时间无法继续增长
+	maxLoginLockout       = 24 * time.Hour      // 最长锁定时间
+	loginAttemptRetention = 30 * 24 * time.Hour // 登录记录的保留时间
+	failedLoginListLimit  = 20                  // 登录成功后最多展示的失败记录条数
+)
+
+// loginLockPolicy 返回锁定账户前允许连续失败的次数和首次锁定的时长
+func loginLockPolicy() (uint, time.Duration) {
+	maxFails := uint(5)
+	if config.ProjectConfig.Account.MaxLoginFails > 0 {
+		maxFails = uint(config.ProjectConfig.Account.MaxLoginFails)
+	}
+	lockout := 15 * time.Minute
+	if config.ProjectConfig.Account.LockoutMinutes > 0 {
+		lockout = time.Duration(config.ProjectConfig.Account.LockoutMinutes) * time.Minute
+	}
+	return maxFails, lockout
+}
+
+// loginBackoff 连续失败 failures 次后需要等待的时间
+// 达到锁定次数前按 1、2、4、8 秒递增，达到后锁定账户，锁定期间继续失败时锁定时长翻倍
+func loginBackoff(failures uint) time.Duration {
+	if failures == 0 {
+		return 0
+	}
+	maxFails, lockout := loginLockPolicy()
+	if failures < maxFails {
+		if failures > 10 {
+			failures = 10
+		}
+		return time.Duration(1<<(failures-1)) * time.Second
+	}
+	backoff := lockout
+	for i := maxFails; i < failures && backoff < maxLoginLockout; i++ {
+		backoff *= 2
+	}
+	if backoff > maxLoginLockout {
+		return maxLoginLockout
+	}
+	return backoff
+}
+
+// loginIPBackoff 同一IP失败 failures 次后需要等待的时间，超过 loginIPLimit 次后按账户的规则递增
+func loginIPBackoff(failures uint) time.Duration {
+	if failures < loginIPLimit {
+		return 0
+	}
+	return loginBackoff(failures - loginIPLimit + 1)
+}
+
+// checkLoginAllowed 检查登录请求的IP和账户是否处于限制中，user 为空表示邮箱未注册
+// 未注册的邮箱按该邮箱的失败记录以相同的规则计算锁定时间，返回与已注册账户相同的提示，避免通过锁定提示探测邮箱是否注册
+func checkLoginAllowed(user *models.User, email, ip string) error {
+	ipLockedUntil, err := ipLockedUntil(ip)
+	if err != nil {
+		return err
+	}
+	if ipLockedUntil != nil && ipLockedUntil.After(time.Now()) {
+		return fmt.Errorf("%w，请于 %s 后重试", ErrLoginThrottled, utils.FormatTime2Str(*ipLockedUntil))
+	}
+	var lockedUntil *time.Time
+	if user != nil {
+		lockedUntil = user.LockedUntil
+	} else {
+		var err error
+		if lockedUntil, err = unknownEmailLockedUntil(email); err != nil {
+			return err
+		}
+	}
+	if lockedUntil != nil && lockedUntil.After(time.Now()) {
+		return fmt.Errorf("%w，请于 %s 后重试", ErrAccountLocked, utils.FormatTime2Str(*lockedUntil))
+	}
+	return nil
+}
+
+// ipLockedUntil 根据IP在窗口时间内的失败次数和最近一次失败的时间计算该IP下次允许登录的时间
+func ipLockedUntil(ip string) (*time.Time, error) {
+	since := time.Now().Local().Add(-loginIPWindow)
+	var failures int64
+	if err := models.DB.Model(&models.LoginAttempt{}).
+		Where("ip = ? AND success = ? AND created_at > ?", ip, false, since).
+		Count(&failures).Error; err != nil {
+		return nil, err
+	}
+	backoff := loginIPBackoff(uint(failures))
+	if backoff == 0 {
+		return nil, nil
+	}
+	var last models.LoginAttempt
+	if err := models.DB.Where("ip = ? AND success = ? AND created_at > ?", ip, false, since).
+		Order("id DESC").First(&last).Error; err != nil {
+		return nil, err
+	}
+	lockedUntil := last.CreatedAt.Add(backoff)
+	return &lockedUntil, nil
+}
+
+// unknownEmailLockedUntil 根据未注册邮箱的失败次数和最近一次失败的时间计算下次允许登录的时间
+func unknownEmailLockedUntil(email string) (*time.Time, error) {
+	var failures int64
+	if err := models.DB.Model(&models.LoginAttempt{}).
+		Where("email = ? AND user_id = 0 AND success = ?", email, false).
+		Count(&failures).Error; err != nil {
+		return nil, err
+	}
+	if failures == 0 {
+		return nil, nil
+	}
+	var last models.LoginAttempt
+	if err := models.DB.Where("email = ? AND user_id = 0 AND success = ?", email, false).
+		Order("id DESC").First(&last).Error; err != nil {
+		return nil, err
+	}
+	lockedUntil := last.CreatedAt.Add(loginBackoff(uint(failures)))
+	return &lockedUntil, nil
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// dummyPasswordHash 返回一个随机密码的哈希，邮箱未注册时用于比较密码
+func dummyPasswordHash() []byte {
+	dummyHashOnce.Do(func() {
+		password, _ := newRandomToken()
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	})
+	return dummyHash
+}
+
+// recordLoginFailure 记录一次失败的登录，邮箱已注册时递增连续失败次数并设置下次允许登录的时间
+// 失败次数在数据库中递增后重新读取，并发失败时按递增后的次数计算锁定时间
+func recordLoginFailure(user *models.User, email, ip string) error {
+	now := time.Now().Local()
+	attempt := models.LoginAttempt{Email: email, IP: ip, CreatedAt: now}
+	if user == nil {
+		return models.DB.Create(&attempt).Error
+	}
+	attempt.UserID = user.ID
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attempt).Error; err != nil {
+			return err
+		}
+		// 递增失败次数的更新会锁住该行，直到事务结束前其他请求的递增都需要等待
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).
+			Update("failed_login_count", gorm.Expr("failed_login_count + 1")).Error; err != nil {
+			return err
+		}
+		var failures uint
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).
+			Pluck("failed_login_count", &failures).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).
+			Update("locked_until", now.Add(loginBackoff(failures))).Error
+	})
+}
+
+// recordLoginSuccess 在事务中记录一次成功的登录并清除失败计数，返回上次登录成功后的失败记录
+func recordLoginSuccess(tx *gorm.DB, user *models.User, ip string, now time.Time) ([]dto.FailedLoginInfo, error) {
+	var failures []models.LoginAttempt
+	if err := tx.Where("user_id = ? AND success = ? AND created_at > ?", user.ID, false, user.LastLoginTime).
+		Order("id DESC").Limit(failedLoginListLimit).Find(&failures).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&models.LoginAttempt{UserID: user.ID, Email: user.Email, IP: ip, Success: true, CreatedAt: now}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(user).Updates(map[string]any{"failed_login_count": 0, "locked_until": nil}).Error; err != nil {
+		return nil, err
+	}
+	infos := make([]dto.FailedLoginInfo, len(failures))
+	for i, failure := range failures {
+		infos[i] = dto.FailedLoginInfo{IP: failure.IP, Time: utils.FormatTime2Str(failure.CreatedAt)}
+	}
+	return infos, nil
+}
+
+// UnlockUser 管理员解除账户的登录锁定
+func UnlockUser(email string) error {
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return err
+	}
+	if err := models.DB.Model(&models.User{}).Where("id = ?", userId).
+		Updates(map[string]any{"failed_login_count": 0, "locked_until": nil}).Error; err != nil {
+		return errors.New("无法解除锁定")
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"volunteer-system-backend/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoginLockoutDoesNotRevealRegisteredEmails(t *testing.T) {
+	setupTestDB(t)
+	createTestUser(t, "volunteer@example.com")
+
+	for _, email := range []string{"volunteer@example.com", "nobody@example.com"} {
+		_, err := LoginUser(email, "wrong-password", "192.0.2.1")
+		if err == nil || err.Error() != "邮箱或密码无效" {
+			t.Fatalf("%s 首次密码错误应返回邮箱或密码无效，实际为 %v", email, err)
+		}
+		// 第一次失败后需要等待1秒，立即重试时已注册和未注册的邮箱都应提示账户被锁定
+		if _, err := LoginUser(email, "wrong-password", "192.0.2.1"); !errors.Is(err, ErrAccountLocked) {
+			t.Fatalf("%s 在等待时间内重试应返回 ErrAccountLocked，实际为 %v", email, err)
+		}
+	}
+}
+
+func TestRecordLoginFailureUsesIncrementedCount(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "volunteer@example.com")
+
+	// 使用同一个过期的用户记录连续记录失败，锁定时间应按数据库中递增后的次数计算
+	stale := *user
+	maxFails, lockout := loginLockPolicy()
+	for i := uint(0); i < maxFails; i++ {
+		if err := recordLoginFailure(&stale, stale.Email, "192.0.2.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var saved models.User
+	if err := models.DB.First(&saved, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.FailedLoginCount != maxFails {
+		t.Fatalf("失败次数应为 %d，实际为 %d", maxFails, saved.FailedLoginCount)
+	}
+	if saved.LockedUntil == nil || saved.LockedUntil.Before(time.Now().Add(lockout-time.Minute)) {
+		t.Fatalf("达到失败次数后应锁定 %v，实际锁定到 %v", lockout, saved.LockedUntil)
+	}
+}
+
+func TestIPBackoffGrowsWithFailures(t *testing.T) {
+	setupTestDB(t)
+	ip := "192.0.2.20"
+	addFailures := func(count int, at time.Time) {
+		t.Helper()
+		for i := 0; i < count; i++ {
+			if err := models.DB.Create(&models.LoginAttempt{Email: "nobody@example.com", IP: ip, CreatedAt: at}).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// 共用IP的前几次失败只需要等待几秒
+	addFailures(int(loginIPLimit), time.Now().Local().Add(-time.Minute))
+	if err := checkLoginAllowed(nil, "someone@example.com", ip); err != nil {
+		t.Fatalf("超过等待时间后应允许登录，实际为 %v", err)
+	}
+
+	maxFails, lockout := loginLockPolicy()
+	addFailures(int(maxFails)-1, time.Now().Local().Add(-time.Minute))
+	if err := checkLoginAllowed(nil, "someone@example.com", ip); !errors.Is(err, ErrLoginThrottled) {
+		t.Fatalf("IP连续失败后应被限制，实际为 %v", err)
+	}
+	if got, want := loginIPBackoff(loginIPLimit+maxFails), 2*lockout; got != want {
+		t.Fatalf("继续失败后等待时间应翻倍为 %v，实际为 %v", want, got)
+	}
+	if err := checkLoginAllowed(nil, "someone@example.com", "192.0.2.21"); err != nil {
+		t.Fatalf("其他IP不应受影响，实际为 %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"volunteer-system-backend/config"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/utils"
+	"encoding/base64"
+	"github.com/glebarez/sqlite"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupTestDB 为每个测试创建独立的 SQLite 数据库，执行与 MySQL 相同的迁移并使用默认配置，返回使用该数据库的通知服务
+func setupTestDB(t *testing.T) NotificationService {
+	t.Helper()
+	config.ProjectConfig = &config.Config{}
+	config.ProjectConfig.Volunteer.TwtKey = "test-jwt-key"
+	config.ProjectConfig.Account.TOTPKeyFile = filepath.Join(t.TempDir(), "totp.key")
+	if err := os.WriteFile(config.ProjectConfig.Account.TOTPKeyFile, []byte(base64.StdEncoding.EncodeToString(make([]byte, 32))), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.LoadTOTPKey(); err != nil {
+		t.Fatalf("无法加载两步验证加密密钥: %v", err)
+	}
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=busy_timeout(5000)&_pragma=synchronous(OFF)&_pragma=journal_mode(MEMORY)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("无法打开测试数据库: %v", err)
+	}
+	models.DB = db
+	if err := models.Migrate(); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return NewNotificationService(db, NewMessageHub())
+}
+
+// createTestUser 创建已验证邮箱的志愿者，密码为 Passw0rd!test
+func createTestUser(t *testing.T, email string) *models.User {
+	t.Helper()
+	role, err := models.GetRoleByName(models.RoleVolunteer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte("Passw0rd!test"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Local()
+	user := models.User{
+		Email:           email,
+		Nickname:        email,
+		Gender:          "保密",
+		Password:        string(hashed),
+		CreatedAt:       now,
+		RoleID:          role.ID,
+		LastLoginTime:   now,
+		EmailVerifiedAt: &now,
+	}
+	if err := models.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+// createTestOrg 创建组织
+func createTestOrg(t *testing.T, name string) uint {
+	t.Helper()
+	org, err := CreateOrganization(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return org.ID
+}
+
+// addTestMember 直接将用户以指定角色加入组织
+func addTestMember(t *testing.T, orgID uint, user *models.User, roleName string) {
+	t.Helper()
+	role, err := models.GetRoleByName(roleName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.DB.Create(&models.OrganizationMember{
+		OrganizationID: orgID,
+		UserID:         user.ID,
+		RoleID:         role.ID,
+		CreatedAt:      time.Now().Local(),
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createTestTask 在组织中创建一个明天开始、持续两小时的活动
+func createTestTask(t *testing.T, orgID uint, name string, limit uint) *models.Task {
+	t.Helper()
+	start := time.Now().Local().Add(24 * time.Hour)
+	task := models.Task{
+		OrganizationID: orgID,
+		Name:           name,
+		CreatedAt:      time.Now().Local(),
+		StartTime:      start,
+		EndTime:        start.Add(2 * time.Hour),
+		Location:       "图书馆",
+		Limit:          limit,
+	}
+	if err := models.DB.Create(&task).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &task
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"volunteer-system-backend/models"
+	"sync"
+)
+
+// messageBufferSize 每个订阅者的消息缓冲区大小，缓冲区满时断开该订阅者，客户端重连时通过 Last-Event-ID 补齐
+const messageBufferSize = 16
+
+// MessageHub 进程内的消息发布订阅中心，同一用户可以有多个订阅者（多个浏览器标签页）
+type MessageHub struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[chan models.Message]struct{}
+}
+
+// DefaultHub 默认的消息发布订阅中心，消息写入数据库后通过它推送给在线客户端
+var DefaultHub = NewMessageHub()
+
+// NewMessageHub 创建新的消息发布订阅中心
+func NewMessageHub() *MessageHub {
+	return &MessageHub{
+		subscribers: make(map[uint]map[chan models.Message]struct{}),
+	}
+}
+
+// Subscribe 订阅指定用户的新消息，返回消息通道和取消订阅函数
+func (h *MessageHub) Subscribe(userID uint) (<-chan models.Message, func()) {
+	ch := make(chan models.Message, messageBufferSize)
+	h.mu.Lock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan models.Message]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(userID, ch)
+	}
+	return ch, unsubscribe
+}
+
+// remove 移除并关闭订阅者的消息通道，调用方需要持有写锁，已经移除的订阅者不会重复关闭
+func (h *MessageHub) remove(userID uint, ch chan models.Message) {
+	if _, ok := h.subscribers[userID][ch]; !ok {
+		return
+	}
+	delete(h.subscribers[userID], ch)
+	if len(h.subscribers[userID]) == 0 {
+		delete(h.subscribers, userID)
+	}
+	close(ch)
+}
+
+// Publish 将消息推送给接收者的所有订阅者，不会阻塞调用方
+// 缓冲区已满的订阅者接收过慢，直接断开其连接而不是丢弃消息，客户端重连后从数据库补齐
+func (h *MessageHub) Publish(message models.Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[message.UserID] {
+		select {
+		case ch <- message:
+		default:
+			h.remove(message.UserID, ch)
+		}
+	}
+}
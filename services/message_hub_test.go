@@ -0,0 +1,50 @@
+package services
+
+import (
+	"volunteer-system-backend/models"
+	"testing"
+)
+
+func TestMessageHubDisconnectsSlowSubscriber(t *testing.T) {
+	hub := NewMessageHub()
+	slow, unsubscribeSlow := hub.Subscribe(1)
+	fast, unsubscribeFast := hub.Subscribe(1)
+	defer unsubscribeFast()
+
+	for i := 1; i <= messageBufferSize+1; i++ {
+		hub.Publish(models.Message{ID: uint(i), UserID: 1})
+		// 及时接收的订阅者不受影响
+		if message := <-fast; message.ID != uint(i) {
+			t.Fatalf("应收到消息 %d，实际为 %d", i, message.ID)
+		}
+	}
+	// 缓冲区中已有的消息仍然可以读出，之后通道被关闭
+	received := 0
+	for range slow {
+		received++
+	}
+	if received != messageBufferSize {
+		t.Fatalf("接收过慢的订阅者应先读出 %d 条消息，实际为 %d", messageBufferSize, received)
+	}
+	// 已被断开的订阅者再次取消订阅不会重复关闭通道
+	unsubscribeSlow()
+
+	hub.Publish(models.Message{ID: 100, UserID: 1})
+	if message := <-fast; message.ID != 100 {
+		t.Fatalf("应收到消息 100，实际为 %d", message.ID)
+	}
+}
+
+func TestMessageHubUnsubscribe(t *testing.T) {
+	hub := NewMessageHub()
+	messages, unsubscribe := hub.Subscribe(1)
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-messages; ok {
+		t.Fatal("取消订阅后通道应被关闭")
+	}
+	hub.Publish(models.Message{ID: 1, UserID: 1})
+	if len(hub.subscribers) != 0 {
+		t.Fatal("取消订阅后不应保留订阅者")
+	}
+}
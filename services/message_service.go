@@ -1,65 +1,197 @@
 package services
 
 import (
+	"volunteer-system-backend/dto"
 	"volunteer-system-backend/models"
+	"errors"
 	"gorm.io/gorm"
 	"log"
 	"time"
 )
 
-// MessageService 消息服务接口
+var (
+	// ErrMessageForbidden 没有操作该消息的权限
+	ErrMessageForbidden = errors.New("没有操作该消息的权限")
+	// ErrMessageNotFound 消息不存在
+	ErrMessageNotFound = errors.New("消息不存在")
+)
+
+// MessageService 消息服务接口，所有方法都以当前操作用户的身份进行鉴权
 type MessageService interface {
-	GetMessagesByUserID(userID uint) ([]models.Message, error)
-	CreateMessage(userID uint, title, content string) (*models.Message, error)
-	MarkMessageAsRead(messageID uint) error
+	ListMessages(actor *models.User, orgID uint, query *dto.MessageQuery) ([]models.Message, int64, error)
+	MessagesSince(actor *models.User, orgID uint, lastID uint) ([]models.Message, error)
+	CountUnread(actor *models.User, orgID uint) (int64, error)
+	CreateMessage(actor *models.User, orgID, userID uint, category, title, content string) (*models.Message, error)
+	MarkMessageAsRead(actor *models.User, messageID uint) error
+	MarkAllAsRead(actor *models.User, orgID uint) (int64, error)
+	DeleteMessage(actor *models.User, messageID uint) error
+	Subscribe(actor *models.User) (<-chan models.Message, func())
 }
 
+const (
+	defaultMessagePageSize = 20
+	maxMessagePageSize     = 100
+)
+
 // messageServiceImpl 消息服务实现
 type messageServiceImpl struct {
-	db *gorm.DB
+	db            *gorm.DB
+	hub           *MessageHub
+	notifications NotificationService
 }
 
-// NewMessageService 创建新的消息服务实例
-func NewMessageService() MessageService {
+// NewMessageService 创建新的消息服务实例，notifications 为写入消息使用的通知服务
+func NewMessageService(notifications NotificationService) MessageService {
 	if models.DB == nil {
 		log.Println("数据库连接未初始化")
 	}
 	return &messageServiceImpl{
-		db: models.DB,
+		db:            models.DB,
+		hub:           DefaultHub,
+		notifications: notifications,
 	}
 }
 
-// GetMessagesByUserID 根据用户ID获取消息列表
-func (s *messageServiceImpl) GetMessagesByUserID(userID uint) ([]models.Message, error) {
+// inbox 当前用户在当前组织内可见且未过期的消息，系统消息在所有组织中可见
+func (s *messageServiceImpl) inbox(actor *models.User, orgID uint) *gorm.DB {
+	return s.db.Model(&models.Message{}).Where("user_id = ? AND organization_id IN ?", actor.ID, []uint{0, orgID}).
+		Where("(expires_at IS NULL OR expires_at > ?)", time.Now())
+}
+
+// ListMessages 分页获取当前用户的消息列表，按时间倒序排列，并将 query 中的分页参数修正为实际使用的值
+func (s *messageServiceImpl) ListMessages(actor *models.User, orgID uint, query *dto.MessageQuery) ([]models.Message, int64, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = defaultMessagePageSize
+	}
+	if query.PageSize > maxMessagePageSize {
+		query.PageSize = maxMessagePageSize
+	}
+	db := s.inbox(actor, orgID)
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.Category != "" {
+		db = db.Where("category = ?", query.Category)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var messages []models.Message
+	err := db.Order("time DESC").Order("id DESC").
+		Offset((query.Page - 1) * query.PageSize).Limit(query.PageSize).
+		Find(&messages).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
+// MessagesSince 获取当前用户ID大于 lastID 的一批消息，按ID升序排列，用于断线重连后补齐消息，调用方需要分批获取直到返回为空
+func (s *messageServiceImpl) MessagesSince(actor *models.User, orgID uint, lastID uint) ([]models.Message, error) {
 	var messages []models.Message
-	err := s.db.Where("user_id = ?", userID).Find(&messages).Error
+	err := s.inbox(actor, orgID).Where("id > ?", lastID).Order("id").Limit(maxMessagePageSize).Find(&messages).Error
 	if err != nil {
 		return nil, err
 	}
 	return messages, nil
 }
 
-// CreateMessage 创建新消息
-func (s *messageServiceImpl) CreateMessage(userID uint, title, content string) (*models.Message, error) {
-	message := &models.Message{
-		UserID:  userID,
-		Title:   title,
-		Content: content,
-		Time:    time.Now(),
-		Status:  "unread",
+// CountUnread 统计当前用户的未读消息数
+func (s *messageServiceImpl) CountUnread(actor *models.User, orgID uint) (int64, error) {
+	var count int64
+	if err := s.inbox(actor, orgID).Where("status = ?", "unread").Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CreateMessage 创建新消息，发送者需要在当前组织中拥有 message:send 权限，接收者必须是该组织成员
+func (s *messageServiceImpl) CreateMessage(actor *models.User, orgID, userID uint, category, title, content string) (*models.Message, error) {
+	if category == "" {
+		category = models.MessageCategorySystem
 	}
-	err := s.db.Create(message).Error
+	if !isValidMessageCategory(category) {
+		return nil, errors.New("无效的消息分类")
+	}
+	ok, err := HasPermission(actor.Email, orgID, models.PermMessageSend)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrMessageForbidden
+	}
+	if !IsOrganizationMember(userID, orgID) {
+		return nil, errors.New("接收者不是本组织成员")
+	}
+	messages := []models.Message{{
+		OrganizationID: orgID,
+		UserID:         userID,
+		Category:       category,
+		Title:          title,
+		Content:        content,
+		Time:           time.Now(),
+		Status:         "unread",
+	}}
+	messages, err = s.notifications.Send(messages)
 	if err != nil {
 		return nil, err
 	}
-	return message, nil
+	return &messages[0], nil
 }
 
-// MarkMessageAsRead 将消息标记为已读
-func (s *messageServiceImpl) MarkMessageAsRead(messageID uint) error {
-	err := s.db.Model(&models.Message{}).Where("id = ?", messageID).Update("status", "read").Error
+// MarkMessageAsRead 将当前用户的消息标记为已读
+func (s *messageServiceImpl) MarkMessageAsRead(actor *models.User, messageID uint) error {
+	var message models.Message
+	if err := s.db.Where("id = ?", messageID).First(&message).Error; err != nil {
+		return ErrMessageNotFound
+	}
+	if message.UserID != actor.ID {
+		return ErrMessageForbidden
+	}
+	err := s.db.Model(&message).Update("status", "read").Error
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// MarkAllAsRead 将当前用户在当前组织内的所有消息标记为已读，返回被更新的消息数
+func (s *messageServiceImpl) MarkAllAsRead(actor *models.User, orgID uint) (int64, error) {
+	result := s.inbox(actor, orgID).Where("status = ?", "unread").Update("status", "read")
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// DeleteMessage 删除当前用户的消息（软删除）
+func (s *messageServiceImpl) DeleteMessage(actor *models.User, messageID uint) error {
+	var message models.Message
+	if err := s.db.Where("id = ?", messageID).First(&message).Error; err != nil {
+		return ErrMessageNotFound
+	}
+	if message.UserID != actor.ID {
+		return ErrMessageForbidden
+	}
+	return s.db.Delete(&message).Error
+}
+
+// isValidMessageCategory 判断消息分类是否有效
+func isValidMessageCategory(category string) bool {
+	switch category {
+	case models.MessageCategorySystem, models.MessageCategoryApproval,
+		models.MessageCategoryReminder, models.MessageCategoryAnnouncement:
+		return true
+	}
+	return false
+}
+
+// Subscribe 订阅当前用户的新消息
+func (s *messageServiceImpl) Subscribe(actor *models.User) (<-chan models.Message, func()) {
+	return s.hub.Subscribe(actor.ID)
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+// createTestMessage 直接写入一条未读消息
+func createTestMessage(t *testing.T, userID, orgID uint, title string, expiresAt *time.Time) *models.Message {
+	t.Helper()
+	message := models.Message{
+		UserID:         userID,
+		OrganizationID: orgID,
+		Category:       models.MessageCategorySystem,
+		Title:          title,
+		Content:        title,
+		Time:           time.Now(),
+		Status:         "unread",
+		ExpiresAt:      expiresAt,
+	}
+	if err := models.DB.Create(&message).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &message
+}
+
+func TestListMessagesOnlyReturnsOwnVisibleMessages(t *testing.T) {
+	notifications := setupTestDB(t)
+	orgA := createTestOrg(t, "组织A")
+	orgB := createTestOrg(t, "组织B")
+	alice := createTestUser(t, "alice@example.com")
+	bob := createTestUser(t, "bob@example.com")
+	expired := time.Now().Add(-time.Hour)
+
+	createTestMessage(t, alice.ID, orgA, "组织A消息", nil)
+	createTestMessage(t, alice.ID, 0, "系统消息", nil)
+	createTestMessage(t, alice.ID, orgB, "组织B消息", nil)
+	createTestMessage(t, alice.ID, orgA, "过期消息", &expired)
+	createTestMessage(t, bob.ID, orgA, "其他用户的消息", nil)
+
+	service := NewMessageService(notifications)
+	query := &dto.MessageQuery{Page: 0, PageSize: 1000}
+	messages, total, err := service.ListMessages(alice, orgA, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(messages) != 2 {
+		t.Fatalf("应返回 2 条消息，实际总数 %d，返回 %d", total, len(messages))
+	}
+	for _, message := range messages {
+		if message.Title != "组织A消息" && message.Title != "系统消息" {
+			t.Fatalf("返回了不可见的消息 %q", message.Title)
+		}
+	}
+	if query.Page != 1 || query.PageSize != maxMessagePageSize {
+		t.Fatalf("分页参数应被修正为 1/%d，实际为 %d/%d", maxMessagePageSize, query.Page, query.PageSize)
+	}
+
+	unread, err := service.CountUnread(alice, orgA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unread != 2 {
+		t.Fatalf("未读消息数应为 2，实际为 %d", unread)
+	}
+	since, err := service.MessagesSince(alice, orgB, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(since) != 2 || since[0].Title != "系统消息" || since[1].Title != "组织B消息" {
+		t.Fatalf("组织B中补齐的消息不正确: %+v", since)
+	}
+}
+
+func TestMessageOwnershipIsEnforced(t *testing.T) {
+	notifications := setupTestDB(t)
+	orgA := createTestOrg(t, "组织A")
+	alice := createTestUser(t, "alice@example.com")
+	bob := createTestUser(t, "bob@example.com")
+	message := createTestMessage(t, alice.ID, orgA, "消息", nil)
+	service := NewMessageService(notifications)
+
+	if err := service.MarkMessageAsRead(bob, message.ID); !errors.Is(err, ErrMessageForbidden) {
+		t.Fatalf("其他用户标记已读应返回 ErrMessageForbidden，实际为 %v", err)
+	}
+	if err := service.DeleteMessage(bob, message.ID); !errors.Is(err, ErrMessageForbidden) {
+		t.Fatalf("其他用户删除应返回 ErrMessageForbidden，实际为 %v", err)
+	}
+	if err := service.MarkMessageAsRead(alice, message.ID+100); !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("不存在的消息应返回 ErrMessageNotFound，实际为 %v", err)
+	}
+	if count, err := service.MarkAllAsRead(bob, orgA); err != nil || count != 0 {
+		t.Fatalf("其他用户全部已读不应影响该消息，更新了 %d 条: %v", count, err)
+	}
+
+	if err := service.MarkMessageAsRead(alice, message.ID); err != nil {
+		t.Fatal(err)
+	}
+	var saved models.Message
+	if err := models.DB.First(&saved, message.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != "read" {
+		t.Fatalf("消息状态应为 read，实际为 %q", saved.Status)
+	}
+	if err := service.DeleteMessage(alice, message.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.DeleteMessage(alice, message.ID); !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("已删除的消息应返回 ErrMessageNotFound，实际为 %v", err)
+	}
+}
+
+func TestMarkAllAsReadIsScopedToOrganization(t *testing.T) {
+	notifications := setupTestDB(t)
+	orgA := createTestOrg(t, "组织A")
+	orgB := createTestOrg(t, "组织B")
+	alice := createTestUser(t, "alice@example.com")
+	createTestMessage(t, alice.ID, orgA, "组织A消息", nil)
+	createTestMessage(t, alice.ID, 0, "系统消息", nil)
+	other := createTestMessage(t, alice.ID, orgB, "组织B消息", nil)
+
+	count, err := NewMessageService(notifications).MarkAllAsRead(alice, orgA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("应更新 2 条消息，实际为 %d", count)
+	}
+	var saved models.Message
+	if err := models.DB.First(&saved, other.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != "unread" {
+		t.Fatal("其他组织的消息不应被标记为已读")
+	}
+}
+
+func TestCreateMessageRequiresPermissionAndMembership(t *testing.T) {
+	notifications := setupTestDB(t)
+	orgA := createTestOrg(t, "组织A")
+	orgB := createTestOrg(t, "组织B")
+	admin := createTestUser(t, "admin@example.com")
+	volunteer := createTestUser(t, "volunteer@example.com")
+	outsider := createTestUser(t, "outsider@example.com")
+	addTestMember(t, orgA, admin, models.RoleOrgAdmin)
+	addTestMember(t, orgA, volunteer, models.RoleVolunteer)
+	addTestMember(t, orgB, outsider, models.RoleVolunteer)
+	service := NewMessageService(notifications)
+
+	if _, err := service.CreateMessage(volunteer, orgA, admin.ID, "", "标题", "内容"); !errors.Is(err, ErrMessageForbidden) {
+		t.Fatalf("志愿者发送消息应返回 ErrMessageForbidden，实际为 %v", err)
+	}
+	if _, err := service.CreateMessage(admin, orgB, outsider.ID, "", "标题", "内容"); !errors.Is(err, ErrMessageForbidden) {
+		t.Fatalf("在其他组织中发送消息应返回 ErrMessageForbidden，实际为 %v", err)
+	}
+	if _, err := service.CreateMessage(admin, orgA, outsider.ID, "", "标题", "内容"); err == nil {
+		t.Fatal("不应能向其他组织的成员发送消息")
+	}
+	if _, err := service.CreateMessage(admin, orgA, volunteer.ID, "unknown", "标题", "内容"); err == nil {
+		t.Fatal("无效的消息分类应被拒绝")
+	}
+
+	message, err := service.CreateMessage(admin, orgA, volunteer.ID, "", "标题", "内容")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.ID == 0 || message.UserID != volunteer.ID || message.OrganizationID != orgA || message.Category != models.MessageCategorySystem {
+		t.Fatalf("创建的消息不正确: %+v", message)
+	}
+}
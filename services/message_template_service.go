@@ -0,0 +1,146 @@
+package services
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/utils"
+	"bytes"
+	"errors"
+	"gorm.io/gorm"
+	"text/template"
+	"time"
+)
+
+// MessageData 渲染消息模板使用的变量
+type MessageData map[string]any
+
+// messageTemplateSample 校验和预览模板时使用的示例数据，包含所有模板可用的变量
+var messageTemplateSample = MessageData{
+	"Nickname":  "张三",
+	"TaskName":  "冬至晚会",
+	"TeamName":  "青年志愿队",
+	"StartTime": "2024-12-21 18:00:00",
+	"Location":  "大礼堂",
+	"Content":   "请问活动需要自带水杯吗？",
+}
+
+// isSupportedLocale 判断是否为支持的语言
+func isSupportedLocale(locale string) bool {
+	return locale == models.LocaleZhCN || locale == models.LocaleEnUS
+}
+
+// executeMessageTemplate 使用数据渲染模板文本，模板引用不存在的变量时返回错误
+func executeMessageTemplate(text string, data MessageData) (string, error) {
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]any(data)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// findMessageTemplate 查询事件类型在指定语言下的模板，没有该语言的模板时使用默认语言
+func findMessageTemplate(db *gorm.DB, eventType, locale string) (models.MessageTemplate, error) {
+	var tmpl models.MessageTemplate
+	if err := db.Where("event_type = ? AND locale = ?", eventType, locale).First(&tmpl).Error; err == nil {
+		return tmpl, nil
+	}
+	if err := db.Where("event_type = ? AND locale = ?", eventType, models.DefaultLocale).First(&tmpl).Error; err != nil {
+		return models.MessageTemplate{}, errors.New("消息模板不存在: " + eventType)
+	}
+	return tmpl, nil
+}
+
+// renderMessage 按事件类型和语言渲染消息的分类、标题和内容
+func renderMessage(db *gorm.DB, eventType, locale string, data MessageData) (string, string, string, error) {
+	tmpl, err := findMessageTemplate(db, eventType, locale)
+	if err != nil {
+		return "", "", "", err
+	}
+	title, err := executeMessageTemplate(tmpl.Title, data)
+	if err != nil {
+		return "", "", "", errors.New("渲染消息标题失败: " + err.Error())
+	}
+	content, err := executeMessageTemplate(tmpl.Content, data)
+	if err != nil {
+		return "", "", "", errors.New("渲染消息内容失败: " + err.Error())
+	}
+	return tmpl.Category, title, content, nil
+}
+
+// GetMessageTemplates 获取所有消息模板
+func GetMessageTemplates() ([]dto.MessageTemplateInfo, error) {
+	var templates []models.MessageTemplate
+	if err := models.DB.Order("event_type").Order("locale").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	infos := make([]dto.MessageTemplateInfo, len(templates))
+	for i, tmpl := range templates {
+		infos[i] = dto.MessageTemplateInfo{
+			EventType: tmpl.EventType,
+			Locale:    tmpl.Locale,
+			Category:  tmpl.Category,
+			Title:     tmpl.Title,
+			Content:   tmpl.Content,
+			UpdatedAt: utils.FormatTime2Str(tmpl.UpdatedAt),
+		}
+	}
+	return infos, nil
+}
+
+// UpdateMessageTemplate 修改消息模板，保存前使用示例数据校验模板语法和变量
+// 事件类型在该语言下还没有模板时新建一个，消息分类与默认语言的模板保持一致
+func UpdateMessageTemplate(editorEmail string, input dto.UpdateMessageTemplateRequest) error {
+	editorId, err := GetUserIDByEmail(editorEmail)
+	if err != nil {
+		return err
+	}
+	if !isSupportedLocale(input.Locale) {
+		return errors.New("不支持的语言")
+	}
+	base, err := findMessageTemplate(models.DB, input.EventType, models.DefaultLocale)
+	if err != nil {
+		return err
+	}
+	if _, err := PreviewMessageTemplate(dto.PreviewMessageTemplateRequest{Title: input.Title, Content: input.Content}); err != nil {
+		return err
+	}
+	tmpl := models.MessageTemplate{EventType: input.EventType, Locale: input.Locale}
+	if err := models.DB.Where(tmpl).Attrs(models.MessageTemplate{Category: base.Category}).FirstOrInit(&tmpl).Error; err != nil {
+		return err
+	}
+	tmpl.Title = input.Title
+	tmpl.Content = input.Content
+	tmpl.UpdatedBy = editorId
+	tmpl.UpdatedAt = time.Now().Local()
+	if err := models.DB.Save(&tmpl).Error; err != nil {
+		return errors.New("无法保存消息模板")
+	}
+	return nil
+}
+
+// PreviewMessageTemplate 使用提交的数据或示例数据渲染模板
+func PreviewMessageTemplate(input dto.PreviewMessageTemplateRequest) (dto.MessagePreview, error) {
+	data := messageTemplateSample
+	if len(input.Data) > 0 {
+		data = make(MessageData, len(messageTemplateSample))
+		for key, value := range messageTemplateSample {
+			data[key] = value
+		}
+		for key, value := range input.Data {
+			data[key] = value
+		}
+	}
+	title, err := executeMessageTemplate(input.Title, data)
+	if err != nil {
+		return dto.MessagePreview{}, errors.New("标题模板错误: " + err.Error())
+	}
+	content, err := executeMessageTemplate(input.Content, data)
+	if err != nil {
+		return dto.MessagePreview{}, errors.New("内容模板错误: " + err.Error())
+	}
+	return dto.MessagePreview{Title: title, Content: content}, nil
+}
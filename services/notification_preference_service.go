@@ -0,0 +1,227 @@
+package services
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+	_ "time/tzdata" // 内置时区数据，保证在没有系统时区数据的环境中也能解析用户时区
+)
+
+// quietHoursLayout 免打扰时间的格式
+const quietHoursLayout = "15:04"
+
+// messageCategories 所有消息分类
+var messageCategories = []string{
+	models.MessageCategorySystem,
+	models.MessageCategoryApproval,
+	models.MessageCategoryReminder,
+	models.MessageCategoryAnnouncement,
+}
+
+// isUrgentCategory 紧急的消息分类不会被合并到每日摘要
+func isUrgentCategory(category string) bool {
+	return category == models.MessageCategoryApproval || category == models.MessageCategoryReminder
+}
+
+// findNotifier 根据渠道名称查找通知渠道
+func findNotifier(channel string) Notifier {
+	for _, notifier := range notifiers {
+		if notifier.Channel() == channel {
+			return notifier
+		}
+	}
+	return nil
+}
+
+// GetNotificationPreferences 获取用户的通知偏好，返回所有渠道和分类的订阅状态
+func GetNotificationPreferences(email string) (dto.NotificationPreferences, error) {
+	var user models.User
+	if err := models.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return dto.NotificationPreferences{}, errors.New("用户不存在")
+	}
+	preferences, err := loadChannelPreferences(models.DB, []uint{user.ID})
+	if err != nil {
+		return dto.NotificationPreferences{}, err
+	}
+	result := dto.NotificationPreferences{
+		Timezone:        user.Timezone,
+		QuietHoursStart: user.QuietHoursStart,
+		QuietHoursEnd:   user.QuietHoursEnd,
+		DailyDigest:     user.DailyDigest,
+		DigestHour:      user.DigestHour,
+		Locale:          user.Locale,
+		Channels:        []dto.ChannelPreference{},
+	}
+	for _, notifier := range notifiers {
+		for _, category := range messageCategories {
+			result.Channels = append(result.Channels, dto.ChannelPreference{
+				Channel:  notifier.Channel(),
+				Category: category,
+				Enabled:  channelEnabled(preferences, user.ID, notifier, category),
+			})
+		}
+	}
+	return result, nil
+}
+
+// UpdateNotificationPreferences 更新用户的通知偏好，只更新提交的字段，未提交的字段和渠道订阅设置保持不变
+func UpdateNotificationPreferences(email string, input dto.NotificationPreferencesUpdate) error {
+	var user models.User
+	if err := models.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return errors.New("用户不存在")
+	}
+	updates := make(map[string]any)
+	if input.Timezone != nil {
+		timezone := strings.TrimSpace(*input.Timezone)
+		if _, err := time.LoadLocation(timezone); err != nil || timezone == "" {
+			return errors.New("无效的时区")
+		}
+		updates["timezone"] = timezone
+	}
+	if input.QuietHoursStart != nil || input.QuietHoursEnd != nil {
+		if input.QuietHoursStart == nil || input.QuietHoursEnd == nil || (*input.QuietHoursStart == "") != (*input.QuietHoursEnd == "") {
+			return errors.New("免打扰开始时间和结束时间需要同时设置")
+		}
+		if *input.QuietHoursStart != "" {
+			if _, err := time.Parse(quietHoursLayout, *input.QuietHoursStart); err != nil {
+				return errors.New("免打扰开始时间格式错误")
+			}
+			if _, err := time.Parse(quietHoursLayout, *input.QuietHoursEnd); err != nil {
+				return errors.New("免打扰结束时间格式错误")
+			}
+		}
+		updates["quiet_hours_start"] = *input.QuietHoursStart
+		updates["quiet_hours_end"] = *input.QuietHoursEnd
+	}
+	if input.DailyDigest != nil {
+		updates["daily_digest"] = *input.DailyDigest
+	}
+	if input.DigestHour != nil {
+		if *input.DigestHour > 23 {
+			return errors.New("摘要发送时间必须在0到23点之间")
+		}
+		updates["digest_hour"] = *input.DigestHour
+	}
+	if input.Locale != nil {
+		if !isSupportedLocale(*input.Locale) {
+			return errors.New("不支持的语言")
+		}
+		updates["locale"] = *input.Locale
+	}
+	preferences := make([]models.NotificationPreference, len(input.Channels))
+	for i, channel := range input.Channels {
+		if findNotifier(channel.Channel) == nil {
+			return errors.New("无效的通知渠道: " + channel.Channel)
+		}
+		if !isValidMessageCategory(channel.Category) {
+			return errors.New("无效的消息分类: " + channel.Category)
+		}
+		preferences[i] = models.NotificationPreference{
+			UserID:   user.ID,
+			Channel:  channel.Channel,
+			Category: channel.Category,
+			Enabled:  channel.Enabled,
+		}
+	}
+
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&user).Updates(updates).Error; err != nil {
+				return errors.New("无法更新通知偏好")
+			}
+		}
+		if len(preferences) == 0 {
+			return nil
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "channel"}, {Name: "category"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enabled"}),
+		}).Create(&preferences).Error; err != nil {
+			return errors.New("无法更新通知偏好")
+		}
+		return nil
+	})
+}
+
+// loadChannelPreferences 查询用户的渠道订阅设置，以 用户ID -> 渠道/分类 -> 是否接收 的形式返回
+func loadChannelPreferences(db *gorm.DB, userIds []uint) (map[uint]map[string]bool, error) {
+	var preferences []models.NotificationPreference
+	if err := db.Where("user_id IN ?", userIds).Find(&preferences).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[uint]map[string]bool)
+	for _, preference := range preferences {
+		if result[preference.UserID] == nil {
+			result[preference.UserID] = make(map[string]bool)
+		}
+		result[preference.UserID][preference.Channel+"/"+preference.Category] = preference.Enabled
+	}
+	return result, nil
+}
+
+// channelEnabled 判断用户是否接收指定渠道中的某类消息，用户没有设置时使用渠道的默认值
+func channelEnabled(preferences map[uint]map[string]bool, userId uint, notifier Notifier, category string) bool {
+	if enabled, ok := preferences[userId][notifier.Channel()+"/"+category]; ok {
+		return enabled
+	}
+	return notifier.DefaultEnabled(category)
+}
+
+// userLocation 用户所在时区，时区无效时使用服务器时区
+func userLocation(user models.User) *time.Location {
+	if location, err := time.LoadLocation(user.Timezone); err == nil && user.Timezone != "" {
+		return location
+	}
+	return time.Local
+}
+
+// clockOn 将 HH:MM 格式的时间换算为 day 当天在同一时区的时刻
+func clockOn(day time.Time, clock string) (time.Time, bool) {
+	t, err := time.Parse(quietHoursLayout, clock)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location()), true
+}
+
+// deferQuietHours 如果 at 处于用户的免打扰时段内，返回免打扰结束的时间，否则原样返回
+func deferQuietHours(user models.User, at time.Time) time.Time {
+	if user.QuietHoursStart == "" || user.QuietHoursEnd == "" {
+		return at
+	}
+	local := at.In(userLocation(user))
+	start, ok1 := clockOn(local, user.QuietHoursStart)
+	end, ok2 := clockOn(local, user.QuietHoursEnd)
+	if !ok1 || !ok2 || start.Equal(end) {
+		return at
+	}
+	if start.Before(end) {
+		// 免打扰时段在同一天内，例如 12:00-14:00
+		if !local.Before(start) && local.Before(end) {
+			return end.In(at.Location())
+		}
+		return at
+	}
+	// 免打扰时段跨越午夜，例如 22:00-07:00
+	if !local.Before(start) {
+		return end.AddDate(0, 0, 1).In(at.Location())
+	}
+	if local.Before(end) {
+		return end.In(at.Location())
+	}
+	return at
+}
+
+// nextDigestTime 用户下一次接收每日摘要的时间
+func nextDigestTime(user models.User, now time.Time) time.Time {
+	local := now.In(userLocation(user))
+	digestAt := time.Date(local.Year(), local.Month(), local.Day(), int(user.DigestHour), 0, 0, 0, local.Location())
+	if !digestAt.After(local) {
+		digestAt = digestAt.AddDate(0, 0, 1)
+	}
+	return deferQuietHours(user, digestAt.In(now.Location()))
+}
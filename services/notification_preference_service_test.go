@@ -0,0 +1,81 @@
+package services
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"testing"
+)
+
+func TestUpdateNotificationPreferencesIsPartial(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "user@example.com")
+	start, end, hour := "22:00", "07:00", uint(20)
+	if err := UpdateNotificationPreferences(user.Email, dto.NotificationPreferencesUpdate{
+		QuietHoursStart: &start,
+		QuietHoursEnd:   &end,
+		DigestHour:      &hour,
+		Channels:        []dto.ChannelPreference{{Channel: "email", Category: models.MessageCategoryAnnouncement, Enabled: false}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 只提交每日摘要开关时，其他设置保持不变
+	digest := true
+	if err := UpdateNotificationPreferences(user.Email, dto.NotificationPreferencesUpdate{DailyDigest: &digest}); err != nil {
+		t.Fatal(err)
+	}
+	preferences, err := GetNotificationPreferences(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !preferences.DailyDigest || preferences.QuietHoursStart != start || preferences.QuietHoursEnd != end ||
+		preferences.DigestHour != hour || preferences.Timezone != "Asia/Shanghai" || preferences.Locale != models.LocaleZhCN {
+		t.Fatalf("未提交的字段被修改: %+v", preferences)
+	}
+	for _, channel := range preferences.Channels {
+		if channel.Channel == "email" && channel.Category == models.MessageCategoryAnnouncement && channel.Enabled {
+			t.Fatal("未提交的渠道订阅设置被修改")
+		}
+	}
+
+	// 同时提交空字符串关闭免打扰
+	empty := ""
+	if err := UpdateNotificationPreferences(user.Email, dto.NotificationPreferencesUpdate{QuietHoursStart: &empty, QuietHoursEnd: &empty}); err != nil {
+		t.Fatal(err)
+	}
+	preferences, err = GetNotificationPreferences(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preferences.QuietHoursStart != "" || preferences.QuietHoursEnd != "" || preferences.DigestHour != hour || !preferences.DailyDigest {
+		t.Fatalf("关闭免打扰后的设置不正确: %+v", preferences)
+	}
+}
+
+func TestUpdateNotificationPreferencesValidation(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "user@example.com")
+	start, invalid, empty, unknown := "22:00", "25:61", "", "xx-XX"
+	late := uint(24)
+	cases := map[string]dto.NotificationPreferencesUpdate{
+		"只提交免打扰开始时间":  {QuietHoursStart: &start},
+		"免打扰结束时间为空":   {QuietHoursStart: &start, QuietHoursEnd: &empty},
+		"免打扰结束时间格式错误": {QuietHoursStart: &start, QuietHoursEnd: &invalid},
+		"摘要发送时间超出范围":  {DigestHour: &late},
+		"不支持的语言":      {Locale: &unknown},
+		"空时区":         {Timezone: &empty},
+		"无效的通知渠道":     {Channels: []dto.ChannelPreference{{Channel: "sms", Category: models.MessageCategorySystem}}},
+	}
+	for name, input := range cases {
+		if err := UpdateNotificationPreferences(user.Email, input); err == nil {
+			t.Errorf("%s 应被拒绝", name)
+		}
+	}
+	var saved models.User
+	if err := models.DB.First(&saved, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.QuietHoursStart != "" || saved.DigestHour != 8 || saved.Locale != models.LocaleZhCN {
+		t.Fatalf("校验失败时不应修改设置: %+v", saved)
+	}
+}
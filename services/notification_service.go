@@ -0,0 +1,124 @@
+package services
+
+import (
+	"volunteer-system-backend/models"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Event 业务中发生的领域事件，由通知服务按事件类型渲染消息模板后投递给接收者
+type Event struct {
+	Type           string      // 事件类型，对应消息模板的事件类型
+	OrganizationID uint        // 事件所属组织
+	Recipients     []uint      // 接收者的用户ID
+	Data           MessageData // 渲染消息模板使用的变量
+	ExpiresAt      *time.Time  // 消息过期时间，为空表示不过期
+}
+
+// NotificationService 通知服务，所有站内消息都通过它写入，并按用户的订阅设置交给其他通知渠道
+// 以 Tx 结尾的方法在调用方的事务中写入，返回的 push 函数需要在事务提交后调用，将消息推送给在线的客户端
+type NotificationService interface {
+	Emit(event Event) ([]models.Message, error)
+	EmitTx(tx *gorm.DB, event Event) ([]models.Message, func(), error)
+	Send(messages []models.Message) ([]models.Message, error)
+	SendTx(tx *gorm.DB, messages []models.Message) ([]models.Message, func(), error)
+}
+
+// notificationServiceImpl 通知服务实现
+type notificationServiceImpl struct {
+	db  *gorm.DB
+	hub *MessageHub
+}
+
+// NewNotificationService 创建新的通知服务实例
+func NewNotificationService(db *gorm.DB, hub *MessageHub) NotificationService {
+	return &notificationServiceImpl{db: db, hub: hub}
+}
+
+// Emit 将领域事件渲染为每个接收者的消息并投递
+func (s *notificationServiceImpl) Emit(event Event) ([]models.Message, error) {
+	var messages []models.Message
+	var push func()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		messages, push, err = s.EmitTx(tx, event)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	push()
+	return messages, nil
+}
+
+// EmitTx 在调用方的事务中将领域事件渲染为每个接收者的消息并写入，每种语言只渲染一次模板
+func (s *notificationServiceImpl) EmitTx(tx *gorm.DB, event Event) ([]models.Message, func(), error) {
+	if len(event.Recipients) == 0 {
+		return nil, func() {}, nil
+	}
+	var recipients []models.User
+	if err := tx.Select("id", "locale").Where("id IN ?", event.Recipients).Find(&recipients).Error; err != nil {
+		return nil, nil, err
+	}
+	type rendered struct{ category, title, content string }
+	renderedByLocale := make(map[string]rendered)
+	now := time.Now()
+	messages := make([]models.Message, 0, len(recipients))
+	for _, recipient := range recipients {
+		r, ok := renderedByLocale[recipient.Locale]
+		if !ok {
+			category, title, content, err := renderMessage(tx, event.Type, recipient.Locale, event.Data)
+			if err != nil {
+				return nil, nil, err
+			}
+			r = rendered{category, title, content}
+			renderedByLocale[recipient.Locale] = r
+		}
+		messages = append(messages, models.Message{
+			UserID:         recipient.ID,
+			OrganizationID: event.OrganizationID,
+			Category:       r.category,
+			Title:          r.title,
+			Content:        r.content,
+			Time:           now,
+			Status:         "unread",
+			ExpiresAt:      event.ExpiresAt,
+		})
+	}
+	return s.SendTx(tx, messages)
+}
+
+// Send 投递已经确定内容的消息，例如管理员手动发送的消息
+func (s *notificationServiceImpl) Send(messages []models.Message) ([]models.Message, error) {
+	var push func()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		messages, push, err = s.SendTx(tx, messages)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	push()
+	return messages, nil
+}
+
+// SendTx 在调用方的事务中写入消息并交给各通知渠道
+func (s *notificationServiceImpl) SendTx(tx *gorm.DB, messages []models.Message) ([]models.Message, func(), error) {
+	if len(messages) == 0 {
+		return messages, func() {}, nil
+	}
+	if err := tx.CreateInBatches(&messages, announcementBatchSize).Error; err != nil {
+		return nil, nil, errors.New("创建消息失败")
+	}
+	if err := notifyChannels(tx, messages); err != nil {
+		return nil, nil, err
+	}
+	push := func() {
+		for _, message := range messages {
+			s.hub.Publish(message)
+		}
+	}
+	return messages, push, nil
+}
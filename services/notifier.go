@@ -0,0 +1,58 @@
+package services
+
+import (
+	"volunteer-system-backend/models"
+	"gorm.io/gorm"
+)
+
+// Notifier 站内消息之外的通知渠道
+// Notify 在写入消息的同一事务中调用，渠道只应在事务内记录待发送的内容，实际发送由各渠道的后台任务完成
+// 用户没有设置某类消息的订阅时，使用 DefaultEnabled 决定是否通过该渠道通知
+type Notifier interface {
+	Channel() string
+	DefaultEnabled(category string) bool
+	Notify(tx *gorm.DB, user models.User, message models.Message) error
+}
+
+// notifiers 已注册的通知渠道
+var notifiers = []Notifier{
+	&EmailNotifier{},
+}
+
+// notifyChannels 按用户的订阅设置将消息交给各通知渠道
+func notifyChannels(tx *gorm.DB, messages []models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	userIds := make([]uint, len(messages))
+	for i, message := range messages {
+		userIds[i] = message.UserID
+	}
+	var users []models.User
+	if err := tx.Where("id IN ?", userIds).Find(&users).Error; err != nil {
+		return err
+	}
+	preferences, err := loadChannelPreferences(tx, userIds)
+	if err != nil {
+		return err
+	}
+	userMap := make(map[uint]models.User, len(users))
+	for _, user := range users {
+		userMap[user.ID] = user
+	}
+	for _, message := range messages {
+		user, ok := userMap[message.UserID]
+		if !ok {
+			continue
+		}
+		for _, notifier := range notifiers {
+			if !channelEnabled(preferences, user.ID, notifier, message.Category) {
+				continue
+			}
+			if err := notifier.Notify(tx, user, message); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
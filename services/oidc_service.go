@@ -0,0 +1,261 @@
+package services
+
+import (
+	"volunteer-system-backend/config"
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/utils"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrOIDCDisabled 未启用统一身份认证登录
+	ErrOIDCDisabled = errors.New("未启用统一身份认证登录")
+	// ErrInvalidOIDCState 登录状态无效，可能已过期或已被使用
+	ErrInvalidOIDCState = errors.New("登录已过期，请重新发起统一身份认证登录")
+	// ErrOIDCProvider 与身份提供方交互失败或其返回的令牌无效
+	ErrOIDCProvider = errors.New("统一身份认证失败")
+	// ErrOIDCEmailNotVerified 身份提供方没有返回已验证的邮箱
+	ErrOIDCEmailNotVerified = errors.New("身份提供方未提供已验证的邮箱，无法关联账户")
+	// ErrOIDCSignupDisabled 邮箱未注册且未开启自动注册
+	ErrOIDCSignupDisabled = errors.New("该邮箱尚未注册，请先注册账户")
+)
+
+// OIDCStateExpiry 发起登录到回调之间允许的最长时间
+const OIDCStateExpiry = 10 * time.Minute
+
+// oidcScopes 申请的权限范围，必须包含 openid
+func oidcScopes() []string {
+	scopes := config.ProjectConfig.OIDC.Scopes
+	if len(scopes) == 0 {
+		return []string{"openid", "email", "profile"}
+	}
+	for _, scope := range scopes {
+		if scope == "openid" {
+			return scopes
+		}
+	}
+	return append([]string{"openid"}, scopes...)
+}
+
+// oidcDiscovery 获取已启用的身份提供方的发现文档
+func oidcDiscovery() (*utils.OIDCDiscovery, error) {
+	if !config.ProjectConfig.OIDC.Enabled {
+		return nil, ErrOIDCDisabled
+	}
+	doc, err := utils.DiscoverOIDC(config.ProjectConfig.OIDC.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("%w：%v", ErrOIDCProvider, err)
+	}
+	return doc, nil
+}
+
+// OIDCAuthorize 生成 state、nonce 和 PKCE 校验码并返回身份提供方的授权地址
+// 前端需要保存 state，回调时核对地址中的 state 与保存的一致后再调用回调接口
+// 返回的 binding 由控制器写入 HttpOnly Cookie，回调时必须由同一个浏览器提交，避免攻击者诱导用户登录攻击者的账户
+func OIDCAuthorize() (dto.OIDCAuthorizeInfo, string, error) {
+	doc, err := oidcDiscovery()
+	if err != nil {
+		return dto.OIDCAuthorizeInfo{}, "", err
+	}
+	var values [4]string
+	for i := range values {
+		if values[i], err = newRandomToken(); err != nil {
+			return dto.OIDCAuthorizeInfo{}, "", errors.New("无法生成登录状态")
+		}
+	}
+	state, nonce, verifier, binding := values[0], values[1], values[2], values[3]
+	now := time.Now().Local()
+	if err := models.DB.Create(&models.OIDCLoginState{
+		StateHash:    hashToken(state),
+		BindingHash:  hashToken(binding),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(OIDCStateExpiry),
+		CreatedAt:    now,
+	}).Error; err != nil {
+		return dto.OIDCAuthorizeInfo{}, "", errors.New("无法保存登录状态")
+	}
+	cfg := config.ProjectConfig.OIDC
+	return dto.OIDCAuthorizeInfo{
+		Name:  cfg.Name,
+		URL:   utils.OIDCAuthURL(doc, cfg.ClientID, cfg.RedirectURL, oidcScopes(), state, nonce, verifier),
+		State: state,
+	}, binding, nil
+}
+
+// claimOIDCState 取出并删除登录状态，state 必须由发起登录的浏览器提交，同一个 state 并发使用时只有一次成功
+func claimOIDCState(state, binding string) (*models.OIDCLoginState, error) {
+	if binding == "" {
+		return nil, ErrInvalidOIDCState
+	}
+	var record models.OIDCLoginState
+	if err := models.DB.Where("state_hash = ? AND binding_hash = ?", hashToken(state), hashToken(binding)).First(&record).Error; err != nil {
+		return nil, ErrInvalidOIDCState
+	}
+	result := models.DB.Where("id = ? AND expires_at > ?", record.ID, time.Now().Local()).Delete(&models.OIDCLoginState{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidOIDCState
+	}
+	return &record, nil
+}
+
+// oidcUser 找到 ID Token 对应的用户，首次登录时按已验证的邮箱关联已有账户，邮箱未注册时按配置自动创建账户
+func oidcUser(issuer string, claims *utils.OIDCClaims) (*models.User, error) {
+	now := time.Now().Local()
+	var identity models.ExternalIdentity
+	err := models.DB.Where("provider = ? AND subject = ?", issuer, claims.Subject).First(&identity).Error
+	if err == nil {
+		var user models.User
+		if err := models.DB.First(&user, identity.UserID).Error; err != nil {
+			return nil, errors.New("关联的用户不存在")
+		}
+		if err := models.DB.Model(&identity).Updates(map[string]any{"email": claims.Email, "last_login_at": now}).Error; err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// 首次登录，只有身份提供方确认过的邮箱才能用于关联账户
+	cfg := config.ProjectConfig.OIDC
+	email := strings.TrimSpace(claims.Email)
+	verified := claims.IsEmailVerified() || (cfg.TrustEmail && !claims.HasEmailVerified())
+	if email == "" || !verified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+	var user models.User
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("email = ?", email).First(&user).Error
+		switch {
+		case err == nil:
+			// 身份提供方确认了邮箱的所有权，未验证的邮箱视为已验证
+			// 未验证邮箱的账户可能是他人抢先用该邮箱注册的，注册时设置的密码和登录需要全部作废
+			if user.EmailVerifiedAt == nil {
+				if err := claimUnverifiedAccount(tx, &user, now); err != nil {
+					return err
+				}
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if !cfg.AutoRegister {
+				return ErrOIDCSignupDisabled
+			}
+			if err := createOIDCUser(tx, &user, email, claims, now); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+		return tx.Create(&models.ExternalIdentity{
+			UserID:      user.ID,
+			Provider:    issuer,
+			Subject:     claims.Subject,
+			Email:       email,
+			CreatedAt:   now,
+			LastLoginAt: now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// claimUnverifiedAccount 邮箱所有者通过统一身份认证认领未验证邮箱的账户，将密码替换为随机值并使之前的登录失效
+func claimUnverifiedAccount(tx *gorm.DB, user *models.User, now time.Time) error {
+	password, err := newRandomToken()
+	if err != nil {
+		return errors.New("无法生成随机密码")
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.New("无法对密码进行哈希处理")
+	}
+	if err := tx.Model(user).Updates(map[string]any{"email_verified_at": now, "password": string(hashedPassword)}).Error; err != nil {
+		return err
+	}
+	if err := InvalidateUserSessions(tx, user.ID); err != nil {
+		return err
+	}
+	user.EmailVerifiedAt = &now
+	user.TokenVersion++
+	return nil
+}
+
+// createOIDCUser 为首次通过统一身份认证登录的用户创建志愿者账户
+// 账户使用随机密码，需要密码登录时可以通过找回密码设置
+func createOIDCUser(tx *gorm.DB, user *models.User, email string, claims *utils.OIDCClaims, now time.Time) error {
+	password, err := newRandomToken()
+	if err != nil {
+		return errors.New("无法生成随机密码")
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.New("无法对密码进行哈希处理")
+	}
+	role, err := models.GetRoleByName(models.RoleVolunteer)
+	if err != nil {
+		return err
+	}
+	nickname := claims.Name
+	if nickname == "" {
+		nickname = claims.PreferredUsername
+	}
+	if nickname == "" {
+		nickname, _, _ = strings.Cut(email, "@")
+	}
+	*user = models.User{
+		Email:           email,
+		Nickname:        nickname,
+		Gender:          "保密",
+		Password:        string(hashedPassword),
+		CreatedAt:       now,
+		RoleID:          role.ID,
+		LastLoginTime:   now,
+		EmailVerifiedAt: &now,
+	}
+	if err := tx.Create(user).Error; err != nil {
+		return errors.New("无法创建用户")
+	}
+	return nil
+}
+
+// OIDCLogin 使用身份提供方回调的授权码完成登录，binding 为发起登录时写入浏览器 Cookie 的随机值
+// 开启两步验证的用户与密码登录一样返回预认证令牌
+func OIDCLogin(code, state, binding, ip string) (dto.TokenPair, error) {
+	doc, err := oidcDiscovery()
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+	record, err := claimOIDCState(state, binding)
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+	cfg := config.ProjectConfig.OIDC
+	idToken, err := utils.ExchangeOIDCCode(doc, cfg.ClientID, cfg.ClientSecret, cfg.RedirectURL, code, record.CodeVerifier)
+	if err != nil {
+		return dto.TokenPair{}, fmt.Errorf("%w：%v", ErrOIDCProvider, err)
+	}
+	claims, err := utils.VerifyIDToken(doc, cfg.ClientID, idToken, record.Nonce)
+	if err != nil {
+		return dto.TokenPair{}, fmt.Errorf("%w：%v", ErrOIDCProvider, err)
+	}
+	user, err := oidcUser(doc.Issuer, claims)
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+	if user.TOTPEnabled {
+		return dto.TokenPair{TwoFactorRequired: true, PreAuthToken: signPreAuthToken(user)}, nil
+	}
+	return completeLogin(user, ip)
+}
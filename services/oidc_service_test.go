@@ -0,0 +1,287 @@
+package services
+
+import (
+	"volunteer-system-backend/config"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/utils"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testOIDCClientID = "volunteer-system"
+
+// fakeOIDCProvider 测试使用的身份提供方，授权码对应的 ID Token 声明由测试注册
+type fakeOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	issuer string // 发现文档中返回的 issuer，默认为服务地址
+
+	mu    sync.Mutex
+	codes map[string]fakeOIDCCode
+}
+
+type fakeOIDCCode struct {
+	challenge string
+	claims    jwt.MapClaims
+	signer    *rsa.PrivateKey
+}
+
+// newFakeOIDCProvider 启动身份提供方并将其配置为当前启用的统一身份认证
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeOIDCProvider{key: key, codes: map[string]fakeOIDCCode{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.issuer,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		encode := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "test-key", "use": "sig",
+			"n": encode(key.N.Bytes()), "e": encode(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		p.mu.Lock()
+		code, ok := p.codes[r.PostForm.Get("code")]
+		delete(p.codes, r.PostForm.Get("code"))
+		p.mu.Unlock()
+		// 授权码只能使用一次，且必须提交与授权请求对应的 PKCE 校验码
+		if !ok || r.PostForm.Get("client_id") != testOIDCClientID ||
+			utils.PKCEChallenge(r.PostForm.Get("code_verifier")) != code.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, code.claims)
+		token.Header["kid"] = "test-key"
+		signed, err := token.SignedString(code.signer)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "token_type": "Bearer"})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	p.issuer = p.server.URL
+
+	config.ProjectConfig.OIDC.Enabled = true
+	config.ProjectConfig.OIDC.Issuer = p.server.URL
+	config.ProjectConfig.OIDC.ClientID = testOIDCClientID
+	config.ProjectConfig.OIDC.RedirectURL = "http://localhost:5173/oidc/callback"
+	config.ProjectConfig.OIDC.AutoRegister = true
+	return p
+}
+
+// authorize 发起登录并为身份提供方登记授权码，modify 可以修改默认的 ID Token 声明
+func (p *fakeOIDCProvider) authorize(t *testing.T, code, subject, email string, modify func(jwt.MapClaims)) (state, binding string) {
+	t.Helper()
+	info, binding, err := OIDCAuthorize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := url.Parse(info.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := authURL.Query()
+	if query.Get("state") != info.State || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("授权地址不正确: %s", info.URL)
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            p.server.URL,
+		"sub":            subject,
+		"aud":            testOIDCClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          query.Get("nonce"),
+		"email":          email,
+		"email_verified": true,
+		"name":           "统一认证用户",
+	}
+	if modify != nil {
+		modify(claims)
+	}
+	p.mu.Lock()
+	p.codes[code] = fakeOIDCCode{challenge: query.Get("code_challenge"), claims: claims, signer: p.key}
+	p.mu.Unlock()
+	return info.State, binding
+}
+
+func TestOIDCDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	setupTestDB(t)
+	p := newFakeOIDCProvider(t)
+	p.issuer = "https://attacker.example.com"
+	if _, _, err := OIDCAuthorize(); !errors.Is(err, ErrOIDCProvider) {
+		t.Fatalf("发现文档的 issuer 与配置不一致时应返回 ErrOIDCProvider，实际为 %v", err)
+	}
+}
+
+func TestOIDCLoginRegistersAndRejectsStateReuse(t *testing.T) {
+	setupTestDB(t)
+	p := newFakeOIDCProvider(t)
+
+	state, binding := p.authorize(t, "code-1", "subject-1", "new@example.com", nil)
+	pair, err := OIDCLogin("code-1", state, binding, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair.Token == "" {
+		t.Fatal("登录成功后应签发访问令牌")
+	}
+	var user models.User
+	if err := models.DB.Where("email = ?", "new@example.com").First(&user).Error; err != nil {
+		t.Fatalf("邮箱未注册时应自动创建账户: %v", err)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Fatal("自动创建的账户邮箱应为已验证")
+	}
+
+	if _, err := OIDCLogin("code-1", state, binding, "192.0.2.1"); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("state 只能使用一次，实际为 %v", err)
+	}
+}
+
+func TestOIDCLoginRequiresBrowserBinding(t *testing.T) {
+	setupTestDB(t)
+	p := newFakeOIDCProvider(t)
+
+	state, binding := p.authorize(t, "code-1", "subject-1", "new@example.com", nil)
+	for _, other := range []string{"", "attacker-cookie"} {
+		if _, err := OIDCLogin("code-1", state, other, "192.0.2.1"); !errors.Is(err, ErrInvalidOIDCState) {
+			t.Fatalf("Cookie 为 %q 时应返回 ErrInvalidOIDCState，实际为 %v", other, err)
+		}
+	}
+	if _, err := OIDCLogin("code-1", state, binding, "192.0.2.1"); err != nil {
+		t.Fatalf("发起登录的浏览器应能完成登录: %v", err)
+	}
+}
+
+func TestOIDCLoginVerifiesIDToken(t *testing.T) {
+	setupTestDB(t)
+	p := newFakeOIDCProvider(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]func(jwt.MapClaims){
+		"nonce 不匹配": func(c jwt.MapClaims) { c["nonce"] = "other-nonce" },
+		"azp 不是本客户端": func(c jwt.MapClaims) {
+			c["aud"] = []string{testOIDCClientID, "other-client"}
+			c["azp"] = "other-client"
+		},
+		"audience 不包含本客户端": func(c jwt.MapClaims) { c["aud"] = "other-client" },
+		"issuer 不一致":       func(c jwt.MapClaims) { c["iss"] = "https://attacker.example.com" },
+		"已过期":              func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+	}
+	for name, modify := range cases {
+		state, binding := p.authorize(t, name, "subject-1", "new@example.com", modify)
+		if _, err := OIDCLogin(name, state, binding, "192.0.2.1"); !errors.Is(err, ErrOIDCProvider) {
+			t.Errorf("%s 时应返回 ErrOIDCProvider，实际为 %v", name, err)
+		}
+	}
+
+	// 使用身份提供方公钥之外的密钥签名
+	state, binding := p.authorize(t, "forged", "subject-1", "new@example.com", nil)
+	p.mu.Lock()
+	forged := p.codes["forged"]
+	forged.signer = otherKey
+	p.codes["forged"] = forged
+	p.mu.Unlock()
+	if _, err := OIDCLogin("forged", state, binding, "192.0.2.1"); !errors.Is(err, ErrOIDCProvider) {
+		t.Errorf("签名无效时应返回 ErrOIDCProvider，实际为 %v", err)
+	}
+
+	// 授权码无效时令牌端点返回错误
+	state, binding = p.authorize(t, "code-1", "subject-1", "new@example.com", nil)
+	if _, err := OIDCLogin("unknown-code", state, binding, "192.0.2.1"); !errors.Is(err, ErrOIDCProvider) {
+		t.Errorf("授权码无效时应返回 ErrOIDCProvider，实际为 %v", err)
+	}
+
+	var count int64
+	models.DB.Model(&models.User{}).Where("email = ?", "new@example.com").Count(&count)
+	if count != 0 {
+		t.Fatal("ID Token 校验失败时不应创建账户")
+	}
+}
+
+func TestOIDCLoginLinksAccountsByVerifiedEmail(t *testing.T) {
+	setupTestDB(t)
+	p := newFakeOIDCProvider(t)
+
+	// 身份提供方未确认邮箱时不能关联账户
+	createTestUser(t, "verified@example.com")
+	state, binding := p.authorize(t, "unverified-claim", "subject-1", "verified@example.com", func(c jwt.MapClaims) { c["email_verified"] = false })
+	if _, err := OIDCLogin("unverified-claim", state, binding, "192.0.2.1"); !errors.Is(err, ErrOIDCEmailNotVerified) {
+		t.Fatalf("邮箱未验证时应返回 ErrOIDCEmailNotVerified，实际为 %v", err)
+	}
+
+	// 已验证邮箱的账户关联后保留原来的密码
+	state, binding = p.authorize(t, "verified", "subject-1", "verified@example.com", nil)
+	if _, err := OIDCLogin("verified", state, binding, "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoginUser("verified@example.com", "Passw0rd!test", "192.0.2.1"); err != nil {
+		t.Fatalf("已验证邮箱的账户关联后应仍能使用密码登录: %v", err)
+	}
+}
+
+func TestOIDCLoginClaimsUnverifiedAccount(t *testing.T) {
+	setupTestDB(t)
+	p := newFakeOIDCProvider(t)
+
+	// 他人抢先使用该邮箱注册了账户，但没有验证邮箱
+	squatter := createTestUser(t, "victim@example.com")
+	if err := models.DB.Model(squatter).Update("email_verified_at", nil).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := models.DB.Create(&models.RefreshToken{UserID: squatter.ID, TokenHash: hashToken("squatter"), FamilyID: "squatter", ExpiresAt: time.Now().Add(time.Hour)}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	state, binding := p.authorize(t, "code-1", "victim-subject", "victim@example.com", nil)
+	if _, err := OIDCLogin("code-1", state, binding, "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var saved models.User
+	if err := models.DB.First(&saved, squatter.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.EmailVerifiedAt == nil || saved.TokenVersion != squatter.TokenVersion+1 {
+		t.Fatalf("认领后邮箱应为已验证且之前的令牌失效，实际令牌版本 %d", saved.TokenVersion)
+	}
+	if _, err := LoginUser("victim@example.com", "Passw0rd!test", "192.0.2.1"); err == nil || !strings.Contains(err.Error(), "邮箱或密码无效") {
+		t.Fatalf("注册时设置的密码应失效，实际为 %v", err)
+	}
+	var active int64
+	models.DB.Model(&models.RefreshToken{}).Where("token_hash = ? AND revoked_at IS NULL", hashToken("squatter")).Count(&active)
+	if active != 0 {
+		t.Fatal("认领前签发的刷新令牌应被撤销")
+	}
+}
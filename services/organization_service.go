@@ -0,0 +1,331 @@
+package services
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/utils"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+)
+
+// GetDefaultOrganizationID 获取用户登录后默认进入的组织（最早加入的组织），没有加入任何组织时返回 0
+func GetDefaultOrganizationID(userID uint) uint {
+	var member models.OrganizationMember
+	if err := models.DB.Where("user_id = ?", userID).Order("id").First(&member).Error; err != nil {
+		return 0
+	}
+	return member.OrganizationID
+}
+
+// IsOrganizationMember 判断用户是否为组织成员
+func IsOrganizationMember(userID, orgID uint) bool {
+	var count int64
+	models.DB.Model(&models.OrganizationMember{}).Where("organization_id = ? AND user_id = ?", orgID, userID).Count(&count)
+	return count > 0
+}
+
+// CreateOrganization 创建组织
+func CreateOrganization(name string) (dto.OrganizationInfo, error) {
+	name = strings.TrimSpace(name)
+	var existing models.Organization
+	if err := models.DB.Where("name = ?", name).First(&existing).Error; err == nil {
+		return dto.OrganizationInfo{}, errors.New("该组织名称已经存在")
+	}
+	org := models.Organization{
+		Name:      name,
+		Code:      strings.ReplaceAll(uuid.New().String(), "-", "")[:12],
+		CreatedAt: time.Now().Local(),
+	}
+	if err := models.DB.Create(&org).Error; err != nil {
+		return dto.OrganizationInfo{}, errors.New("无法创建组织")
+	}
+	return dto.OrganizationInfo{ID: org.ID, Name: org.Name, Code: org.Code}, nil
+}
+
+// GetUserOrganizations 获取用户加入的组织列表
+func GetUserOrganizations(email string) ([]dto.OrganizationInfo, error) {
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	var orgs []dto.OrganizationInfo
+	if err := models.DB.Table("organization_members").
+		Select("organizations.id, organizations.name, roles.name AS role").
+		Joins("JOIN organizations ON organizations.id = organization_members.organization_id").
+		Joins("JOIN roles ON roles.id = organization_members.role_id").
+		Where("organization_members.user_id = ?", userId).
+		Order("organization_members.id").
+		Scan(&orgs).Error; err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// SwitchOrganization 切换当前所在组织，返回新的 token，jti 和 expiresAt 为当前访问令牌的标识和过期时间
+func SwitchOrganization(email string, orgID uint, jti string, expiresAt time.Time) (string, error) {
+	user, err := GetUserProfile(email)
+	if err != nil {
+		return "", err
+	}
+	if !IsOrganizationMember(user.ID, orgID) {
+		// 超级管理员可以进入任意组织
+		ok, err := HasPermission(email, 0, models.PermOrgCreate)
+		if err != nil {
+			return "", err
+		}
+		var org models.Organization
+		if !ok || models.DB.Where("id = ?", orgID).First(&org).Error != nil {
+			return "", errors.New("不是该组织的成员")
+		}
+	}
+	token, claims, err := utils.GenerateJWT(user.Email, user.Nickname, orgID, user.TokenVersion, user.LastLoginTime)
+	if err != nil {
+		return "", errors.New("生成 token 失败")
+	}
+	// 旧的访问令牌作废，刷新令牌改为关联新的访问令牌，之后刷新时进入新的组织
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := revokeAccessToken(tx, jti, expiresAt); err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("access_jti = ? AND revoked_at IS NULL", jti).
+			Updates(map[string]any{"access_jti": claims.ID, "organization_id": orgID}).Error
+	})
+	if err != nil {
+		return "", errors.New("切换组织失败")
+	}
+	return token, nil
+}
+
+// JoinOrganization 凭邀请码以志愿者身份加入组织，并通知加入的用户
+func (s *OrganizationService) JoinOrganization(email, code string) (dto.OrganizationInfo, error) {
+	var org models.Organization
+	if err := models.DB.Where("code = ?", strings.TrimSpace(code)).First(&org).Error; err != nil {
+		return dto.OrganizationInfo{}, errors.New("邀请码无效")
+	}
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return dto.OrganizationInfo{}, err
+	}
+	if IsOrganizationMember(userId, org.ID) {
+		return dto.OrganizationInfo{}, errors.New("已经是该组织的成员")
+	}
+	role, err := models.GetRoleByName(models.RoleVolunteer)
+	if err != nil {
+		return dto.OrganizationInfo{}, err
+	}
+	push := func() {}
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := addOrganizationMember(tx, org.ID, userId, role.ID); err != nil {
+			return err
+		}
+		var err error
+		if _, push, err = s.notifications.EmitTx(tx, orgJoinedEvent(org, userId, role)); err != nil {
+			return errors.New("创建消息失败")
+		}
+		return nil
+	})
+	if err != nil {
+		return dto.OrganizationInfo{}, err
+	}
+	push()
+	return dto.OrganizationInfo{ID: org.ID, Name: org.Name, Role: models.RoleVolunteer}, nil
+}
+
+// orgJoinedEvent 用户加入组织的事件，消息属于加入的组织
+func orgJoinedEvent(org models.Organization, userId uint, role models.Role) Event {
+	return Event{
+		Type:           models.EventOrgJoined,
+		OrganizationID: org.ID,
+		Recipients:     []uint{userId},
+		Data:           MessageData{"OrgName": org.Name, "RoleName": role.DisplayName},
+	}
+}
+
+// addOrganizationMember 在事务中创建组织成员身份，用户已经是成员时不做修改
+func addOrganizationMember(tx *gorm.DB, orgID, userId, roleId uint) error {
+	member := models.OrganizationMember{
+		OrganizationID: orgID,
+		UserID:         userId,
+		RoleID:         roleId,
+		CreatedAt:      time.Now().Local(),
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&member).Error; err != nil {
+		return errors.New("无法加入组织")
+	}
+	return nil
+}
+
+// OrganizationService 邀请用户加入组织
+type OrganizationService struct {
+	notifications NotificationService
+}
+
+// NewOrganizationService 创建组织服务
+func NewOrganizationService(notifications NotificationService) *OrganizationService {
+	return &OrganizationService{notifications: notifications}
+}
+
+// InviteOrganizationMember 邀请用户以指定角色加入组织，用户接受邀请后才成为成员；已经是成员时直接更新其角色
+func (s *OrganizationService) InviteOrganizationMember(orgID uint, inviterEmail, email, roleName string) error {
+	if roleName == models.RoleSuperAdmin {
+		return errors.New("超级管理员不能作为组织角色")
+	}
+	role, err := models.GetRoleByName(roleName)
+	if err != nil {
+		return err
+	}
+	inviterId, err := GetUserIDByEmail(inviterEmail)
+	if err != nil {
+		return err
+	}
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return err
+	}
+	var org models.Organization
+	if err := models.DB.First(&org, orgID).Error; err != nil {
+		return errors.New("组织不存在")
+	}
+	var member models.OrganizationMember
+	if err := models.DB.Where("organization_id = ? AND user_id = ?", orgID, userId).First(&member).Error; err == nil {
+		if member.RoleID == role.ID {
+			return nil
+		}
+		// 组织角色变化后该用户之前的登录全部失效
+		return models.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&member).Update("role_id", role.ID).Error; err != nil {
+				return errors.New("无法更新成员角色")
+			}
+			return InvalidateUserSessions(tx, userId)
+		})
+	}
+	// 重复邀请时更新邀请的角色
+	invitation := models.OrganizationInvitation{
+		OrganizationID: orgID,
+		UserID:         userId,
+		RoleID:         role.ID,
+		InvitedBy:      inviterId,
+		CreatedAt:      time.Now().Local(),
+	}
+	if err := models.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "organization_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role_id", "invited_by", "created_at"}),
+	}).Create(&invitation).Error; err != nil {
+		return errors.New("无法邀请组织成员")
+	}
+	// 被邀请的用户还不是组织成员，邀请消息不属于任何组织，在所有组织中都可以看到
+	if _, err := s.notifications.Emit(Event{
+		Type:       models.EventOrgInvited,
+		Recipients: []uint{userId},
+		Data:       MessageData{"OrgName": org.Name, "RoleName": role.DisplayName},
+	}); err != nil {
+		return errors.New("创建消息失败")
+	}
+	return nil
+}
+
+// GetOrganizationInvitations 获取用户待处理的组织邀请
+func GetOrganizationInvitations(email string) ([]dto.OrganizationInvitationInfo, error) {
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		ID               uint
+		OrganizationID   uint
+		OrganizationName string
+		Role             string
+		InvitedBy        string
+		CreatedAt        time.Time
+	}
+	if err := models.DB.Table("organization_invitations").
+		Select("organization_invitations.id, organization_invitations.organization_id, organizations.name AS organization_name, "+
+			"roles.name AS role, users.nickname AS invited_by, organization_invitations.created_at").
+		Joins("JOIN organizations ON organizations.id = organization_invitations.organization_id").
+		Joins("JOIN roles ON roles.id = organization_invitations.role_id").
+		Joins("LEFT JOIN users ON users.id = organization_invitations.invited_by").
+		Where("organization_invitations.user_id = ?", userId).
+		Order("organization_invitations.id DESC").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	invitations := make([]dto.OrganizationInvitationInfo, len(rows))
+	for i, row := range rows {
+		invitations[i] = dto.OrganizationInvitationInfo{
+			ID:               row.ID,
+			OrganizationID:   row.OrganizationID,
+			OrganizationName: row.OrganizationName,
+			Role:             row.Role,
+			InvitedBy:        row.InvitedBy,
+			CreatedAt:        utils.FormatTime2Str(row.CreatedAt),
+		}
+	}
+	return invitations, nil
+}
+
+// RespondOrganizationInvitation 接受或拒绝组织邀请，接受后以邀请中的角色成为组织成员
+func RespondOrganizationInvitation(email string, invitationId uint, accept bool) (dto.OrganizationInfo, error) {
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return dto.OrganizationInfo{}, err
+	}
+	var invitation models.OrganizationInvitation
+	if err := models.DB.Where("id = ? AND user_id = ?", invitationId, userId).First(&invitation).Error; err != nil {
+		return dto.OrganizationInfo{}, errors.New("没有待处理的组织邀请")
+	}
+	var org models.Organization
+	if err := models.DB.First(&org, invitation.OrganizationID).Error; err != nil {
+		return dto.OrganizationInfo{}, errors.New("组织不存在")
+	}
+	role, err := models.GetRoleWithPermissions(invitation.RoleID)
+	if err != nil {
+		return dto.OrganizationInfo{}, err
+	}
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		// 通过删除认领邀请，同一个邀请并发答复时只有一次生效
+		result := tx.Delete(&models.OrganizationInvitation{}, invitation.ID)
+		if result.Error != nil {
+			return errors.New("无法答复组织邀请")
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("没有待处理的组织邀请")
+		}
+		if !accept {
+			return nil
+		}
+		return addOrganizationMember(tx, invitation.OrganizationID, userId, invitation.RoleID)
+	})
+	if err != nil {
+		return dto.OrganizationInfo{}, err
+	}
+	return dto.OrganizationInfo{ID: org.ID, Name: org.Name, Role: role.Name}, nil
+}
+
+// RemoveOrganizationMember 将用户移出组织，同时移除其在该组织活动中的协调员身份并使其之前的登录失效
+func RemoveOrganizationMember(orgID uint, email string) error {
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return err
+	}
+	result := models.DB.Where("organization_id = ? AND user_id = ?", orgID, userId).Delete(&models.OrganizationMember{})
+	if result.Error != nil {
+		return errors.New("无法移除组织成员")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("该用户不是组织成员")
+	}
+	if err := models.DB.Where("user_id = ? AND task_id IN (?)", userId,
+		models.DB.Model(&models.Task{}).Select("id").Where("organization_id = ?", orgID)).
+		Delete(&models.TaskCoordinator{}).Error; err != nil {
+		return errors.New("无法移除协调员身份")
+	}
+	if err := InvalidateUserSessions(models.DB, userId); err != nil {
+		return errors.New("无法使该用户的登录失效")
+	}
+	return nil
+}
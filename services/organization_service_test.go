@@ -0,0 +1,275 @@
+package services
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestTasksAreScopedToOrganization(t *testing.T) {
+	notifications := setupTestDB(t)
+	taskService := NewTaskService(notifications)
+	orgA := createTestOrg(t, "组织A")
+	orgB := createTestOrg(t, "组织B")
+	user := createTestUser(t, "a@example.com")
+	addTestMember(t, orgA, user, models.RoleVolunteer)
+	taskB := createTestTask(t, orgB, "组织B的活动", 10)
+
+	tasks, err := GetTasks(orgA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range tasks {
+		if task.ID == taskB.ID {
+			t.Fatal("组织A的活动列表中出现了组织B的活动")
+		}
+	}
+	details, err := GetTaskDetails(orgA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range details {
+		if task.ID == taskB.ID {
+			t.Fatal("组织A的活动详情中出现了组织B的活动")
+		}
+	}
+
+	err = taskService.JoinTask(orgA, dto.TaskRegistrationRequest{ID: taskB.ID, Name: taskB.Name}, user.Nickname, user.Email)
+	if err == nil {
+		t.Fatal("不应能在组织A中报名组织B的活动")
+	}
+	if err := taskService.DeleteTask(orgA, strconv.Itoa(int(taskB.ID))); err == nil {
+		t.Fatal("不应能在组织A中删除组织B的活动")
+	}
+	if err := models.DB.First(&models.Task{}, taskB.ID).Error; err != nil {
+		t.Fatalf("组织B的活动被删除: %v", err)
+	}
+}
+
+func TestGetVolunteerCountIsScopedToOrganization(t *testing.T) {
+	setupTestDB(t)
+	orgA := createTestOrg(t, "组织A")
+	orgB := createTestOrg(t, "组织B")
+	both := createTestUser(t, "both@example.com")
+	onlyB := createTestUser(t, "b@example.com")
+	addTestMember(t, orgA, both, models.RoleVolunteer)
+	addTestMember(t, orgB, both, models.RoleVolunteer)
+	addTestMember(t, orgB, onlyB, models.RoleVolunteer)
+
+	// 在两个组织的活动中各确认一段时长，并记入全局的 users.duration
+	for orgID, minutes := range map[uint]uint{orgA: 30, orgB: 90} {
+		task := createTestTask(t, orgID, "活动", 0)
+		if err := models.DB.Create(&models.TaskParticipant{
+			TaskID:           task.ID,
+			Nickname:         both.Nickname,
+			Email:            both.Email,
+			Status:           1,
+			ConfirmedMinutes: minutes,
+			HoursConfirmed:   true,
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := models.DB.Model(both).Update("duration", 120).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	volunteers, err := GetVolunteerCount(orgA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volunteers) != 1 {
+		t.Fatalf("组织A应只有 1 名志愿者，实际为 %d", len(volunteers))
+	}
+	if volunteers[0]["email"] != both.Email {
+		t.Fatalf("组织A的志愿者应为 %s，实际为 %v", both.Email, volunteers[0]["email"])
+	}
+	if duration, _ := strconv.Atoi(fmt.Sprint(volunteers[0]["duration"])); duration != 30 {
+		t.Fatalf("组织A中的服务时长应为 30 分钟，实际为 %v", volunteers[0]["duration"])
+	}
+	if _, err := GetVolunteerCount(0); err == nil {
+		t.Fatal("未进入组织时不应能统计志愿者")
+	}
+}
+
+func TestGetUserRoleWithoutOrganization(t *testing.T) {
+	setupTestDB(t)
+	orgA := createTestOrg(t, "组织A")
+	orgB := createTestOrg(t, "组织B")
+	user := createTestUser(t, "admin@example.com")
+	addTestMember(t, orgA, user, models.RoleOrgAdmin)
+
+	ok, err := HasPermission(user.Email, orgA, models.PermTaskCreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("组织管理员应能在本组织中创建活动")
+	}
+	for _, orgID := range []uint{0, orgB} {
+		_, role, err := GetUserRole(user.Email, orgID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(role.Permissions) != 0 || role.Name != "" {
+			t.Fatalf("在组织 %d 中不应有任何角色，实际为 %q", orgID, role.Name)
+		}
+	}
+}
+
+func TestOrganizationInvitationRequiresConsent(t *testing.T) {
+	notifications := setupTestDB(t)
+	orgService := NewOrganizationService(notifications)
+	orgA := createTestOrg(t, "组织A")
+	admin := createTestUser(t, "admin@example.com")
+	invitee := createTestUser(t, "invitee@example.com")
+	addTestMember(t, orgA, admin, models.RoleOrgAdmin)
+
+	if err := orgService.InviteOrganizationMember(orgA, admin.Email, invitee.Email, models.RoleCoordinator); err != nil {
+		t.Fatal(err)
+	}
+	if IsOrganizationMember(invitee.ID, orgA) {
+		t.Fatal("接受邀请前不应成为组织成员")
+	}
+	invitations, err := GetOrganizationInvitations(invitee.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invitations) != 1 || invitations[0].OrganizationID != orgA || invitations[0].Role != models.RoleCoordinator {
+		t.Fatalf("邀请列表不正确: %+v", invitations)
+	}
+	// 其他用户不能代为答复邀请
+	if _, err := RespondOrganizationInvitation(admin.Email, invitations[0].ID, true); err == nil {
+		t.Fatal("不应能答复发给其他用户的邀请")
+	}
+
+	info, err := RespondOrganizationInvitation(invitee.Email, invitations[0].ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != orgA || info.Role != models.RoleCoordinator {
+		t.Fatalf("接受邀请的返回不正确: %+v", info)
+	}
+	if !IsOrganizationMember(invitee.ID, orgA) {
+		t.Fatal("接受邀请后应成为组织成员")
+	}
+	if _, err := RespondOrganizationInvitation(invitee.Email, invitations[0].ID, true); err == nil {
+		t.Fatal("同一个邀请不应能答复两次")
+	}
+}
+
+func TestDeclineOrganizationInvitation(t *testing.T) {
+	notifications := setupTestDB(t)
+	orgService := NewOrganizationService(notifications)
+	orgA := createTestOrg(t, "组织A")
+	admin := createTestUser(t, "admin@example.com")
+	invitee := createTestUser(t, "invitee@example.com")
+	addTestMember(t, orgA, admin, models.RoleOrgAdmin)
+
+	if err := orgService.InviteOrganizationMember(orgA, admin.Email, invitee.Email, models.RoleVolunteer); err != nil {
+		t.Fatal(err)
+	}
+	invitations, err := GetOrganizationInvitations(invitee.Email)
+	if err != nil || len(invitations) != 1 {
+		t.Fatalf("邀请列表不正确: %+v, %v", invitations, err)
+	}
+	if _, err := RespondOrganizationInvitation(invitee.Email, invitations[0].ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if IsOrganizationMember(invitee.ID, orgA) {
+		t.Fatal("拒绝邀请后不应成为组织成员")
+	}
+	if invitations, _ := GetOrganizationInvitations(invitee.Email); len(invitations) != 0 {
+		t.Fatalf("拒绝后邀请应被删除: %+v", invitations)
+	}
+}
+
+func TestRegisterUserWithInvalidOrgCodeCreatesNoAccount(t *testing.T) {
+	userService := NewUserService(setupTestDB(t))
+	orgID := createTestOrg(t, "社团")
+	var org models.Organization
+	if err := models.DB.First(&org, orgID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := userService.RegisterUser("new@example.com", "新用户", "保密", "", "Passw0rd!test", "wrong-code"); err == nil {
+		t.Fatal("邀请码无效时应注册失败")
+	}
+	var count int64
+	models.DB.Model(&models.User{}).Where("email = ?", "new@example.com").Count(&count)
+	if count != 0 {
+		t.Fatal("邀请码无效时不应创建账户")
+	}
+
+	if err := userService.RegisterUser("new@example.com", "新用户", "保密", "", "Passw0rd!test", org.Code); err != nil {
+		t.Fatalf("修改邀请码后应能重新注册: %v", err)
+	}
+	var user models.User
+	if err := models.DB.Where("email = ?", "new@example.com").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !IsOrganizationMember(user.ID, orgID) {
+		t.Fatal("注册时应加入邀请码对应的组织")
+	}
+	models.DB.Model(&models.Message{}).Where("user_id = ? AND organization_id = ?", user.ID, orgID).Count(&count)
+	if count != 1 {
+		t.Fatalf("注册时加入组织应通知用户，实际消息数 %d", count)
+	}
+}
+
+func TestDefaultOrganizationUsesRandomCode(t *testing.T) {
+	orgService := NewOrganizationService(setupTestDB(t))
+	var org models.Organization
+	if err := models.DB.Where("name = ?", models.DefaultOrganizationName).First(&org).Error; err != nil {
+		t.Fatal(err)
+	}
+	if org.Code == "default" || len(org.Code) != 12 {
+		t.Fatalf("默认组织应使用随机邀请码，实际为 %q", org.Code)
+	}
+
+	// 旧版本迁移出的固定邀请码在升级时被替换
+	if err := models.DB.Model(&org).Update("code", "default").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := models.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orgService.JoinOrganization(createTestUser(t, "new@example.com").Email, "default"); err == nil {
+		t.Fatal("不应能凭固定邀请码加入默认组织")
+	}
+}
+
+func TestJoinOrganizationAndChangePasswordNotifyUser(t *testing.T) {
+	notifications := setupTestDB(t)
+	orgService := NewOrganizationService(notifications)
+	userService := NewUserService(notifications)
+	orgID := createTestOrg(t, "社团")
+	var org models.Organization
+	if err := models.DB.First(&org, orgID).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := createTestUser(t, "member@example.com")
+
+	if _, err := orgService.JoinOrganization(user.Email, org.Code); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := userService.ChangePassword(user.Email, orgID, "Passw0rd!test", "N3w-Passw0rd!"); err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []models.Message
+	if err := models.DB.Where("user_id = ?", user.ID).Order("id").Find(&messages).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("加入组织和修改密码都应通知用户，实际消息数 %d", len(messages))
+	}
+	if messages[0].OrganizationID != orgID || messages[0].Title != "加入组织通知" {
+		t.Fatalf("加入组织的消息不正确: %+v", messages[0])
+	}
+	if messages[1].OrganizationID != 0 || messages[1].Title != "密码修改通知" {
+		t.Fatalf("修改密码的消息应在所有组织中可见: %+v", messages[1])
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"volunteer-system-backend/config"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/templates"
+	"volunteer-system-backend/utils"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidResetToken 重置密码令牌无效、已使用或已过期
+var ErrInvalidResetToken = errors.New("重置链接无效或已过期")
+
+var (
+	// resetEmailLimiter 每个邮箱每小时最多申请3次重置密码
+	resetEmailLimiter = utils.NewRateLimiter(3, time.Hour)
+	// resetIPLimiter 每个IP每小时最多申请10次重置密码
+	resetIPLimiter = utils.NewRateLimiter(10, time.Hour)
+)
+
+// resetTokenExpiry 重置密码令牌的有效期，默认30分钟
+func resetTokenExpiry() time.Duration {
+	if config.ProjectConfig.Account.ResetExpiry > 0 {
+		return time.Duration(config.ProjectConfig.Account.ResetExpiry) * time.Minute
+	}
+	return 30 * time.Minute
+}
+
+// accountLink 生成邮件中指向前端页面的链接
+func accountLink(path, token string) string {
+	return strings.TrimRight(config.ProjectConfig.Account.FrontendURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// RequestPasswordReset 申请重置密码，向已注册的邮箱发送一次性重置链接
+// 无论邮箱是否注册都返回相同的结果，避免通过该接口探测已注册的邮箱
+func RequestPasswordReset(email, ip string) error {
+	email = strings.TrimSpace(email)
+	if !resetEmailLimiter.Allow(strings.ToLower(email)) || !resetIPLimiter.Allow(ip) {
+		return ErrRateLimited
+	}
+	var user models.User
+	if err := models.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := newRandomToken()
+	if err != nil {
+		return errors.New("生成重置令牌失败")
+	}
+	expiry := resetTokenExpiry()
+	body, err := templates.RenderEmail("password_reset.html", map[string]any{
+		"Nickname": user.Nickname,
+		"Link":     accountLink("/reset-password", token),
+		"Minutes":  int(expiry.Minutes()),
+	})
+	if err != nil {
+		return err
+	}
+	now := time.Now().Local()
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		// 新的重置链接发出后，之前未使用的链接作废
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("expires_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: hashToken(token),
+			ExpiresAt: now.Add(expiry),
+			RequestIP: ip,
+		}).Error; err != nil {
+			return errors.New("无法保存重置令牌")
+		}
+		return tx.Create(&models.EmailOutbox{
+			UserID:        user.ID,
+			To:            user.Email,
+			Subject:       "重置密码",
+			Body:          body,
+			Sensitive:     true,
+			Status:        models.EmailStatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}).Error
+	})
+}
+
+// ResetPassword 使用重置令牌设置新密码，令牌只能使用一次，重置后之前的登录全部失效
+func ResetPassword(token, newPassword string) error {
+	// 新密码不符合要求时不消耗重置令牌，用户可以换一个密码重试
+	var user models.User
+	if err := models.DB.Joins("JOIN password_reset_tokens ON password_reset_tokens.user_id = users.id").
+		Where("password_reset_tokens.token_hash = ?", hashToken(token)).First(&user).Error; err != nil {
+		return ErrInvalidResetToken
+	}
+	if err := validatePassword(newPassword, user.Email, user.Phone); err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.New("无法对密码进行哈希处理")
+	}
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		var record models.PasswordResetToken
+		if err := tx.Where("token_hash = ?", hashToken(token)).First(&record).Error; err != nil {
+			return ErrInvalidResetToken
+		}
+		now := time.Now().Local()
+		// 通过条件更新认领令牌，同一个令牌并发使用时只有一次成功
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", record.ID, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", record.UserID).
+			Updates(map[string]any{"password": string(hashedPassword), "must_change_password": false}).Error; err != nil {
+			return errors.New("无法更新密码")
+		}
+		// 通过邮件中的链接重置密码同样证明了对邮箱的所有权
+		if err := tx.Model(&models.User{}).Where("id = ? AND email_verified_at IS NULL", record.UserID).
+			Update("email_verified_at", now).Error; err != nil {
+			return err
+		}
+		return InvalidateUserSessions(tx, record.UserID)
+	})
+}
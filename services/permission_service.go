@@ -0,0 +1,194 @@
+package services
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"errors"
+	"gorm.io/gorm"
+)
+
+// ErrNoTaskPermission 没有管理该任务的权限
+var ErrNoTaskPermission = errors.New("没有管理该活动的权限")
+
+// GetUserRole 获取用户在指定组织中的角色及其权限
+// 超级管理员在所有组织中都使用全局角色，其他用户使用其在组织中的成员角色，没有进入组织或不是组织成员时没有任何权限
+func GetUserRole(email string, orgID uint) (*models.User, models.Role, error) {
+	var user models.User
+	if err := models.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, models.Role{}, errors.New("用户不存在")
+	}
+	role, err := models.GetRoleWithPermissions(user.RoleID)
+	if err != nil {
+		return nil, models.Role{}, err
+	}
+	if role.Name == models.RoleSuperAdmin {
+		return &user, role, nil
+	}
+	if orgID == 0 {
+		return &user, models.Role{}, nil
+	}
+	var member models.OrganizationMember
+	if err := models.DB.Where("organization_id = ? AND user_id = ?", orgID, user.ID).First(&member).Error; err != nil {
+		return &user, models.Role{}, nil
+	}
+	role, err = models.GetRoleWithPermissions(member.RoleID)
+	if err != nil {
+		return nil, models.Role{}, err
+	}
+	return &user, role, nil
+}
+
+// HasPermission 判断用户在指定组织中是否拥有指定权限
+func HasPermission(email string, orgID uint, permission string) (bool, error) {
+	_, role, err := GetUserRole(email, orgID)
+	if err != nil {
+		return false, err
+	}
+	return role.HasPermission(permission), nil
+}
+
+// GetUserPermissions 获取用户在指定组织中拥有的权限标识列表
+func GetUserPermissions(email string, orgID uint) (string, []string, error) {
+	_, role, err := GetUserRole(email, orgID)
+	if err != nil {
+		return "", nil, err
+	}
+	permissions := make([]string, len(role.Permissions))
+	for i, p := range role.Permissions {
+		permissions[i] = p.Name
+	}
+	return role.Name, permissions, nil
+}
+
+// CanManageTask 判断用户是否可以管理指定任务
+// 任务必须属于当前组织，拥有 task:manage 权限的用户可以管理组织内所有任务，拥有 task:coordinate 权限的协调员只能管理被分配的任务
+func CanManageTask(email string, orgID uint, taskId uint) (bool, error) {
+	if _, err := getOrgTask(orgID, taskId); err != nil {
+		return false, nil
+	}
+	user, role, err := GetUserRole(email, orgID)
+	if err != nil {
+		return false, err
+	}
+	if role.HasPermission(models.PermTaskManage) {
+		return true, nil
+	}
+	if !role.HasPermission(models.PermTaskCoordinate) {
+		return false, nil
+	}
+	var count int64
+	if err := models.DB.Model(&models.TaskCoordinator{}).Where("task_id = ? AND user_id = ?", taskId, user.ID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CanViewTaskAudit 判断用户是否可以查看指定任务的报名情况
+func CanViewTaskAudit(email string, orgID uint, taskId uint) (bool, error) {
+	if _, err := getOrgTask(orgID, taskId); err != nil {
+		return false, nil
+	}
+	ok, err := HasPermission(email, orgID, models.PermTaskAudit)
+	if err != nil || ok {
+		return ok, err
+	}
+	return CanManageTask(email, orgID, taskId)
+}
+
+// GetCoordinatedTaskIDs 获取用户在指定组织中作为协调员负责的任务ID列表
+func GetCoordinatedTaskIDs(email string, orgID uint) ([]uint, error) {
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	var taskIds []uint
+	if err := models.DB.Model(&models.TaskCoordinator{}).
+		Joins("JOIN tasks ON tasks.id = task_coordinators.task_id").
+		Where("task_coordinators.user_id = ? AND tasks.organization_id = ?", userId, orgID).
+		Pluck("task_coordinators.task_id", &taskIds).Error; err != nil {
+		return nil, err
+	}
+	return taskIds, nil
+}
+
+// GetRoles 获取所有角色及其权限
+func GetRoles() ([]dto.RoleInfo, error) {
+	var roles []models.Role
+	if err := models.DB.Preload("Permissions").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	roleInfos := make([]dto.RoleInfo, len(roles))
+	for i, role := range roles {
+		permissions := make([]string, len(role.Permissions))
+		for j, p := range role.Permissions {
+			permissions[j] = p.Name
+		}
+		roleInfos[i] = dto.RoleInfo{
+			Name:        role.Name,
+			DisplayName: role.DisplayName,
+			Permissions: permissions,
+		}
+	}
+	return roleInfos, nil
+}
+
+// AssignRole 为用户分配全局角色，角色变化后该用户之前的登录全部失效
+func AssignRole(email, roleName string) error {
+	role, err := models.GetRoleByName(roleName)
+	if err != nil {
+		return err
+	}
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return err
+	}
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ?", userId).Update("role_id", role.ID)
+		if result.Error != nil {
+			return errors.New("无法更新用户角色")
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("用户不存在或角色未变化")
+		}
+		return InvalidateUserSessions(tx, userId)
+	})
+}
+
+// rolesWithPermission 获取拥有指定权限的角色ID
+func rolesWithPermission(permission string) ([]uint, error) {
+	var roleIds []uint
+	if err := models.DB.Table("role_permissions").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("permissions.name = ?", permission).
+		Pluck("role_permissions.role_id", &roleIds).Error; err != nil {
+		return nil, err
+	}
+	return roleIds, nil
+}
+
+// getManagerUserIDs 获取组织内可以管理指定任务的用户ID，包括拥有 task:manage 权限的成员和该任务的协调员
+func getManagerUserIDs(orgID uint, taskId uint) ([]uint, error) {
+	roleIds, err := rolesWithPermission(models.PermTaskManage)
+	if err != nil {
+		return nil, err
+	}
+	var userIds []uint
+	if err := models.DB.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND role_id IN ?", orgID, roleIds).
+		Pluck("user_id", &userIds).Error; err != nil {
+		return nil, err
+	}
+	var coordinatorIds []uint
+	if err := models.DB.Model(&models.TaskCoordinator{}).Where("task_id = ?", taskId).Pluck("user_id", &coordinatorIds).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[uint]bool)
+	var result []uint
+	for _, id := range append(userIds, coordinatorIds...) {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
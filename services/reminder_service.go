@@ -0,0 +1,163 @@
+package services
+
+import (
+	"volunteer-system-backend/config"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/utils"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+// defaultReminderOffsets 未配置时在活动开始前24小时和2小时发送提醒
+var defaultReminderOffsets = []time.Duration{24 * time.Hour, 2 * time.Hour}
+
+// reminderOffsets 读取配置中的提醒时间点，忽略无法解析的配置
+func reminderOffsets() []time.Duration {
+	configured := config.ProjectConfig.Reminder.Offsets
+	if len(configured) == 0 {
+		return defaultReminderOffsets
+	}
+	offsets := make([]time.Duration, 0, len(configured))
+	for _, value := range configured {
+		offset, err := time.ParseDuration(value)
+		if err != nil || offset <= 0 {
+			log.Println("无效的活动提醒时间:", value)
+			continue
+		}
+		offsets = append(offsets, offset)
+	}
+	return offsets
+}
+
+// planTaskReminders 为活动创建尚未存在的提醒计划，已经过了发送时间的提醒不再创建
+func planTaskReminders(tx *gorm.DB, task models.Task) error {
+	now := time.Now().Local()
+	var reminders []models.TaskReminder
+	for _, offset := range reminderOffsets() {
+		remindAt := task.StartTime.Add(-offset)
+		if !remindAt.After(now) {
+			continue
+		}
+		reminders = append(reminders, models.TaskReminder{
+			TaskID:        task.ID,
+			OffsetMinutes: int(offset / time.Minute),
+			RemindAt:      remindAt,
+			CreatedAt:     now,
+		})
+	}
+	if len(reminders) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&reminders).Error
+}
+
+// replanTaskReminders 活动开始时间变化后删除原有的提醒计划并重新创建
+func replanTaskReminders(tx *gorm.DB, task models.Task) error {
+	if err := tx.Where("task_id = ?", task.ID).Delete(&models.TaskReminder{}).Error; err != nil {
+		return err
+	}
+	return planTaskReminders(tx, task)
+}
+
+// PlanUpcomingReminders 为所有尚未开始的活动补齐提醒计划，用于升级后的首次启动和提醒时间配置变更
+func PlanUpcomingReminders() error {
+	var tasks []models.Task
+	if err := models.DB.Where("start_time > ?", time.Now().Local()).Find(&tasks).Error; err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if err := planTaskReminders(models.DB, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatchTaskReminder 向活动中审核通过的报名人发送提醒
+// 通过条件更新 sent_at 认领提醒，并与消息写入放在同一事务中，保证服务重启或多实例运行时提醒只会发送一次
+func dispatchTaskReminder(notifications NotificationService, reminderId uint) error {
+	push := func() {}
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().Local()
+		result := tx.Model(&models.TaskReminder{}).Where("id = ? AND sent_at IS NULL", reminderId).Update("sent_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		var reminder models.TaskReminder
+		if err := tx.First(&reminder, reminderId).Error; err != nil {
+			return err
+		}
+		var task models.Task
+		if err := tx.First(&task, reminder.TaskID).Error; err != nil {
+			return err
+		}
+		var userIds []uint
+		if err := tx.Table("task_participants").
+			Joins("JOIN users ON users.email = task_participants.email").
+			Where("task_participants.task_id = ? AND task_participants.status = 1 AND task_participants.deleted_at IS NULL", task.ID).
+			Distinct().Pluck("users.id", &userIds).Error; err != nil {
+			return err
+		}
+		messages, p, err := notifications.EmitTx(tx, Event{
+			Type:           models.EventTaskReminder,
+			OrganizationID: task.OrganizationID,
+			Recipients:     userIds,
+			Data: MessageData{
+				"TaskName":  task.Name,
+				"StartTime": utils.FormatTime2Str(task.StartTime),
+				"Location":  task.Location,
+			},
+			ExpiresAt: &task.EndTime,
+		})
+		if err != nil {
+			return errors.New("无法发送活动提醒")
+		}
+		push = p
+		return tx.Model(&reminder).Update("recipients", len(messages)).Error
+	})
+	if err != nil {
+		return err
+	}
+	push()
+	return nil
+}
+
+// DispatchDueReminders 发送所有已到发送时间且活动尚未开始的提醒
+func DispatchDueReminders(notifications NotificationService) error {
+	now := time.Now().Local()
+	var ids []uint
+	if err := models.DB.Model(&models.TaskReminder{}).
+		Joins("JOIN tasks ON tasks.id = task_reminders.task_id").
+		Where("task_reminders.sent_at IS NULL AND task_reminders.remind_at <= ? AND tasks.start_time > ?", now, now).
+		Pluck("task_reminders.id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := dispatchTaskReminder(notifications, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartReminderScheduler 启动发送活动提醒的后台任务
+func StartReminderScheduler(interval time.Duration, notifications NotificationService) {
+	if err := PlanUpcomingReminders(); err != nil {
+		log.Println("创建活动提醒计划失败:", err)
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := DispatchDueReminders(notifications); err != nil {
+				log.Println("发送活动提醒失败:", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"volunteer-system-backend/models"
+	"errors"
+	"time"
+)
+
+// ErrInvalidStreamTicket 推送凭证无效、已过期或已被使用
+var ErrInvalidStreamTicket = errors.New("推送凭证无效或已过期")
+
+// streamTicketExpiry 推送凭证的有效期，凭证只用于立即建立连接
+const streamTicketExpiry = time.Minute
+
+// IssueStreamTicket 为当前登录签发建立消息推送连接的一次性凭证，凭证继承签发时的组织和令牌版本
+func IssueStreamTicket(email string, orgID, tokenVersion uint) (string, time.Time, error) {
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	ticket, err := newRandomToken()
+	if err != nil {
+		return "", time.Time{}, errors.New("无法生成推送凭证")
+	}
+	now := time.Now().Local()
+	expiresAt := now.Add(streamTicketExpiry)
+	if err := models.DB.Create(&models.MessageStreamTicket{
+		TicketHash:     hashToken(ticket),
+		UserID:         userId,
+		OrganizationID: orgID,
+		TokenVersion:   tokenVersion,
+		ExpiresAt:      expiresAt,
+		CreatedAt:      now,
+	}).Error; err != nil {
+		return "", time.Time{}, errors.New("无法保存推送凭证")
+	}
+	return ticket, expiresAt, nil
+}
+
+// RedeemStreamTicket 取出并删除推送凭证，同一个凭证并发使用时只有一次成功
+// 签发后修改了密码或角色的用户不能再使用之前签发的凭证
+func RedeemStreamTicket(ticket string) (*models.User, *models.MessageStreamTicket, error) {
+	var record models.MessageStreamTicket
+	if err := models.DB.Where("ticket_hash = ?", hashToken(ticket)).First(&record).Error; err != nil {
+		return nil, nil, ErrInvalidStreamTicket
+	}
+	result := models.DB.Where("id = ? AND expires_at > ?", record.ID, time.Now().Local()).Delete(&models.MessageStreamTicket{})
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, ErrInvalidStreamTicket
+	}
+	var user models.User
+	if err := models.DB.First(&user, record.UserID).Error; err != nil {
+		return nil, nil, ErrInvalidStreamTicket
+	}
+	if user.TokenVersion != record.TokenVersion {
+		return nil, nil, ErrInvalidStreamTicket
+	}
+	return &user, &record, nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"volunteer-system-backend/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStreamTicketIsSingleUse(t *testing.T) {
+	setupTestDB(t)
+	orgID := createTestOrg(t, "组织A")
+	user := createTestUser(t, "user@example.com")
+
+	ticket, expiresAt, err := IssueStreamTicket(user.Email, orgID, user.TokenVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(expiresAt) > streamTicketExpiry {
+		t.Fatalf("推送凭证的有效期不应超过 %v", streamTicketExpiry)
+	}
+	redeemed, record, err := RedeemStreamTicket(ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redeemed.ID != user.ID || record.OrganizationID != orgID {
+		t.Fatalf("推送凭证对应的用户或组织不正确: %d, %d", redeemed.ID, record.OrganizationID)
+	}
+	if _, _, err := RedeemStreamTicket(ticket); !errors.Is(err, ErrInvalidStreamTicket) {
+		t.Fatalf("推送凭证不应能使用两次，实际为 %v", err)
+	}
+	if _, _, err := RedeemStreamTicket("unknown"); !errors.Is(err, ErrInvalidStreamTicket) {
+		t.Fatalf("未知的推送凭证应被拒绝，实际为 %v", err)
+	}
+}
+
+func TestStreamTicketRejectedAfterExpiryOrSessionInvalidation(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "user@example.com")
+
+	expired, _, err := IssueStreamTicket(user.Email, 0, user.TokenVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.DB.Model(&models.MessageStreamTicket{}).Where("ticket_hash = ?", hashToken(expired)).
+		Update("expires_at", time.Now().Local().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := RedeemStreamTicket(expired); !errors.Is(err, ErrInvalidStreamTicket) {
+		t.Fatalf("过期的推送凭证应被拒绝，实际为 %v", err)
+	}
+
+	ticket, _, err := IssueStreamTicket(user.Email, 0, user.TokenVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := InvalidateUserSessions(models.DB, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := RedeemStreamTicket(ticket); !errors.Is(err, ErrInvalidStreamTicket) {
+		t.Fatalf("登录失效后签发的推送凭证应被拒绝，实际为 %v", err)
+	}
+}
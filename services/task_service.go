@@ -6,39 +6,63 @@ import (
 	"volunteer-system-backend/utils"
 	"errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"log"
 	"strings"
 	"time"
 )
 
-func CreateTask(name, startTime, endTime, location string, limit uint) (dto.TaskInfo, error) {
+// ErrAlreadyJoined 用户已经报名该活动
+var ErrAlreadyJoined = errors.New("用户已经报名该活动")
+
+// getOrgTask 查询属于指定组织的任务
+func getOrgTask(orgID uint, taskId uint) (models.Task, error) {
+	var task models.Task
+	if err := models.DB.Where("id = ? AND organization_id = ?", taskId, orgID).First(&task).Error; err != nil {
+		return models.Task{}, errors.New("活动不存在")
+	}
+	return task, nil
+}
+
+func CreateTask(orgID uint, name, startTime, endTime, location, category string, limit uint) (dto.TaskInfo, error) {
 	// 检查活动名是否已存在
 	var existingName models.Task
-	if err := models.DB.Where("name = ?", strings.TrimSpace(name)).First(&existingName).Error; err == nil {
+	if err := models.DB.Where("organization_id = ? AND name = ?", orgID, strings.TrimSpace(name)).First(&existingName).Error; err == nil {
 		return dto.TaskInfo{}, errors.New("该活动名称已经存在")
 	}
 
 	// 创建新活动
 	task := models.Task{
-		Name:      name,
-		CreatedAt: utils.FormatStr2Time(time.Now().Local().Format("2006-01-02 15:04:05")),
-		StartTime: utils.FormatStr2Time(startTime),
-		EndTime:   utils.FormatStr2Time(endTime),
-		Location:  location,
-		Limit:     limit,
-		Joined:    0,
+		OrganizationID: orgID,
+		Name:           name,
+		CreatedAt:      utils.FormatStr2Time(time.Now().Local().Format("2006-01-02 15:04:05")),
+		StartTime:      utils.FormatStr2Time(startTime),
+		EndTime:        utils.FormatStr2Time(endTime),
+		Location:       location,
+		Category:       strings.TrimSpace(category),
+		Limit:          limit,
+		Joined:         0,
 		// 确保 Participants 字段为空
 		Participants: []models.TaskParticipant{},
 	}
-	if err := models.DB.Create(&task).Error; err != nil {
-		return dto.TaskInfo{}, errors.New("无法创建活动")
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&task).Error; err != nil {
+			return errors.New("无法创建活动")
+		}
+		if err := planTaskReminders(tx, task); err != nil {
+			return errors.New("无法创建活动提醒")
+		}
+		return nil
+	})
+	if err != nil {
+		return dto.TaskInfo{}, err
 	}
 	return utils.ConvertTaskToDTO(task), nil
 }
 
-func GetTasks() ([]dto.TaskInfo, error) {
+func GetTasks(orgID uint) ([]dto.TaskInfo, error) {
 	var tasks []models.Task
-	if err := models.DB.Preload("Participants").Find(&tasks).Error; err != nil {
+	if err := models.DB.Preload("Participants").Where("organization_id = ?", orgID).Find(&tasks).Error; err != nil {
 		return nil, err
 	}
 	newTask := make([]dto.TaskInfo, len(tasks))
@@ -48,76 +72,162 @@ func GetTasks() ([]dto.TaskInfo, error) {
 	return newTask, nil
 }
 
-func JoinTask(taskInfo dto.TaskRegistrationRequest, nickname, email any) error {
+// TaskService 活动报名、审核和取消，这些操作会通知报名人和活动管理员
+type TaskService struct {
+	notifications NotificationService
+}
+
+// NewTaskService 创建活动服务
+func NewTaskService(notifications NotificationService) *TaskService {
+	return &TaskService{notifications: notifications}
+}
+
+func (s *TaskService) JoinTask(orgID uint, taskInfo dto.TaskRegistrationRequest, nickname, email any) error {
 	// 检查活动是否存在
 	var task models.Task
-	if err := models.DB.Where("id = ? AND name = ? ", taskInfo.ID, taskInfo.Name).First(&task).Error; err != nil {
+	if err := models.DB.Where("id = ? AND name = ? AND organization_id = ?", taskInfo.ID, taskInfo.Name, orgID).First(&task).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errors.New("活动不存在")
 		}
 		return err
 	}
-	// 检查活动是否已达到人数上限
-	if task.Joined >= task.Limit && task.Limit != 0 {
-		return errors.New("活动已达到人数上限")
-	}
-
-	// 检查用户是否已经报名
+	// 检查用户是否已经报名，并发报名时由唯一索引保证只有一次成功
 	var participant models.TaskParticipant
 	if err := models.DB.Where("task_id = ? AND email = ?", task.ID, email).First(&participant).Error; err == nil {
-		return errors.New("用户已经报名该活动")
+		return ErrAlreadyJoined
 	}
 
-	// 创建新的参加人员记录
-	newParticipant := models.TaskParticipant{
-		TaskID:   task.ID,
-		Nickname: nickname.(string),
-		Email:    email.(string),
-	}
-	if err := models.DB.Create(&newParticipant).Error; err != nil {
-		return errors.New("无法记录用户报名信息")
-	}
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		// 通过带人数条件的更新占用名额，并发报名时不会超过人数上限
+		result := tx.Model(&models.Task{}).Where("id = ? AND (`limit` = 0 OR joined < `limit`)", task.ID).
+			Update("joined", gorm.Expr("joined + 1"))
+		if result.Error != nil {
+			return errors.New("无法更新活动参加人数")
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("活动已达到人数上限")
+		}
 
-	// 更新已参加人数
-	task.Joined++
-	if err := models.DB.Save(&task).Error; err != nil {
-		return errors.New("无法更新活动参加人数")
+		// 创建新的参加人员记录
+		newParticipant := models.TaskParticipant{
+			TaskID:   task.ID,
+			Nickname: nickname.(string),
+			Email:    email.(string),
+		}
+		result = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&newParticipant)
+		if result.Error != nil {
+			return errors.New("无法记录用户报名信息")
+		}
+		if result.RowsAffected == 0 {
+			return ErrAlreadyJoined
+		}
+		if err := tx.Model(&newParticipant).Update("status", 0).Error; err != nil {
+			return errors.New("无法更新任务状态")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	var TaskParticipant dto.TaskParticipant
-	if err := models.DB.Model(&TaskParticipant).Where("task_id = ? AND email = ?", task.ID, email).Update("status", "0").Error; err != nil {
-		return errors.New("无法更新任务状态")
+	managerIds, err := getManagerUserIDs(orgID, task.ID)
+	if err != nil {
+		return errors.New("获取活动管理员失败")
+	}
+	if _, err := s.notifications.Emit(Event{
+		Type:           models.EventTaskJoined,
+		OrganizationID: orgID,
+		Recipients:     managerIds,
+		Data:           MessageData{"Nickname": nickname, "TaskName": task.Name},
+	}); err != nil {
+		return errors.New("创建消息失败")
 	}
-	_, err := CreateMessage(1, "新的待审核通知", "管理员您好，您有一条新的待审核活动")
+	userId, err := GetUserIDByEmail(email.(string))
 	if err != nil {
+		return errors.New("获取用户ID失败")
+	}
+	if _, err := s.notifications.Emit(Event{
+		Type:           models.EventRegistrationSubmitted,
+		OrganizationID: orgID,
+		Recipients:     []uint{userId},
+		Data:           MessageData{"TaskName": task.Name},
+	}); err != nil {
 		return errors.New("创建消息失败")
 	}
 	return nil
 }
 
-func DeleteTask(id string) error {
-	result := models.DB.Delete(&models.Task{}, id)
-	if result.RowsAffected == 0 {
-		return errors.New("活动不存在")
+// DeleteTask 删除活动，并通知待审核和审核通过的报名人活动已取消
+func (s *TaskService) DeleteTask(orgID uint, id string) error {
+	push := func() {}
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		var task models.Task
+		if err := tx.Where("id = ? AND organization_id = ?", id, orgID).First(&task).Error; err != nil {
+			return errors.New("活动不存在")
+		}
+		var userIds []uint
+		if err := tx.Table("task_participants").
+			Joins("JOIN users ON users.email = task_participants.email").
+			Where("task_participants.task_id = ? AND task_participants.status IN ? AND task_participants.deleted_at IS NULL", task.ID, []uint{0, 1}).
+			Distinct().Pluck("users.id", &userIds).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&task).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("task_id = ?", task.ID).Delete(&models.TaskReminder{}).Error; err != nil {
+			return err
+		}
+		_, p, err := s.notifications.EmitTx(tx, Event{
+			Type:           models.EventTaskCancelled,
+			OrganizationID: orgID,
+			Recipients:     userIds,
+			Data:           MessageData{"TaskName": task.Name},
+		})
+		if err != nil {
+			return errors.New("创建消息失败")
+		}
+		push = p
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	return result.Error
+	push()
+	return nil
 }
 
-func UpdateTask(name, startTime, endTime, location string, limit uint) (dto.TaskInfo, error) {
+func UpdateTask(orgID uint, name, startTime, endTime, location, category string, limit uint) (dto.TaskInfo, error) {
 	var task models.Task
-	if err := models.DB.Where("name = ?", strings.TrimSpace(name)).First(&task).Error; err != nil {
+	if err := models.DB.Where("organization_id = ? AND name = ?", orgID, strings.TrimSpace(name)).First(&task).Error; err != nil {
 		return dto.TaskInfo{}, errors.New("该活动不存在，无法修改")
 	}
 
 	// 使用 map 更新特定字段
+	newStartTime := utils.FormatStr2Time(startTime)
 	updates := map[string]interface{}{
-		"start_time": utils.FormatStr2Time(startTime),
+		"start_time": newStartTime,
 		"end_time":   utils.FormatStr2Time(endTime),
 		"location":   location,
+		"category":   strings.TrimSpace(category),
 		"limit":      limit,
 	}
 
-	if err := models.DB.Model(&task).Updates(updates).Error; err != nil {
-		return dto.TaskInfo{}, errors.New("无法修改活动")
+	startTimeChanged := !newStartTime.Equal(task.StartTime)
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&task).Updates(updates).Error; err != nil {
+			return errors.New("无法修改活动")
+		}
+		// 开始时间变化后重新安排活动提醒
+		if startTimeChanged {
+			task.StartTime = newStartTime
+			if err := replanTaskReminders(tx, task); err != nil {
+				return errors.New("无法更新活动提醒")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return dto.TaskInfo{}, err
 	}
 
 	// 重新查询更新后的完整记录
@@ -129,9 +239,9 @@ func UpdateTask(name, startTime, endTime, location string, limit uint) (dto.Task
 }
 
 // GetTaskDetails 获取任务详情
-func GetTaskDetails() ([]dto.TaskDetailResponse, error) {
+func GetTaskDetails(orgID uint) ([]dto.TaskDetailResponse, error) {
 	var tasks []models.Task
-	if err := models.DB.Preload("Participants").Find(&tasks).Error; err != nil {
+	if err := models.DB.Preload("Participants").Where("organization_id = ?", orgID).Find(&tasks).Error; err != nil {
 		return nil, err
 	}
 
@@ -141,7 +251,7 @@ func GetTaskDetails() ([]dto.TaskDetailResponse, error) {
 		for _, participant := range task.Participants {
 			currUser, err := GetUserProfile(participant.Email)
 			// 调用服务层的 GetTaskStatus 方法获取任务状态
-			taskStatus, err := GetTaskStatus(int(participant.TaskID), currUser.Nickname)
+			taskStatus, err := GetTaskStatus(orgID, int(participant.TaskID), currUser.Nickname)
 			if err != nil {
 				return nil, err
 			}
@@ -178,10 +288,10 @@ func GetTaskDetails() ([]dto.TaskDetailResponse, error) {
 }
 
 // GetTaskStatus 根据任务 ID 和用户名获取任务状态信息
-func GetTaskStatus(taskID int, nickname string) (dto.ParticipantStatus, error) {
+func GetTaskStatus(orgID uint, taskID int, nickname string) (dto.ParticipantStatus, error) {
 	// 首先根据任务 ID 查找任务
 	var task models.Task
-	if err := models.DB.Preload("Participants").Where("id = ?", taskID).First(&task).Error; err != nil {
+	if err := models.DB.Preload("Participants").Where("id = ? AND organization_id = ?", taskID, orgID).First(&task).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return dto.ParticipantStatus{}, errors.New("任务不存在")
 		}
@@ -204,18 +314,26 @@ func GetTaskStatus(taskID int, nickname string) (dto.ParticipantStatus, error) {
 	return taskParticipantStatus, nil
 }
 
-func GetTaskAuditDetail(TaskId uint) (dto.AuditResponse, error) {
+func GetTaskAuditDetail(orgID uint, TaskId uint) (dto.AuditResponse, error) {
+	if _, err := getOrgTask(orgID, TaskId); err != nil {
+		return dto.AuditResponse{}, err
+	}
 	var TaskParticipant models.TaskParticipant
 	if err := models.DB.Where("task_id = ?", TaskId).First(&TaskParticipant).Error; err != nil {
 		return dto.AuditResponse{}, errors.New("该活动暂时没有人报名或已结束")
 	}
 	var volunteers []dto.JoinTaskVolunteer
-	if err := models.DB.Table("task_participants").Where("task_id = ? AND status = 0", TaskId).Find(&volunteers).Error; err != nil {
+	if err := models.DB.Table("task_participants").Where("task_id = ? AND status = 0 AND team_id = 0", TaskId).Find(&volunteers).Error; err != nil {
+		return dto.AuditResponse{}, err
+	}
+	teams, err := GetTaskTeamRegistrations(TaskId)
+	if err != nil {
 		return dto.AuditResponse{}, err
 	}
 	AuditResponse := dto.AuditResponse{
 		TaskId:     TaskId,
 		Volunteers: volunteers,
+		Teams:      teams,
 	}
 	return AuditResponse, nil
 }
@@ -230,24 +348,11 @@ func GetTaskNameByID(taskId uint) (string, error) {
 	return task.Name, nil
 }
 
-// CreateMessage 创建新消息
-func CreateMessage(userID uint, title, content string) (*models.Message, error) {
-	message := &models.Message{
-		UserID:  userID,
-		Title:   title,
-		Content: content,
-		Time:    time.Now(),
-		Status:  "unread",
-	}
-	err := models.DB.Create(message).Error
-	if err != nil {
-		return nil, err
-	}
-	return message, nil
-}
-
 // ApproveVolunteer 通过报名人审核
-func ApproveVolunteer(taskId uint, email string) error {
+func (s *TaskService) ApproveVolunteer(orgID uint, taskId uint, email string) error {
+	if _, err := getOrgTask(orgID, taskId); err != nil {
+		return err
+	}
 	var TaskParticipant models.TaskParticipant
 	if err := models.DB.Where("task_id = ? AND email = ?", taskId, email).First(&TaskParticipant).Error; err != nil {
 		return errors.New("该活动已结束或该用户没有报名该活动")
@@ -258,6 +363,9 @@ func ApproveVolunteer(taskId uint, email string) error {
 		}
 		return errors.New("该用户无需审核")
 	}
+	if TaskParticipant.TeamID != 0 {
+		return errors.New("该用户属于团队报名，请按团队整体审核")
+	}
 	if TaskParticipant.Status == 0 {
 		if err := models.DB.Model(&TaskParticipant).Update("status", 1).Error; err != nil {
 			return errors.New("更新任务状态失败")
@@ -271,7 +379,12 @@ func ApproveVolunteer(taskId uint, email string) error {
 		if err != nil {
 			return errors.New("获取任务名称失败")
 		}
-		_, err = CreateMessage(userId, "申请通过通知", "您的申请已被通过，活动名称: \""+taskName+"\"")
+		_, err = s.notifications.Emit(Event{
+			Type:           models.EventVolunteerApproved,
+			OrganizationID: orgID,
+			Recipients:     []uint{userId},
+			Data:           MessageData{"TaskName": taskName},
+		})
 		if err != nil {
 			log.Println(err)
 			return errors.New("创建消息失败")
@@ -282,7 +395,10 @@ func ApproveVolunteer(taskId uint, email string) error {
 }
 
 // RejectVolunteer 拒绝报名人审核
-func RejectVolunteer(taskId uint, email string) error {
+func (s *TaskService) RejectVolunteer(orgID uint, taskId uint, email string) error {
+	if _, err := getOrgTask(orgID, taskId); err != nil {
+		return err
+	}
 	var TaskParticipant models.TaskParticipant
 	if err := models.DB.Where("task_id = ? AND email = ?", taskId, email).First(&TaskParticipant).Error; err != nil {
 		return errors.New("该活动已结束或该用户没有报名该活动")
@@ -293,6 +409,9 @@ func RejectVolunteer(taskId uint, email string) error {
 		}
 		return errors.New("该用户无需审核")
 	}
+	if TaskParticipant.TeamID != 0 {
+		return errors.New("该用户属于团队报名，请按团队整体审核")
+	}
 	if TaskParticipant.Status == 0 {
 		if err := models.DB.Model(&TaskParticipant).Update("status", 2).Error; err != nil {
 			return errors.New("更新任务状态失败")
@@ -306,7 +425,12 @@ func RejectVolunteer(taskId uint, email string) error {
 		if err != nil {
 			return errors.New("获取任务名称失败")
 		}
-		_, err = CreateMessage(userId, "申请拒绝通知", "很抱歉，您的申请已被拒绝，活动名称: \""+taskName+"\"")
+		_, err = s.notifications.Emit(Event{
+			Type:           models.EventVolunteerRejected,
+			OrganizationID: orgID,
+			Recipients:     []uint{userId},
+			Data:           MessageData{"TaskName": taskName},
+		})
 		if err != nil {
 			log.Println(err)
 			return errors.New("创建消息失败")
@@ -315,3 +439,152 @@ func RejectVolunteer(taskId uint, email string) error {
 	}
 	return nil
 }
+
+// AssignCoordinator 为任务分配协调员，协调员必须是任务所属组织的成员
+func AssignCoordinator(orgID uint, taskId uint, email string) error {
+	if _, err := getOrgTask(orgID, taskId); err != nil {
+		return err
+	}
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return err
+	}
+	ok, err := HasPermission(email, orgID, models.PermTaskCoordinate)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("该用户没有协调员角色")
+	}
+	var existing models.TaskCoordinator
+	if err := models.DB.Where("task_id = ? AND user_id = ?", taskId, userId).First(&existing).Error; err == nil {
+		return errors.New("该用户已经是该活动的协调员")
+	}
+	coordinator := models.TaskCoordinator{
+		TaskID:    taskId,
+		UserID:    userId,
+		CreatedAt: time.Now().Local(),
+	}
+	if err := models.DB.Create(&coordinator).Error; err != nil {
+		return errors.New("无法分配协调员")
+	}
+	return nil
+}
+
+// RemoveCoordinator 移除任务的协调员
+func RemoveCoordinator(orgID uint, taskId uint, email string) error {
+	if _, err := getOrgTask(orgID, taskId); err != nil {
+		return err
+	}
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return err
+	}
+	result := models.DB.Where("task_id = ? AND user_id = ?", taskId, userId).Delete(&models.TaskCoordinator{})
+	if result.Error != nil {
+		return errors.New("无法移除协调员")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("该用户不是该活动的协调员")
+	}
+	return nil
+}
+
+// GetTaskCoordinators 获取任务的协调员列表
+func GetTaskCoordinators(orgID uint, taskId uint) ([]dto.CoordinatorInfo, error) {
+	if _, err := getOrgTask(orgID, taskId); err != nil {
+		return nil, err
+	}
+	var coordinators []dto.CoordinatorInfo
+	if err := models.DB.Table("task_coordinators").
+		Select("users.id, users.email, users.nickname").
+		Joins("JOIN users ON users.id = task_coordinators.user_id").
+		Where("task_coordinators.task_id = ?", taskId).
+		Scan(&coordinators).Error; err != nil {
+		return nil, err
+	}
+	return coordinators, nil
+}
+
+// GetCoordinatorTasks 获取协调员负责的任务列表
+func GetCoordinatorTasks(orgID uint, email string) ([]dto.TaskInfo, error) {
+	taskIds, err := GetCoordinatedTaskIDs(email, orgID)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []models.Task
+	if len(taskIds) > 0 {
+		if err := models.DB.Where("id IN ?", taskIds).Find(&tasks).Error; err != nil {
+			return nil, err
+		}
+	}
+	newTask := make([]dto.TaskInfo, len(tasks))
+	for i, task := range tasks {
+		newTask[i] = utils.ConvertTaskToDTO(task)
+	}
+	return newTask, nil
+}
+
+// CheckInVolunteer 为审核通过的报名人签到
+func CheckInVolunteer(orgID uint, taskId uint, email string) error {
+	if _, err := getOrgTask(orgID, taskId); err != nil {
+		return err
+	}
+	var TaskParticipant models.TaskParticipant
+	if err := models.DB.Where("task_id = ? AND email = ?", taskId, email).First(&TaskParticipant).Error; err != nil {
+		return errors.New("该用户没有报名该活动")
+	}
+	if TaskParticipant.Status != 1 {
+		return errors.New("该用户的报名未通过审核")
+	}
+	if TaskParticipant.CheckInTime != nil {
+		return errors.New("该用户已经签到")
+	}
+	now := time.Now().Local()
+	if err := models.DB.Model(&TaskParticipant).Update("check_in_time", now).Error; err != nil {
+		return errors.New("更新签到状态失败")
+	}
+	return nil
+}
+
+// ConfirmVolunteerHours 确认报名人的志愿时长，minutes 为 0 时按活动时长计算
+func ConfirmVolunteerHours(orgID uint, taskId uint, email string, minutes uint) error {
+	task, err := getOrgTask(orgID, taskId)
+	if err != nil {
+		return err
+	}
+	var TaskParticipant models.TaskParticipant
+	if err := models.DB.Where("task_id = ? AND email = ?", taskId, email).First(&TaskParticipant).Error; err != nil {
+		return errors.New("该用户没有报名该活动")
+	}
+	if TaskParticipant.CheckInTime == nil {
+		return errors.New("该用户尚未签到")
+	}
+	if TaskParticipant.HoursConfirmed {
+		return errors.New("该用户的志愿时长已经确认")
+	}
+	if minutes == 0 {
+		minutes = uint(task.EndTime.Sub(task.StartTime).Minutes())
+	}
+
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		// 通过条件更新认领确认，同一个报名并发确认时只有一次成功，避免重复累加时长
+		result := tx.Model(&models.TaskParticipant{}).
+			Where("id = ? AND hours_confirmed = ?", TaskParticipant.ID, false).
+			Updates(map[string]interface{}{
+				"confirmed_minutes": minutes,
+				"hours_confirmed":   true,
+			})
+		if result.Error != nil {
+			return errors.New("更新志愿时长失败")
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("该用户的志愿时长已经确认")
+		}
+		if err := tx.Model(&models.User{}).Where("email = ?", email).
+			Update("duration", gorm.Expr("duration + ?", minutes)).Error; err != nil {
+			return errors.New("更新用户志愿时长失败")
+		}
+		return nil
+	})
+}
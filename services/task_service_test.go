@@ -0,0 +1,190 @@
+package services
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestJoinTaskDoesNotExceedLimit(t *testing.T) {
+	notifications := setupTestDB(t)
+	taskService := NewTaskService(notifications)
+	orgID := createTestOrg(t, "组织A")
+	task := createTestTask(t, orgID, "限额活动", 3)
+	var users []*models.User
+	for i := 0; i < 10; i++ {
+		user := createTestUser(t, fmt.Sprintf("user%d@example.com", i))
+		addTestMember(t, orgID, user, models.RoleVolunteer)
+		users = append(users, user)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(users))
+	for _, user := range users {
+		wg.Add(1)
+		go func(user *models.User) {
+			defer wg.Done()
+			errs <- taskService.JoinTask(orgID, dto.TaskRegistrationRequest{ID: task.ID, Name: task.Name}, user.Nickname, user.Email)
+		}(user)
+	}
+	wg.Wait()
+	close(errs)
+	joined := 0
+	for err := range errs {
+		if err == nil {
+			joined++
+		} else if err.Error() != "活动已达到人数上限" {
+			t.Errorf("意外的错误: %v", err)
+		}
+	}
+	if joined != 3 {
+		t.Fatalf("应有 3 人报名成功，实际为 %d", joined)
+	}
+	var saved models.Task
+	if err := models.DB.First(&saved, task.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	var participants int64
+	models.DB.Model(&models.TaskParticipant{}).Where("task_id = ? AND status = 0", task.ID).Count(&participants)
+	if saved.Joined != 3 || participants != 3 {
+		t.Fatalf("已参加人数为 %d，待审核报名为 %d，应均为 3", saved.Joined, participants)
+	}
+}
+
+func TestJoinTaskRejectsDuplicate(t *testing.T) {
+	notifications := setupTestDB(t)
+	taskService := NewTaskService(notifications)
+	orgID := createTestOrg(t, "组织A")
+	task := createTestTask(t, orgID, "活动", 0)
+	user := createTestUser(t, "user@example.com")
+	addTestMember(t, orgID, user, models.RoleVolunteer)
+	request := dto.TaskRegistrationRequest{ID: task.ID, Name: task.Name}
+	if err := taskService.JoinTask(orgID, request, user.Nickname, user.Email); err != nil {
+		t.Fatal(err)
+	}
+	if err := taskService.JoinTask(orgID, request, user.Nickname, user.Email); err == nil {
+		t.Fatal("重复报名应失败")
+	}
+	var saved models.Task
+	if err := models.DB.First(&saved, task.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.Joined != 1 {
+		t.Fatalf("已参加人数应为 1，实际为 %d", saved.Joined)
+	}
+}
+
+func TestJoinTaskConcurrentDuplicate(t *testing.T) {
+	notifications := setupTestDB(t)
+	taskService := NewTaskService(notifications)
+	orgID := createTestOrg(t, "组织A")
+	task := createTestTask(t, orgID, "活动", 0)
+	user := createTestUser(t, "user@example.com")
+	addTestMember(t, orgID, user, models.RoleVolunteer)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- taskService.JoinTask(orgID, dto.TaskRegistrationRequest{ID: task.ID, Name: task.Name}, user.Nickname, user.Email)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	joined := 0
+	for err := range errs {
+		if err == nil {
+			joined++
+		} else if !errors.Is(err, ErrAlreadyJoined) {
+			t.Errorf("意外的错误: %v", err)
+		}
+	}
+	var saved models.Task
+	if err := models.DB.First(&saved, task.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	var participants int64
+	models.DB.Model(&models.TaskParticipant{}).Where("task_id = ? AND email = ?", task.ID, user.Email).Count(&participants)
+	if joined != 1 || participants != 1 || saved.Joined != 1 {
+		t.Fatalf("同一用户并发报名只能成功一次，实际成功 %d 次，报名记录 %d 条，已参加人数 %d", joined, participants, saved.Joined)
+	}
+}
+
+func TestMigrateRemovesDuplicateParticipants(t *testing.T) {
+	setupTestDB(t)
+	orgID := createTestOrg(t, "组织A")
+	task := createTestTask(t, orgID, "活动", 0)
+	if err := models.DB.Migrator().DropIndex(&models.TaskParticipant{}, "idx_task_participant"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := models.DB.Create(&models.TaskParticipant{TaskID: task.ID, Nickname: "用户", Email: "user@example.com"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	models.DB.Model(&models.TaskParticipant{}).Where("task_id = ?", task.ID).Update("status", 0)
+	models.DB.Model(&task).Update("joined", 2)
+
+	if err := models.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	var participants int64
+	models.DB.Unscoped().Model(&models.TaskParticipant{}).Where("task_id = ?", task.ID).Count(&participants)
+	var saved models.Task
+	if err := models.DB.First(&saved, task.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if participants != 1 || saved.Joined != 1 {
+		t.Fatalf("迁移后应只保留一条报名记录并释放名额，实际 %d 条，已参加人数 %d", participants, saved.Joined)
+	}
+	if !models.DB.Migrator().HasIndex(&models.TaskParticipant{}, "idx_task_participant") {
+		t.Fatal("迁移后应创建唯一索引")
+	}
+}
+
+// eventTitles 按时间顺序返回用户收到的消息标题
+func eventTitles(t *testing.T, userID uint) []string {
+	t.Helper()
+	var titles []string
+	if err := models.DB.Model(&models.Message{}).Where("user_id = ?", userID).Order("id").Pluck("title", &titles).Error; err != nil {
+		t.Fatal(err)
+	}
+	return titles
+}
+
+func TestTaskServiceEmitsRegistrationEvents(t *testing.T) {
+	notifications := setupTestDB(t)
+	taskService := NewTaskService(notifications)
+	orgID := createTestOrg(t, "组织A")
+	admin := createTestUser(t, "admin@example.com")
+	volunteer := createTestUser(t, "volunteer@example.com")
+	addTestMember(t, orgID, admin, models.RoleOrgAdmin)
+	addTestMember(t, orgID, volunteer, models.RoleVolunteer)
+	task := createTestTask(t, orgID, "活动", 0)
+
+	if err := taskService.JoinTask(orgID, dto.TaskRegistrationRequest{ID: task.ID, Name: task.Name}, volunteer.Nickname, volunteer.Email); err != nil {
+		t.Fatal(err)
+	}
+	if titles := eventTitles(t, admin.ID); len(titles) != 1 || titles[0] != "新的待审核通知" {
+		t.Fatalf("管理员应收到待审核通知: %v", titles)
+	}
+	if titles := eventTitles(t, volunteer.ID); len(titles) != 1 || titles[0] != "报名提交通知" {
+		t.Fatalf("报名人应收到报名提交通知: %v", titles)
+	}
+
+	if err := taskService.ApproveVolunteer(orgID, task.ID, volunteer.Email); err != nil {
+		t.Fatal(err)
+	}
+	if err := taskService.DeleteTask(orgID, fmt.Sprint(task.ID)); err != nil {
+		t.Fatal(err)
+	}
+	titles := eventTitles(t, volunteer.ID)
+	if len(titles) != 3 || titles[1] != "申请通过通知" || titles[2] != "活动取消通知" {
+		t.Fatalf("报名人收到的通知不正确: %v", titles)
+	}
+}
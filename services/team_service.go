@@ -0,0 +1,366 @@
+package services
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
+	"time"
+)
+
+// getLeaderTeam 查询由指定用户担任队长的团队
+func getLeaderTeam(orgID uint, teamId uint, leaderEmail string) (models.Team, error) {
+	leaderId, err := GetUserIDByEmail(leaderEmail)
+	if err != nil {
+		return models.Team{}, err
+	}
+	var team models.Team
+	if err := models.DB.Where("id = ? AND organization_id = ?", teamId, orgID).First(&team).Error; err != nil {
+		return models.Team{}, errors.New("团队不存在")
+	}
+	if team.LeaderID != leaderId {
+		return models.Team{}, errors.New("只有队长可以进行该操作")
+	}
+	return team, nil
+}
+
+// CreateTeam 创建团队，创建者成为队长
+func CreateTeam(orgID uint, leaderEmail, name string) (dto.TeamInfo, error) {
+	leaderId, err := GetUserIDByEmail(leaderEmail)
+	if err != nil {
+		return dto.TeamInfo{}, err
+	}
+	name = strings.TrimSpace(name)
+	var existing models.Team
+	if err := models.DB.Where("organization_id = ? AND name = ?", orgID, name).First(&existing).Error; err == nil {
+		return dto.TeamInfo{}, errors.New("该团队名称已经存在")
+	}
+	team := models.Team{
+		OrganizationID: orgID,
+		Name:           name,
+		LeaderID:       leaderId,
+		CreatedAt:      time.Now().Local(),
+	}
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&team).Error; err != nil {
+			return errors.New("无法创建团队")
+		}
+		leader := models.TeamMember{
+			TeamID:    team.ID,
+			UserID:    leaderId,
+			Status:    1,
+			CreatedAt: time.Now().Local(),
+		}
+		if err := tx.Create(&leader).Error; err != nil {
+			return errors.New("无法创建团队")
+		}
+		return nil
+	})
+	if err != nil {
+		return dto.TeamInfo{}, err
+	}
+	return dto.TeamInfo{ID: team.ID, Name: team.Name, LeaderID: team.LeaderID}, nil
+}
+
+// TeamService 团队邀请、团队报名和团队审核
+type TeamService struct {
+	notifications NotificationService
+}
+
+// NewTeamService 创建团队服务
+func NewTeamService(notifications NotificationService) *TeamService {
+	return &TeamService{notifications: notifications}
+}
+
+// InviteTeamMember 队长邀请组织内的用户加入团队
+func (s *TeamService) InviteTeamMember(orgID uint, leaderEmail string, teamId uint, email string) error {
+	team, err := getLeaderTeam(orgID, teamId, leaderEmail)
+	if err != nil {
+		return err
+	}
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return err
+	}
+	if !IsOrganizationMember(userId, orgID) {
+		return errors.New("该用户不是本组织成员")
+	}
+	var existing models.TeamMember
+	if err := models.DB.Where("team_id = ? AND user_id = ?", team.ID, userId).First(&existing).Error; err == nil {
+		return errors.New("该用户已被邀请或已在团队中")
+	}
+	member := models.TeamMember{
+		TeamID:    team.ID,
+		UserID:    userId,
+		Status:    0,
+		CreatedAt: time.Now().Local(),
+	}
+	if err := models.DB.Create(&member).Error; err != nil {
+		return errors.New("无法邀请团队成员")
+	}
+	if _, err := s.notifications.Emit(Event{
+		Type:           models.EventTeamInvited,
+		OrganizationID: orgID,
+		Recipients:     []uint{userId},
+		Data:           MessageData{"TeamName": team.Name},
+	}); err != nil {
+		return errors.New("创建消息失败")
+	}
+	return nil
+}
+
+// RespondTeamInvitation 接受或拒绝团队邀请
+func RespondTeamInvitation(orgID uint, email string, teamId uint, accept bool) error {
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return err
+	}
+	var team models.Team
+	if err := models.DB.Where("id = ? AND organization_id = ?", teamId, orgID).First(&team).Error; err != nil {
+		return errors.New("团队不存在")
+	}
+	var member models.TeamMember
+	if err := models.DB.Where("team_id = ? AND user_id = ? AND status = 0", teamId, userId).First(&member).Error; err != nil {
+		return errors.New("没有待处理的团队邀请")
+	}
+	if !accept {
+		if err := models.DB.Delete(&member).Error; err != nil {
+			return errors.New("无法拒绝团队邀请")
+		}
+		return nil
+	}
+	if err := models.DB.Model(&member).Update("status", 1).Error; err != nil {
+		return errors.New("无法加入团队")
+	}
+	return nil
+}
+
+// GetUserTeams 获取用户在当前组织中加入或被邀请的团队
+func GetUserTeams(orgID uint, email string) ([]dto.TeamInfo, error) {
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	var teams []dto.TeamInfo
+	if err := models.DB.Table("team_members").
+		Select("teams.id, teams.name, teams.leader_id, team_members.status").
+		Joins("JOIN teams ON teams.id = team_members.team_id").
+		Where("team_members.user_id = ? AND teams.organization_id = ?", userId, orgID).
+		Scan(&teams).Error; err != nil {
+		return nil, err
+	}
+	for i := range teams {
+		var members []dto.TeamMemberInfo
+		if err := models.DB.Table("team_members").
+			Select("users.id, users.email, users.nickname, team_members.status").
+			Joins("JOIN users ON users.id = team_members.user_id").
+			Where("team_members.team_id = ?", teams[i].ID).
+			Scan(&members).Error; err != nil {
+			return nil, err
+		}
+		teams[i].Members = members
+	}
+	return teams, nil
+}
+
+// JoinTaskAsTeam 队长为团队报名活动
+// 在同一个事务中锁定活动记录，按已加入的成员数原子地占用名额，并为每个成员创建报名记录
+func (s *TeamService) JoinTaskAsTeam(orgID uint, leaderEmail string, teamId, taskId uint) error {
+	team, err := getLeaderTeam(orgID, teamId, leaderEmail)
+	if err != nil {
+		return err
+	}
+	var members []models.User
+	if err := models.DB.Table("users").
+		Joins("JOIN team_members ON team_members.user_id = users.id").
+		Where("team_members.team_id = ? AND team_members.status = 1", team.ID).
+		Find(&members).Error; err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return errors.New("团队没有成员")
+	}
+	slots := uint(len(members))
+
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		var task models.Task
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND organization_id = ?", taskId, orgID).First(&task).Error; err != nil {
+			return errors.New("活动不存在")
+		}
+		if task.Limit != 0 && task.Joined+slots > task.Limit {
+			return errors.New("活动剩余名额不足")
+		}
+		var existing models.TeamRegistration
+		if err := tx.Where("team_id = ? AND task_id = ?", team.ID, task.ID).First(&existing).Error; err == nil {
+			return errors.New("团队已经报名该活动")
+		}
+		emails := make([]string, len(members))
+		for i, member := range members {
+			emails[i] = member.Email
+		}
+		var registered int64
+		if err := tx.Model(&models.TaskParticipant{}).Where("task_id = ? AND email IN ?", task.ID, emails).Count(&registered).Error; err != nil {
+			return err
+		}
+		if registered > 0 {
+			return errors.New("团队中有成员已经报名该活动")
+		}
+
+		registration := models.TeamRegistration{
+			TeamID:    team.ID,
+			TaskID:    task.ID,
+			Slots:     slots,
+			Status:    0,
+			CreatedAt: time.Now().Local(),
+		}
+		if err := tx.Create(&registration).Error; err != nil {
+			return errors.New("无法记录团队报名信息")
+		}
+		for _, member := range members {
+			participant := models.TaskParticipant{
+				TaskID:   task.ID,
+				Nickname: member.Nickname,
+				Email:    member.Email,
+				Status:   0,
+				TeamID:   team.ID,
+			}
+			// 检查之后成员可能同时以个人身份报名，由唯一索引拒绝重复的报名
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&participant)
+			if result.Error != nil {
+				return errors.New("无法记录用户报名信息")
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("团队中有成员已经报名该活动")
+			}
+		}
+		// 与个人报名一致，创建后显式将状态置为待审核
+		if err := tx.Model(&models.TaskParticipant{}).Where("task_id = ? AND team_id = ?", task.ID, team.ID).
+			Update("status", 0).Error; err != nil {
+			return errors.New("无法更新任务状态")
+		}
+		if err := tx.Model(&task).Update("joined", gorm.Expr("joined + ?", slots)).Error; err != nil {
+			return errors.New("无法更新活动参加人数")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	taskName, err := GetTaskNameByID(taskId)
+	if err != nil {
+		return errors.New("获取任务名称失败")
+	}
+	managerIds, err := getManagerUserIDs(orgID, taskId)
+	if err != nil {
+		return errors.New("获取活动管理员失败")
+	}
+	if _, err := s.notifications.Emit(Event{
+		Type:           models.EventTeamJoined,
+		OrganizationID: orgID,
+		Recipients:     managerIds,
+		Data:           MessageData{"TeamName": team.Name, "TaskName": taskName},
+	}); err != nil {
+		return errors.New("创建消息失败")
+	}
+	memberIds := make([]uint, len(members))
+	for i, member := range members {
+		memberIds[i] = member.ID
+	}
+	if _, err := s.notifications.Emit(Event{
+		Type:           models.EventRegistrationSubmitted,
+		OrganizationID: orgID,
+		Recipients:     memberIds,
+		Data:           MessageData{"TaskName": taskName},
+	}); err != nil {
+		return errors.New("创建消息失败")
+	}
+	return nil
+}
+
+// reviewTeamRegistration 整体审核团队报名，审核不通过时释放占用的名额
+func (s *TeamService) reviewTeamRegistration(orgID uint, teamId, taskId uint, approved bool) error {
+	task, err := getOrgTask(orgID, taskId)
+	if err != nil {
+		return err
+	}
+	var team models.Team
+	if err := models.DB.Where("id = ? AND organization_id = ?", teamId, orgID).First(&team).Error; err != nil {
+		return errors.New("团队不存在")
+	}
+	var status uint = 2
+	if approved {
+		status = 1
+	}
+
+	var registration models.TeamRegistration
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("team_id = ? AND task_id = ?", teamId, taskId).First(&registration).Error; err != nil {
+			return errors.New("该团队没有报名该活动")
+		}
+		if registration.Status != 0 {
+			return errors.New("该团队无需审核")
+		}
+		if err := tx.Model(&registration).Update("status", status).Error; err != nil {
+			return errors.New("更新团队报名状态失败")
+		}
+		if err := tx.Model(&models.TaskParticipant{}).Where("task_id = ? AND team_id = ? AND status = 0", taskId, teamId).
+			Update("status", status).Error; err != nil {
+			return errors.New("更新任务状态失败")
+		}
+		if !approved {
+			if err := tx.Model(&models.Task{}).Where("id = ? AND joined >= ?", taskId, registration.Slots).
+				Update("joined", gorm.Expr("joined - ?", registration.Slots)).Error; err != nil {
+				return errors.New("无法更新活动参加人数")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var memberIds []uint
+	if err := models.DB.Model(&models.TeamMember{}).Where("team_id = ? AND status = 1", teamId).Pluck("user_id", &memberIds).Error; err != nil {
+		return err
+	}
+	eventType := models.EventTeamApproved
+	if !approved {
+		eventType = models.EventTeamRejected
+	}
+	if _, err := s.notifications.Emit(Event{
+		Type:           eventType,
+		OrganizationID: orgID,
+		Recipients:     memberIds,
+		Data:           MessageData{"TeamName": team.Name, "TaskName": task.Name},
+	}); err != nil {
+		return errors.New("创建消息失败")
+	}
+	return nil
+}
+
+// ApproveTeam 整体通过团队报名
+func (s *TeamService) ApproveTeam(orgID uint, teamId, taskId uint) error {
+	return s.reviewTeamRegistration(orgID, teamId, taskId, true)
+}
+
+// RejectTeam 整体拒绝团队报名
+func (s *TeamService) RejectTeam(orgID uint, teamId, taskId uint) error {
+	return s.reviewTeamRegistration(orgID, teamId, taskId, false)
+}
+
+// GetTaskTeamRegistrations 获取活动中待审核的团队报名
+func GetTaskTeamRegistrations(taskId uint) ([]dto.TeamRegistrationInfo, error) {
+	var registrations []dto.TeamRegistrationInfo
+	if err := models.DB.Table("team_registrations").
+		Select("teams.id AS team_id, teams.name AS team_name, team_registrations.slots, team_registrations.status").
+		Joins("JOIN teams ON teams.id = team_registrations.team_id").
+		Where("team_registrations.task_id = ? AND team_registrations.status = 0", taskId).
+		Scan(&registrations).Error; err != nil {
+		return nil, err
+	}
+	return registrations, nil
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/utils"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("刷新令牌无效或已过期")
+	ErrRefreshTokenReused  = errors.New("刷新令牌已被使用，该登录已失效，请重新登录")
+)
+
+// hashToken 计算令牌的摘要，数据库中只保存摘要
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRandomToken 生成随机的令牌，用于刷新令牌和邮件中的一次性链接
+func newRandomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// issueTokens 在事务中签发访问令牌和刷新令牌，familyID 为空时开始一个新的令牌家族
+func issueTokens(tx *gorm.DB, user *models.User, orgID uint, familyID string, loginTime time.Time) (dto.TokenPair, models.RefreshToken, error) {
+	token, claims, err := utils.GenerateJWT(user.Email, user.Nickname, orgID, user.TokenVersion, loginTime)
+	if err != nil {
+		return dto.TokenPair{}, models.RefreshToken{}, errors.New("生成 token 失败")
+	}
+	refresh, err := newRandomToken()
+	if err != nil {
+		return dto.TokenPair{}, models.RefreshToken{}, errors.New("生成刷新令牌失败")
+	}
+	if familyID == "" {
+		familyID = uuid.NewString()
+	}
+	record := models.RefreshToken{
+		UserID:         user.ID,
+		TokenHash:      hashToken(refresh),
+		FamilyID:       familyID,
+		AccessJTI:      claims.ID,
+		OrganizationID: orgID,
+		ExpiresAt:      time.Now().Add(utils.RefreshTokenExpiry()),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return dto.TokenPair{}, models.RefreshToken{}, errors.New("保存刷新令牌失败")
+	}
+	return dto.TokenPair{
+		Token:              token,
+		RefreshToken:       refresh,
+		ExpiresAt:          utils.FormatTime2Str(claims.ExpiresAt.Time),
+		MustChangePassword: user.MustChangePassword,
+	}, record, nil
+}
+
+// revokeAccessToken 将访问令牌加入撤销列表，直到它自然过期
+func revokeAccessToken(tx *gorm.DB, jti string, expiresAt time.Time) error {
+	if jti == "" || !expiresAt.After(time.Now()) {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+// revokeRefreshTokens 撤销查询到的所有仍然有效的刷新令牌，并撤销与它们一同签发的访问令牌
+func revokeRefreshTokens(tx *gorm.DB, query *gorm.DB) error {
+	var records []models.RefreshToken
+	if err := query.Where("revoked_at IS NULL").Find(&records).Error; err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	now := time.Now()
+	ids := make([]uint, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ID)
+		// 访问令牌的签发时间不晚于刷新令牌的创建时间，按最长有效期加入撤销列表
+		if err := revokeAccessToken(tx, record.AccessJTI, record.CreatedAt.Add(utils.AccessTokenExpiry())); err != nil {
+			return err
+		}
+	}
+	return tx.Model(&models.RefreshToken{}).Where("id IN ?", ids).Update("revoked_at", now).Error
+}
+
+// RefreshTokens 使用刷新令牌换取新的访问令牌和刷新令牌，旧的刷新令牌随即失效
+// 已经轮换过的刷新令牌再次被使用说明令牌可能已经泄露，此时撤销整个令牌家族
+func RefreshTokens(refreshToken string) (dto.TokenPair, error) {
+	var pair dto.TokenPair
+	reused := false
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		var record models.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(refreshToken)).First(&record).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		if record.RevokedAt != nil {
+			if record.ReplacedBy != 0 {
+				reused = true
+				return revokeRefreshTokens(tx, tx.Where("family_id = ?", record.FamilyID))
+			}
+			return ErrInvalidRefreshToken
+		}
+		if !record.ExpiresAt.After(time.Now()) {
+			return ErrInvalidRefreshToken
+		}
+		var user models.User
+		if err := tx.First(&user, record.UserID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		var next models.RefreshToken
+		var err error
+		pair, next, err = issueTokens(tx, &user, record.OrganizationID, record.FamilyID, user.LastLoginTime)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&record).Updates(map[string]any{"revoked_at": time.Now(), "replaced_by": next.ID}).Error; err != nil {
+			return err
+		}
+		return revokeAccessToken(tx, record.AccessJTI, record.CreatedAt.Add(utils.AccessTokenExpiry()))
+	})
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+	if reused {
+		return dto.TokenPair{}, ErrRefreshTokenReused
+	}
+	return pair, nil
+}
+
+// Logout 退出当前设备的登录，撤销当前访问令牌及其所在的令牌家族
+func Logout(jti string, expiresAt time.Time) error {
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := revokeAccessToken(tx, jti, expiresAt); err != nil {
+			return err
+		}
+		var record models.RefreshToken
+		if err := tx.Where("access_jti = ?", jti).First(&record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		return revokeRefreshTokens(tx, tx.Where("family_id = ?", record.FamilyID))
+	})
+}
+
+// LogoutAll 退出用户在所有设备上的登录
+func LogoutAll(email string) error {
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return err
+	}
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		return revokeRefreshTokens(tx, tx.Where("user_id = ? AND expires_at > ?", userId, time.Now()))
+	})
+}
+
+// InvalidateUserSessions 在事务中递增用户的令牌版本并撤销其所有刷新令牌，之前签发的令牌全部失效
+func InvalidateUserSessions(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).
+		UpdateColumn("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+		return err
+	}
+	return revokeRefreshTokens(tx, tx.Where("user_id = ?", userID))
+}
+
+// CheckTokenVersion 校验令牌中的版本是否与用户当前的令牌版本一致，返回的用户只包含鉴权需要的字段
+func CheckTokenVersion(email string, version uint) (models.User, error) {
+	var user models.User
+	if err := models.DB.Select("id", "role_id", "token_version", "must_change_password", "totp_enabled").Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.User{}, errors.New("用户不存在")
+		}
+		return models.User{}, err
+	}
+	if user.TokenVersion != version {
+		return models.User{}, errors.New("token 已失效")
+	}
+	return user, nil
+}
+
+// IsTokenRevoked 判断访问令牌是否已被撤销
+func IsTokenRevoked(jti string) (bool, error) {
+	var count int64
+	if err := models.DB.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// PurgeExpiredTokens 清理已经过期的刷新令牌、撤销记录、重置密码令牌、推送凭证和登录记录
+func PurgeExpiredTokens() error {
+	now := time.Now()
+	if err := models.DB.Where("expires_at <= ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+	if err := models.DB.Where("expires_at <= ?", now).Delete(&models.PasswordResetToken{}).Error; err != nil {
+		return err
+	}
+	if err := models.DB.Where("expires_at <= ?", now).Delete(&models.OIDCLoginState{}).Error; err != nil {
+		return err
+	}
+	if err := models.DB.Where("expires_at <= ?", now).Delete(&models.MessageStreamTicket{}).Error; err != nil {
+		return err
+	}
+	if err := models.DB.Where("created_at <= ?", now.Add(-loginAttemptRetention)).Delete(&models.LoginAttempt{}).Error; err != nil {
+		return err
+	}
+	return models.DB.Where("expires_at <= ?", now).Delete(&models.RefreshToken{}).Error
+}
+
+// StartTokenCleaner 启动后台任务，定期清理过期的令牌记录
+func StartTokenCleaner(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := PurgeExpiredTokens(); err != nil {
+				log.Println("清理过期令牌失败:", err)
+			}
+		}
+	}()
+}
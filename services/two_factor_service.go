@@ -0,0 +1,338 @@
+package services
+
+import (
+	"volunteer-system-backend/config"
+	"volunteer-system-backend/dto"
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/utils"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidTwoFactorCode 两步验证码错误
+	ErrInvalidTwoFactorCode = errors.New("验证码错误或已被使用")
+	// ErrInvalidPreAuthToken 预认证令牌无效
+	ErrInvalidPreAuthToken = errors.New("登录已过期，请重新输入邮箱和密码")
+)
+
+const (
+	totpIssuer         = "志愿者系统"         // 身份验证器中显示的发行方
+	preAuthTokenExpiry = 5 * time.Minute // 预认证令牌的有效期
+	recoveryCodeCount  = 10              // 每次生成的恢复码个数
+)
+
+// isAdminUser 判断用户的全局角色或任一组织角色是否可以管理活动
+func isAdminUser(user models.User) (bool, error) {
+	roleIds, err := rolesWithPermission(models.PermTaskManage)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range roleIds {
+		if id == user.RoleID {
+			return true, nil
+		}
+	}
+	var count int64
+	if err := models.DB.Model(&models.OrganizationMember{}).
+		Where("user_id = ? AND role_id IN ?", user.ID, roleIds).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// twoFactorRequired 判断配置是否要求该用户开启两步验证
+func twoFactorRequired(user models.User) (bool, error) {
+	if !config.ProjectConfig.Account.RequireAdmin2FA {
+		return false, nil
+	}
+	return isAdminUser(user)
+}
+
+// TwoFactorSetupRequired 判断用户是否需要先开启两步验证才能使用其他接口
+func TwoFactorSetupRequired(user models.User) (bool, error) {
+	if user.TOTPEnabled {
+		return false, nil
+	}
+	return twoFactorRequired(user)
+}
+
+// signPreAuthToken 生成预认证令牌，格式为 用户ID.过期时间.签名，签名中包含令牌版本，修改密码后旧的预认证令牌失效
+func signPreAuthToken(user *models.User) string {
+	payload := fmt.Sprintf("%d.%d", user.ID, time.Now().Add(preAuthTokenExpiry).Unix())
+	return payload + "." + utils.SignHMAC(fmt.Sprintf("2fa.%s.%d", payload, user.TokenVersion))
+}
+
+// parsePreAuthToken 校验预认证令牌并返回对应的用户
+func parsePreAuthToken(token string) (*models.User, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidPreAuthToken
+	}
+	userId, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidPreAuthToken
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return nil, ErrInvalidPreAuthToken
+	}
+	var user models.User
+	if err := models.DB.First(&user, userId).Error; err != nil {
+		return nil, ErrInvalidPreAuthToken
+	}
+	expected := utils.SignHMAC(fmt.Sprintf("2fa.%s.%s.%d", parts[0], parts[1], user.TokenVersion))
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) || !user.TOTPEnabled {
+		return nil, ErrInvalidPreAuthToken
+	}
+	return &user, nil
+}
+
+// normalizeRecoveryCode 忽略恢复码中的空白、连字符和大小写
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// generateRecoveryCodes 在事务中生成新的恢复码，之前的恢复码全部作废，明文只在此时返回一次
+func generateRecoveryCodes(tx *gorm.DB, userId uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userId).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, errors.New("无法生成恢复码")
+		}
+		code := hex.EncodeToString(buf)
+		codes[i] = code[:5] + "-" + code[5:]
+		records[i] = models.RecoveryCode{UserID: userId, CodeHash: hashToken(code)}
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, errors.New("无法保存恢复码")
+	}
+	return codes, nil
+}
+
+// verifySecondFactor 校验身份验证器中的验证码或恢复码，验证码和恢复码都只能使用一次
+// TOTP 密钥无法解密时仍然可以使用恢复码
+func verifySecondFactor(user *models.User, code string) (bool, error) {
+	secret, err := utils.DecryptTOTPSecret(user.TOTPSecret)
+	if err != nil {
+		log.Printf("无法解密用户 %d 的两步验证密钥: %v", user.ID, err)
+	} else if step, ok := utils.ValidateTOTP(secret, code, time.Now()); ok {
+		result := models.DB.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		return result.RowsAffected > 0, nil
+	}
+	result := models.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now().Local())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// LoginTwoFactor 使用预认证令牌和两步验证码完成登录，验证码错误与密码错误一样计入连续登录失败次数
+func LoginTwoFactor(preAuthToken, code, ip string) (dto.TokenPair, error) {
+	user, err := parsePreAuthToken(preAuthToken)
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+	if err := checkLoginAllowed(user, user.Email, ip); err != nil {
+		return dto.TokenPair{}, err
+	}
+	ok, err := verifySecondFactor(user, code)
+	if err != nil {
+		return dto.TokenPair{}, err
+	}
+	if !ok {
+		if err := recordLoginFailure(user, user.Email, ip); err != nil {
+			return dto.TokenPair{}, err
+		}
+		return dto.TokenPair{}, ErrInvalidTwoFactorCode
+	}
+	return completeLogin(user, ip)
+}
+
+// SetupTwoFactor 为用户生成待确认的 TOTP 密钥，使用该密钥生成的验证码确认后才会开启两步验证
+func SetupTwoFactor(email string) (dto.TwoFactorSetupInfo, error) {
+	user, err := GetUserProfile(email)
+	if err != nil {
+		return dto.TwoFactorSetupInfo{}, err
+	}
+	if user.TOTPEnabled {
+		return dto.TwoFactorSetupInfo{}, errors.New("两步验证已经开启")
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return dto.TwoFactorSetupInfo{}, errors.New("无法生成密钥")
+	}
+	encrypted, err := utils.EncryptTOTPSecret(secret)
+	if err != nil {
+		if errors.Is(err, utils.ErrTOTPKeyNotConfigured) {
+			return dto.TwoFactorSetupInfo{}, err
+		}
+		return dto.TwoFactorSetupInfo{}, errors.New("无法加密密钥")
+	}
+	if err := models.DB.Model(user).Update("totp_secret", encrypted).Error; err != nil {
+		return dto.TwoFactorSetupInfo{}, errors.New("无法保存密钥")
+	}
+	return dto.TwoFactorSetupInfo{
+		Secret:     secret,
+		OtpauthURI: utils.TOTPURI(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// EnableTwoFactor 使用身份验证器中的验证码确认密钥并开启两步验证，返回恢复码
+func EnableTwoFactor(email, code string) ([]string, error) {
+	user, err := GetUserProfile(email)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("两步验证已经开启")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("请先获取两步验证密钥")
+	}
+	secret, err := utils.DecryptTOTPSecret(user.TOTPSecret)
+	if err != nil {
+		return nil, errors.New("两步验证密钥无效，请重新获取")
+	}
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	var codes []string
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]any{"totp_enabled": true, "totp_last_step": step}).Error; err != nil {
+			return errors.New("无法开启两步验证")
+		}
+		codes, err = generateRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor 校验验证码或恢复码后关闭两步验证，配置要求开启两步验证的账户不能关闭
+func DisableTwoFactor(email, code string) error {
+	user, err := GetUserProfile(email)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return errors.New("两步验证尚未开启")
+	}
+	required, err := twoFactorRequired(*user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return errors.New("管理员账户必须开启两步验证")
+	}
+	ok, err := verifySecondFactor(user, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	return clearTwoFactor(models.DB, user.ID)
+}
+
+// RegenerateRecoveryCodes 校验验证码后重新生成恢复码，之前的恢复码全部作废
+func RegenerateRecoveryCodes(email, code string) ([]string, error) {
+	user, err := GetUserProfile(email)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, errors.New("两步验证尚未开启")
+	}
+	ok, err := verifySecondFactor(user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	var codes []string
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		codes, err = generateRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// EncryptTOTPSecrets 加密升级前以明文保存的 TOTP 密钥，启动时调用
+// 数据库中存在 TOTP 密钥但没有配置加密密钥时返回错误，避免以明文继续保存或使已开启两步验证的用户无法登录
+func EncryptTOTPSecrets() error {
+	var users []models.User
+	if err := models.DB.Select("id", "totp_secret").Where("totp_secret <> ''").Find(&users).Error; err != nil {
+		return err
+	}
+	if len(users) > 0 && !utils.TOTPKeyConfigured() {
+		return errors.New("数据库中保存了两步验证密钥，请配置 totpKeyFile")
+	}
+	for _, user := range users {
+		if utils.IsEncryptedTOTPSecret(user.TOTPSecret) {
+			continue
+		}
+		encrypted, err := utils.EncryptTOTPSecret(user.TOTPSecret)
+		if err != nil {
+			return err
+		}
+		// 只替换仍然是该明文的密钥，避免覆盖同时重新设置的密钥
+		if err := models.DB.Model(&models.User{}).Where("id = ? AND totp_secret = ?", user.ID, user.TOTPSecret).
+			Update("totp_secret", encrypted).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearTwoFactor 清除用户的两步验证密钥和恢复码
+func clearTwoFactor(tx *gorm.DB, userId uint) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userId).
+		Updates(map[string]any{"totp_secret": "", "totp_enabled": false, "totp_last_step": 0}).Error; err != nil {
+		return errors.New("无法关闭两步验证")
+	}
+	return tx.Where("user_id = ?", userId).Delete(&models.RecoveryCode{}).Error
+}
+
+// ResetTwoFactor 管理员重置用户的两步验证，用于用户丢失身份验证器和恢复码的情况，重置后该用户之前的登录全部失效
+func ResetTwoFactor(email string) error {
+	userId, err := GetUserIDByEmail(email)
+	if err != nil {
+		return err
+	}
+	return models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := clearTwoFactor(tx, userId); err != nil {
+			return err
+		}
+		return InvalidateUserSessions(tx, userId)
+	})
+}
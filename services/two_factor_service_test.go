@@ -0,0 +1,74 @@
+package services
+
+import (
+	"volunteer-system-backend/models"
+	"volunteer-system-backend/utils"
+	"testing"
+	"time"
+)
+
+func TestTwoFactorSecretIsEncryptedAtRest(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "volunteer@example.com")
+
+	info, err := SetupTwoFactor(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved models.User
+	if err := models.DB.First(&saved, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.TOTPSecret == info.Secret || !utils.IsEncryptedTOTPSecret(saved.TOTPSecret) {
+		t.Fatalf("数据库中不应保存明文密钥: %q", saved.TOTPSecret)
+	}
+
+	code, err := utils.TOTPCode(info.Secret, time.Now().Unix()/30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EnableTwoFactor(user.Email, code); err != nil {
+		t.Fatalf("使用身份验证器的验证码应能开启两步验证: %v", err)
+	}
+	next, err := utils.TOTPCode(info.Secret, time.Now().Unix()/30+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := verifySecondFactor(&saved, next); err != nil || !ok {
+		t.Fatalf("加密保存的密钥应能校验验证码: %v, %v", ok, err)
+	}
+}
+
+func TestEncryptTOTPSecretsMigratesPlaintext(t *testing.T) {
+	setupTestDB(t)
+	user := createTestUser(t, "volunteer@example.com")
+	plaintext, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.DB.Model(user).Updates(map[string]any{"totp_secret": plaintext, "totp_enabled": true}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EncryptTOTPSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	var saved models.User
+	if err := models.DB.First(&saved, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if decrypted, err := utils.DecryptTOTPSecret(saved.TOTPSecret); err != nil || decrypted != plaintext {
+		t.Fatalf("明文密钥应被加密保存，解密结果为 %q（%v）", decrypted, err)
+	}
+	// 再次执行时不应重复加密
+	if err := EncryptTOTPSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	var again models.User
+	if err := models.DB.First(&again, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if again.TOTPSecret != saved.TOTPSecret {
+		t.Fatal("已经加密的密钥不应被再次加密")
+	}
+}
@@ -1,28 +1,72 @@
 package services
 
 import (
+	"volunteer-system-backend/dto"
 	"volunteer-system-backend/models"
 	"volunteer-system-backend/utils"
 	"errors"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 	"mime/multipart"
+	"strings"
 	"time"
 )
 
-// RegisterUser 注册用户服务
-func RegisterUser(email, nickname, gender, phone, password string) error {
+// PasswordPolicyError 密码不满足密码策略，Violations 列出所有未满足的规则
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "密码不符合要求：" + strings.Join(e.Violations, "；")
+}
+
+// validatePassword 按密码策略校验密码，密码不能与用户的邮箱或手机号相同
+func validatePassword(password, email, phone string) error {
+	if violations := utils.CheckPassword(password, email, phone); len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// UserService 用户注册和修改密码，相关的通知通过通知服务发送
+type UserService struct {
+	notifications NotificationService
+}
+
+// NewUserService 创建用户服务
+func NewUserService(notifications NotificationService) *UserService {
+	return &UserService{notifications: notifications}
+}
+
+// RegisterUser 注册用户服务，向注册邮箱发送验证邮件，提供组织邀请码时在同一个事务中以志愿者身份加入该组织并通知用户
+func (s *UserService) RegisterUser(email, nickname, gender, phone, password, orgCode string) error {
 	// 检查用户名是否已存在
 	var existingUser models.User
 	if err := models.DB.Where("email = ?", email).First(&existingUser).Error; err == nil {
 		return errors.New("该用户已经存在")
 	}
+	if err := validatePassword(password, email, phone); err != nil {
+		return err
+	}
+	// 邀请码无效时不创建账户，用户可以修改邀请码后重新注册
+	var org *models.Organization
+	if orgCode != "" {
+		org = &models.Organization{}
+		if err := models.DB.Where("code = ?", strings.TrimSpace(orgCode)).First(org).Error; err != nil {
+			return errors.New("邀请码无效")
+		}
+	}
 
 	// 加密密码
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return errors.New("无法对密码进行哈希处理")
 	}
+	role, err := models.GetRoleByName(models.RoleVolunteer)
+	if err != nil {
+		return err
+	}
 	loc, _ := time.LoadLocation("Asia/Shanghai")
 	// 创建新用户
 	user := models.User{
@@ -31,42 +75,107 @@ func RegisterUser(email, nickname, gender, phone, password string) error {
 		Password:      string(hashedPassword),
 		CreatedAt:     time.Now().In(loc),
 		Duration:      0,
-		Admin:         false,
+		RoleID:        role.ID,
 		LastLoginTime: time.Now().In(loc),
 		Gender:        gender,
 		Phone:         phone,
 	}
-	if err := models.DB.Create(&user).Error; err != nil {
-		return errors.New("无法创建用户")
+	// 新用户在验证邮箱之前不能登录
+	push := func() {}
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return errors.New("无法创建用户")
+		}
+		if org != nil {
+			if err := addOrganizationMember(tx, org.ID, user.ID, role.ID); err != nil {
+				return err
+			}
+			var err error
+			if _, push, err = s.notifications.EmitTx(tx, orgJoinedEvent(*org, user.ID, role)); err != nil {
+				return errors.New("创建消息失败")
+			}
+		}
+		if err := sendVerificationEmail(tx, &user); err != nil {
+			return errors.New("无法发送验证邮件")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	push()
+	verificationEmailLimiter.Allow(strings.ToLower(user.Email))
 	return nil
 }
 
-// LoginUser 用户登录服务
-func LoginUser(email, password string) (string, error) {
+// LoginUser 用户登录服务，签发访问令牌和刷新令牌，并返回上次登录成功后的失败记录
+// 同一IP或同一账户连续登录失败时需要等待的时间逐次增加，达到次数后账户被临时锁定
+// 开启了两步验证的用户只返回预认证令牌，需要再调用 LoginTwoFactor 提交验证码
+func LoginUser(email, password, ip string) (dto.TokenPair, error) {
 	var user models.User
 
-	// 查找用户
+	// 查找用户，邮箱未注册时与密码错误返回相同的结果
 	if err := models.DB.Where("email = ?", email).First(&user).Error; err != nil {
-		return "", errors.New("邮箱或密码无效")
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return dto.TokenPair{}, err
+		}
+		if err := checkLoginAllowed(nil, email, ip); err != nil {
+			return dto.TokenPair{}, err
+		}
+		// 同样进行一次密码哈希比较，避免通过响应时间判断邮箱是否注册
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		if err := recordLoginFailure(nil, email, ip); err != nil {
+			return dto.TokenPair{}, err
+		}
+		return dto.TokenPair{}, errors.New("邮箱或密码无效")
+	}
+	if err := checkLoginAllowed(&user, email, ip); err != nil {
+		return dto.TokenPair{}, err
 	}
 
 	// 验证密码
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return "", errors.New("邮箱或密码无效")
+		if err := recordLoginFailure(&user, email, ip); err != nil {
+			return dto.TokenPair{}, err
+		}
+		return dto.TokenPair{}, errors.New("邮箱或密码无效")
+	}
+	if user.EmailVerifiedAt == nil {
+		return dto.TokenPair{}, ErrEmailNotVerified
 	}
+	// 开启两步验证时先返回预认证令牌，提交验证码后才签发访问令牌
+	if user.TOTPEnabled {
+		return dto.TokenPair{TwoFactorRequired: true, PreAuthToken: signPreAuthToken(&user)}, nil
+	}
+	return completeLogin(&user, ip)
+}
 
+// completeLogin 通过全部登录验证后签发令牌，记录本次登录并返回上次登录成功后的失败记录
+func completeLogin(user *models.User, ip string) (dto.TokenPair, error) {
 	localTime := time.Now().Local()
-	// 生成 JWT
-	token, err := utils.GenerateJWT(user.Email, user.Nickname, localTime)
+	// 默认进入最早加入的组织
+	orgID := GetDefaultOrganizationID(user.ID)
+	var pair dto.TokenPair
+	err := models.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		// 生成 JWT 和刷新令牌
+		pair, _, err = issueTokens(tx, user, orgID, "", localTime)
+		if err != nil {
+			return err
+		}
+		pair.FailedLogins, err = recordLoginSuccess(tx, user, ip, localTime)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(user).Update("last_login_time", localTime).Error; err != nil {
+			return errors.New("无法更新最后登录时间")
+		}
+		return nil
+	})
 	if err != nil {
-		return "", errors.New("生成 token 失败")
+		return dto.TokenPair{}, err
 	}
-	user.LastLoginTime = localTime
-	if err := models.DB.Save(&user).Error; err != nil {
-		return "", errors.New("无法更新最后登录时间")
-	}
-	return token, nil
+	return pair, nil
 }
 
 // GetUserIDByEmail 通过 email 查询用户 ID
@@ -91,37 +200,75 @@ func GetUserProfile(email any) (*models.User, error) {
 	return &user, nil
 }
 
-// ChangePassword 修改密码服务
-func ChangePassword(email, oldPassword, newPassword string) error {
+// ChangePassword 修改密码服务，修改后通知用户，不是本人操作时可以及时找回密码
+func (s *UserService) ChangePassword(email string, orgID uint, oldPassword, newPassword string) (dto.TokenPair, error) {
 	var user models.User
 	if err := models.DB.Where("email = ?", email).First(&user).Error; err != nil {
-		return errors.New("用户不存在")
+		return dto.TokenPair{}, errors.New("用户不存在")
 	}
 
 	// 验证旧密码
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(oldPassword)); err != nil {
-		return errors.New("旧密码错误")
+		return dto.TokenPair{}, errors.New("旧密码错误")
+	}
+	if err := validatePassword(newPassword, user.Email, user.Phone); err != nil {
+		return dto.TokenPair{}, err
 	}
 
 	// 加密新密码
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 	if err != nil {
-		return errors.New("无法对密码进行哈希处理")
+		return dto.TokenPair{}, errors.New("无法对密码进行哈希处理")
 	}
 
-	// 更新密码
-	user.Password = string(hashedPassword)
-	if err := models.DB.Save(&user).Error; err != nil {
-		return errors.New("无法更新密码")
+	// 更新密码，之前的登录全部失效，并为当前设备签发新的令牌
+	var pair dto.TokenPair
+	push := func() {}
+	err = models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]any{"password": string(hashedPassword), "must_change_password": false}).Error; err != nil {
+			return errors.New("无法更新密码")
+		}
+		if err := InvalidateUserSessions(tx, user.ID); err != nil {
+			return err
+		}
+		// 密码修改通知不属于任何组织，在所有组织中都可以看到
+		var err error
+		if _, push, err = s.notifications.EmitTx(tx, Event{
+			Type:       models.EventPasswordChanged,
+			Recipients: []uint{user.ID},
+			Data:       MessageData{"Time": utils.FormatTime2Str(time.Now().Local())},
+		}); err != nil {
+			return errors.New("创建消息失败")
+		}
+		user.TokenVersion++
+		user.MustChangePassword = false
+		pair, _, err = issueTokens(tx, &user, orgID, "", user.LastLoginTime)
+		return err
+	})
+	if err != nil {
+		return dto.TokenPair{}, err
 	}
-
-	return nil
+	push()
+	return pair, nil
 }
 
-// GetVolunteerCount 统计志愿者用户个数服务
-func GetVolunteerCount() ([]map[string]interface{}, error) {
+// GetVolunteerCount 统计组织内志愿者用户个数服务
+func GetVolunteerCount(orgID uint) ([]map[string]interface{}, error) {
+	if orgID == 0 {
+		return nil, errors.New("请先进入组织")
+	}
+	// 服务时长只统计本组织活动中已确认的时长，不使用包含其他组织时长的 users.duration
+	duration := models.DB.Table("task_participants").
+		Select("COALESCE(SUM(task_participants.confirmed_minutes), 0)").
+		Joins("JOIN tasks ON tasks.id = task_participants.task_id").
+		Where("task_participants.email = users.email AND task_participants.hours_confirmed = ? AND task_participants.deleted_at IS NULL", true).
+		Where("tasks.organization_id = ?", orgID)
 	var volunteers []map[string]interface{}
-	if err := models.DB.Table("users").Select("email, id, nickname, gender, phone, avatar, duration, last_login_time").Find(&volunteers).Error; err != nil {
+	if err := models.DB.Table("users").
+		Select("users.email, users.id, users.nickname, users.gender, users.phone, users.avatar, (?) AS duration, users.last_login_time", duration).
+		Joins("JOIN organization_members ON organization_members.user_id = users.id").
+		Where("organization_members.organization_id = ? AND users.email_verified_at IS NOT NULL", orgID).
+		Find(&volunteers).Error; err != nil {
 		return nil, err
 	}
 	// 判断用户状态
@@ -152,43 +299,55 @@ func UploadAvatar(email string, file multipart.File, fileHeader *multipart.FileH
 	if err != nil {
 		return err
 	}
-	// 更新用户的头像字段
-	user.Avatar = imageURL
-	if err := models.DB.Save(&user).Error; err != nil {
+	// 只更新头像字段，避免覆盖同时发生的其他修改（如令牌版本、密码）
+	if err := models.DB.Model(&user).Update("avatar", imageURL).Error; err != nil {
 		return errors.New("无法更新用户头像")
 	}
 	return nil
 }
 
 // UpdateUserInfo 更新用户信息
-func UpdateUserInfo(email any, nickname, gender, phone string) error {
+func UpdateUserInfo(email any, nickname, gender, phone, skills string) error {
 	var user models.User
 	if err := models.DB.Where("email = ?", email).First(&user).Error; err != nil {
 		return errors.New("用户不存在")
 	}
 
-	// 只在字段值发生变化时进行更新
-	updated := false
-
+	// 只更新发生变化的字段，避免用读取到的旧值覆盖同时发生的其他修改
+	updates := make(map[string]any)
 	if nickname != "" && nickname != user.Nickname {
-		user.Nickname = nickname
-		updated = true
+		updates["nickname"] = nickname
 	}
 	if gender != "" && gender != user.Gender {
-		user.Gender = gender
-		updated = true
+		updates["gender"] = gender
 	}
 	if phone != "" && phone != user.Phone {
-		user.Phone = phone
-		updated = true
+		updates["phone"] = phone
 	}
-
-	// 如果有字段更新，则保存
-	if updated {
-		if err := models.DB.Save(&user).Error; err != nil {
+	if skills != "" {
+		if skills = normalizeSkills(skills); skills != user.Skills {
+			updates["skills"] = skills
+		}
+	}
+	if len(updates) > 0 {
+		if err := models.DB.Model(&user).Updates(updates).Error; err != nil {
 			return errors.New("无法更新用户信息")
 		}
 	}
 
 	return nil
 }
+
+// normalizeSkills 规范化技能标签：去除空白和重复标签，并支持中文逗号分隔
+func normalizeSkills(skills string) string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, skill := range strings.Split(strings.ReplaceAll(skills, "，", ","), ",") {
+		skill = strings.TrimSpace(skill)
+		if skill != "" && !seen[skill] {
+			seen[skill] = true
+			result = append(result, skill)
+		}
+	}
+	return strings.Join(result, ",")
+}
@@ -0,0 +1,21 @@
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+)
+
+//go:embed email/*.html
+var emailFS embed.FS
+
+var emailTemplates = template.Must(template.ParseFS(emailFS, "email/*.html"))
+
+// RenderEmail 使用指定的邮件模板渲染 HTML 正文
+func RenderEmail(name string, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := emailTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
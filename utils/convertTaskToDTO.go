@@ -14,6 +14,7 @@ func ConvertTaskToDTO(task models.Task) dto.TaskInfo {
 		StartTime: FormatTime2Str(task.StartTime),
 		EndTime:   FormatTime2Str(task.EndTime),
 		Location:  task.Location,
+		Category:  task.Category,
 		Limit:     task.Limit,
 		Joined:    task.Joined,
 	}
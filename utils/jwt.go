@@ -2,62 +2,132 @@ package utils
 
 import (
 	"volunteer-system-backend/config"
+	"crypto/hkdf"
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"sync"
 	"time"
 )
 
+// 由 jwt_key 派生密钥时使用的用途标签，不同用途的密钥互不相同，一种签名泄露或被滥用时不影响另一种
+const (
+	accessTokenKeyLabel = "volunteer-system/access-token/hs256"
+	hmacKeyLabel        = "volunteer-system/signed-link/hmac-sha256"
+)
+
 var (
-	jwtKey []byte
-	once   sync.Once
+	accessTokenKey []byte // 未配置非对称密钥时签发访问令牌的 HS256 密钥
+	hmacKey        []byte // SignHMAC 使用的密钥
+	once           sync.Once
 )
 
+// deriveKey 使用 HKDF-SHA256 从 jwt_key 派生指定用途的 32 字节密钥
+func deriveKey(secret, label string) []byte {
+	key, err := hkdf.Key(sha256.New, []byte(secret), nil, label, 32)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
 // 初始化 JWT 密钥
 func initJwtKey() {
 	once.Do(func() {
-		jwtKey = []byte(GenerateMD5(config.ProjectConfig.Volunteer.TwtKey))
+		secret := config.ProjectConfig.Volunteer.TwtKey
+		accessTokenKey = deriveKey(secret, accessTokenKeyLabel)
+		hmacKey = deriveKey(secret, hmacKeyLabel)
 	})
 }
 
 // Claims 结构体
 type Claims struct {
-	Email     string    `json:"email"`
-	Nickname  string    `json:"nickname"`
-	LoginTime time.Time `json:"loginTime"`
+	Email          string    `json:"email"`
+	Nickname       string    `json:"nickname"`
+	OrganizationID uint      `json:"orgId"` // 当前所在组织
+	TokenVersion   uint      `json:"tv"`    // 签发时用户的令牌版本
+	LoginTime      time.Time `json:"loginTime"`
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT 生成 JWT
-func GenerateJWT(email, nickname string, loginTime time.Time) (string, error) {
-	initJwtKey() // 确保 jwtKey 已初始化
+// AccessTokenExpiry 访问令牌的有效期，默认15分钟
+func AccessTokenExpiry() time.Duration {
+	if config.ProjectConfig.Volunteer.AccessExpiry > 0 {
+		return time.Duration(config.ProjectConfig.Volunteer.AccessExpiry) * time.Minute
+	}
+	return 15 * time.Minute
+}
 
-	// 设置过期时间
-	var expMinutes int64 = 1 // 默认1小时
-	if config.ProjectConfig.Volunteer.JwtExpiry > 0 {
-		expMinutes = int64(config.ProjectConfig.Volunteer.JwtExpiry)
+// RefreshTokenExpiry 刷新令牌的有效期，默认7天
+func RefreshTokenExpiry() time.Duration {
+	if config.ProjectConfig.Volunteer.RefreshExpiry > 0 {
+		return time.Duration(config.ProjectConfig.Volunteer.RefreshExpiry) * time.Hour
 	}
+	return 7 * 24 * time.Hour
+}
+
+// GenerateJWT 生成短期有效的访问令牌，返回的 Claims 中包含令牌的 jti 和过期时间
+func GenerateJWT(email, nickname string, organizationID uint, tokenVersion uint, loginTime time.Time) (string, *Claims, error) {
+	initJwtKey() // 确保 jwtKey 已初始化
 
 	// 计算过期时间
-	expirationTime := time.Now().Add(time.Duration(expMinutes) * time.Hour)
+	now := time.Now()
+	expirationTime := now.Add(AccessTokenExpiry())
 
 	claims := &Claims{
-		Email:     email,
-		Nickname:  nickname,
-		LoginTime: loginTime,
+		Email:          email,
+		Nickname:       nickname,
+		OrganizationID: organizationID,
+		TokenVersion:   tokenVersion,
+		LoginTime:      loginTime,
 		RegisteredClaims: jwt.RegisteredClaims{
 			// 添加更多标准字段来确保token的唯一性和时效性
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),           // 添加签发时间
-			NotBefore: jwt.NewNumericDate(time.Now()),           // 添加生效时间
-			ID:        GenerateMD5(email + time.Now().String()), // 添加唯一标识符
+			IssuedAt:  jwt.NewNumericDate(now), // 添加签发时间
+			NotBefore: jwt.NewNumericDate(now), // 添加生效时间
+			ID:        uuid.NewString(),        // 唯一标识符，撤销令牌时使用
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	// 配置了签名私钥时使用非对称签名，kid 标识签名使用的密钥
+	var tokenStr string
+	var err error
+	if activeSigningKey != nil {
+		token := jwt.NewWithClaims(activeSigningKey.method, claims)
+		token.Header["kid"] = activeSigningKey.kid
+		tokenStr, err = token.SignedString(activeSigningKey.key)
+	} else {
+		tokenStr, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(accessTokenKey)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return tokenStr, claims, nil
+}
+
+// jwtKeyFunc 按签名算法和 kid 选择校验密钥，配置了非对称密钥后不再接受 HS256 签名的令牌
+func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	if len(verificationKeys) == 0 {
+		// 验证签名算法
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("意外的签名方法")
+		}
+		return accessTokenKey, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := verificationKeys[kid]
+	if !ok {
+		return nil, errors.New("未知的签名密钥")
+	}
+	// 签名算法必须与密钥类型一致
+	if token.Method.Alg() != signingMethod(key).Alg() {
+		return nil, errors.New("意外的签名方法")
+	}
+	return key, nil
 }
 
 // ParseJWT 验证 JWT
@@ -65,13 +135,7 @@ func ParseJWT(tokenStr string) (*Claims, error) {
 	initJwtKey() // 确保 jwtKey 已初始化
 
 	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		// 验证签名算法
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("意外的签名方法")
-		}
-		return jwtKey, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenStr, claims, jwtKeyFunc)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -95,6 +159,14 @@ func ParseJWT(tokenStr string) (*Claims, error) {
 	return claims, nil
 }
 
+// SignHMAC 使用由 jwt_key 派生的专用密钥计算数据的 HMAC-SHA256 签名，用于邮件中的签名链接和预认证令牌
+func SignHMAC(data string) string {
+	initJwtKey() // 确保 hmacKey 已初始化
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write([]byte(data))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 // GenerateMD5 生成字符串的 MD5 哈希
 func GenerateMD5(input string) string {
 	hash := md5.Sum([]byte(input))
@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"volunteer-system-backend/config"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+)
+
+const (
+	minRSAKeyBits      = 2048 // RSA 密钥的最短长度
+	minJWTSecretLength = 16   // jwt_key 的最短长度
+)
+
+// signingKey 当前用于签发访问令牌的私钥
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	key    crypto.Signer
+}
+
+var (
+	// activeSigningKey 为空时使用 jwt_key 以 HS256 签名
+	activeSigningKey *signingKey
+	// verificationKeys 按 kid 索引的所有可用于校验访问令牌的公钥，包括当前签名私钥对应的公钥
+	verificationKeys map[string]crypto.PublicKey
+)
+
+// JWK 公开的 JSON Web Key
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet /.well-known/jwks.json 返回的公钥集合
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// readPEM 读取 PEM 文件中的第一个块
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s 不是 PEM 格式", path)
+	}
+	return block, nil
+}
+
+// checkKeyType 只接受 2048 位以上的 RSA 密钥和 Ed25519 密钥
+func checkKeyType(key crypto.PublicKey) error {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return fmt.Errorf("RSA 密钥长度不能少于 %d 位", minRSAKeyBits)
+		}
+		return nil
+	case ed25519.PublicKey:
+		return nil
+	}
+	return errors.New("只支持 RSA 和 Ed25519 密钥")
+}
+
+// loadPrivateKey 读取 PKCS#8 或 PKCS#1 格式的私钥
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	var key any
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("无法解析私钥 %s：%v", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s 不是可用于签名的私钥", path)
+	}
+	if err := checkKeyType(signer.Public()); err != nil {
+		return nil, fmt.Errorf("%s：%v", path, err)
+	}
+	return signer, nil
+}
+
+// loadPublicKey 读取 PKIX 或 PKCS#1 格式的公钥，也可以直接使用私钥文件
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	var key any
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		signer, err := loadPrivateKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return signer.Public(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("无法解析公钥 %s：%v", path, err)
+	}
+	if err := checkKeyType(key); err != nil {
+		return nil, fmt.Errorf("%s：%v", path, err)
+	}
+	return key, nil
+}
+
+// publicJWK 将公钥转换为 JWK，kid 使用 RFC 7638 的 JWK 指纹，同一个密钥在所有服务中的 kid 相同
+func publicJWK(key crypto.PublicKey) JWK {
+	encode := base64.RawURLEncoding.EncodeToString
+	var jwk JWK
+	var canonical string
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		jwk = JWK{Kty: "RSA", Alg: "RS256", N: encode(k.N.Bytes()), E: encode(big.NewInt(int64(k.E)).Bytes())}
+		canonical = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, jwk.E, jwk.N)
+	case ed25519.PublicKey:
+		jwk = JWK{Kty: "OKP", Alg: "EdDSA", Crv: "Ed25519", X: encode(k)}
+		canonical = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, jwk.X)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	jwk.Kid = encode(sum[:])
+	jwk.Use = "sig"
+	return jwk
+}
+
+// signingMethod 根据密钥类型选择签名算法
+func signingMethod(key crypto.PublicKey) jwt.SigningMethod {
+	if _, ok := key.(ed25519.PublicKey); ok {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// LoadJWTKeys 加载配置中的签名私钥和轮换期间仍然接受的公钥，启动时调用
+// 轮换密钥时先将新公钥加入所有服务的 verify_keys，再将 signing_key 切换为新私钥并把旧公钥留在 verify_keys 中，
+// 旧密钥签发的访问令牌全部过期后即可移除旧公钥
+func LoadJWTKeys() error {
+	cfg := config.ProjectConfig.Volunteer
+	keys := make(map[string]crypto.PublicKey)
+	var active *signingKey
+	if cfg.SigningKey != "" {
+		signer, err := loadPrivateKey(cfg.SigningKey)
+		if err != nil {
+			return err
+		}
+		kid := publicJWK(signer.Public()).Kid
+		active = &signingKey{kid: kid, method: signingMethod(signer.Public()), key: signer}
+		keys[kid] = signer.Public()
+	}
+	for _, path := range cfg.VerifyKeys {
+		key, err := loadPublicKey(path)
+		if err != nil {
+			return err
+		}
+		keys[publicJWK(key).Kid] = key
+	}
+	if active == nil && len(keys) > 0 {
+		return errors.New("配置了 verify_keys 时必须同时配置 signing_key")
+	}
+	// 签名链接和预认证令牌总是使用由 jwt_key 派生的密钥
+	// 配置了 signing_key 时访问令牌不依赖 jwt_key，旧部署中较短的 jwt_key 只输出警告，避免升级后无法启动
+	switch {
+	case cfg.TwtKey == "":
+		return errors.New("jwt_key 不能为空")
+	case len(cfg.TwtKey) < minJWTSecretLength && active == nil:
+		return fmt.Errorf("jwt_key 不能少于 %d 个字符", minJWTSecretLength)
+	case len(cfg.TwtKey) < minJWTSecretLength:
+		log.Printf("警告：jwt_key 少于 %d 个字符，签名链接和预认证令牌容易被暴力破解，建议更换为更长的随机字符串", minJWTSecretLength)
+	}
+	if active == nil {
+		log.Println("警告：未配置 signing_key，访问令牌使用由 jwt_key 派生的密钥以 HS256 签名，其他服务无法通过 JWKS 校验访问令牌，建议配置 RSA 或 Ed25519 私钥")
+	}
+	activeSigningKey = active
+	verificationKeys = keys
+	return nil
+}
+
+// PublicJWKS 返回所有可用于校验访问令牌的公钥，未配置非对称密钥时为空集合
+func PublicJWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	if activeSigningKey != nil {
+		set.Keys = append(set.Keys, publicJWK(activeSigningKey.key.Public()))
+	}
+	var kids []string
+	for kid := range verificationKeys {
+		if activeSigningKey == nil || kid != activeSigningKey.kid {
+			kids = append(kids, kid)
+		}
+	}
+	sort.Strings(kids)
+	for _, kid := range kids {
+		set.Keys = append(set.Keys, publicJWK(verificationKeys[kid]))
+	}
+	return set
+}
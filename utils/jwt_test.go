@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"volunteer-system-backend/config"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDerivedKeysAreSeparated(t *testing.T) {
+	saved := config.ProjectConfig
+	defer func() { config.ProjectConfig = saved }()
+	config.ProjectConfig = &config.Config{}
+	config.ProjectConfig.Volunteer.TwtKey = "test-jwt-key-0123456789"
+	initJwtKey()
+
+	if hmac.Equal(accessTokenKey, hmacKey) {
+		t.Fatal("访问令牌和签名链接应使用不同的密钥")
+	}
+	for _, key := range [][]byte{accessTokenKey, hmacKey} {
+		if string(key) == GenerateMD5(config.ProjectConfig.Volunteer.TwtKey) || string(key) == config.ProjectConfig.Volunteer.TwtKey {
+			t.Fatal("密钥应由 jwt_key 通过 HKDF 派生")
+		}
+	}
+
+	// 使用签名链接的密钥签发的访问令牌不能通过校验
+	claims := &Claims{Email: "user@example.com", RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}}
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(hmacKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseJWT(forged); err == nil {
+		t.Fatal("使用其他用途的密钥签名的访问令牌不应通过校验")
+	}
+	token, _, err := GenerateJWT("user@example.com", "user", 0, 0, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseJWT(token); err != nil {
+		t.Fatalf("签发的访问令牌应能通过校验: %v", err)
+	}
+
+	mac := hmac.New(sha256.New, accessTokenKey)
+	mac.Write([]byte("payload"))
+	if SignHMAC("payload") == hex.EncodeToString(mac.Sum(nil)) {
+		t.Fatal("SignHMAC 不应使用访问令牌的密钥")
+	}
+}
+
+func TestLoadJWTKeysRequiresSecret(t *testing.T) {
+	saved := config.ProjectConfig
+	defer func() { config.ProjectConfig = saved }()
+	config.ProjectConfig = &config.Config{}
+	if err := LoadJWTKeys(); err == nil {
+		t.Fatal("未配置 jwt_key 时应拒绝启动")
+	}
+	config.ProjectConfig.Volunteer.TwtKey = "test-jwt-key-0123456789"
+	if err := LoadJWTKeys(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadJWTKeysAllowsShortSecretWithSigningKey(t *testing.T) {
+	saved := config.ProjectConfig
+	defer func() {
+		config.ProjectConfig = saved
+		activeSigningKey, verificationKeys = nil, nil
+	}()
+	config.ProjectConfig = &config.Config{}
+	config.ProjectConfig.Volunteer.TwtKey = "short"
+	if err := LoadJWTKeys(); err == nil {
+		t.Fatal("未配置 signing_key 时应拒绝过短的 jwt_key")
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.ProjectConfig.Volunteer.SigningKey = filepath.Join(t.TempDir(), "signing.pem")
+	if err := os.WriteFile(config.ProjectConfig.Volunteer.SigningKey, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadJWTKeys(); err != nil {
+		t.Fatalf("配置了 signing_key 时过短的 jwt_key 只应输出警告: %v", err)
+	}
+}
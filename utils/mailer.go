@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"volunteer-system-backend/config"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mail 一封待发送的邮件
+type Mail struct {
+	To       string
+	Subject  string
+	HTMLBody string
+}
+
+// Mailer 邮件发送接口
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+// ErrMailerNotConfigured 没有配置有效的邮件投递方式
+var ErrMailerNotConfigured = errors.New("未配置邮件投递方式")
+
+// NewMailer 根据配置创建邮件发送器，写入本地文件需要显式配置 sink 为 file
+// sink 未配置或无法识别时返回的发送器不投递任何邮件，发送时返回 ErrMailerNotConfigured
+func NewMailer() Mailer {
+	emailConfig := config.ProjectConfig.Email
+	switch emailConfig.Sink {
+	case "smtp":
+		return &SMTPMailer{
+			Host:     emailConfig.Host,
+			Port:     emailConfig.Port,
+			Username: emailConfig.Username,
+			Password: emailConfig.Password,
+			From:     emailConfig.From,
+			UseTLS:   emailConfig.UseTLS,
+		}
+	case "file":
+		dir := emailConfig.SinkDir
+		if dir == "" {
+			dir = "mail_sink"
+		}
+		return &FileMailer{Dir: dir, From: emailConfig.From}
+	case "memory":
+		return &MemoryMailer{}
+	default:
+		if emailConfig.Enabled {
+			log.Printf("邮件投递方式 %q 无效，邮件不会被发送，请将 sink 配置为 smtp、file 或 memory", emailConfig.Sink)
+		}
+		return unconfiguredMailer{}
+	}
+}
+
+// unconfiguredMailer 未配置投递方式时使用的发送器，拒绝发送所有邮件
+type unconfiguredMailer struct{}
+
+// Send 返回 ErrMailerNotConfigured
+func (unconfiguredMailer) Send(Mail) error {
+	return ErrMailerNotConfigured
+}
+
+// ErrInvalidMailHeader 邮件头中包含换行符，可能被用于注入额外的邮件头
+var ErrInvalidMailHeader = errors.New("邮件的发件人、收件人或主题中不能包含换行符")
+
+// buildMIME 构造 HTML 邮件内容，发件人、收件人和主题中包含 CR 或 LF 时拒绝构造
+func buildMIME(from string, mail Mail) ([]byte, error) {
+	for _, header := range []string{from, mail.To, mail.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, ErrInvalidMailHeader
+		}
+	}
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + mail.To + "\r\n")
+	b.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", mail.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(mail.HTMLBody)
+	return []byte(b.String()), nil
+}
+
+// SMTPMailer 通过 SMTP 服务器发送邮件
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	UseTLS   bool // 为 true 时使用 TLS 直连，否则在服务器支持时使用 STARTTLS
+}
+
+// Send 发送邮件
+func (m *SMTPMailer) Send(mail Mail) error {
+	data, err := buildMIME(m.From, mail)
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	var conn net.Conn
+	if m.UseTLS {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", addr, &tls.Config{ServerName: m.Host})
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, 10*time.Second)
+	}
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !m.UseTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: m.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	if m.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(m.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(mail.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// FileMailer 将邮件保存为本地 .eml 文件，用于开发环境
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// Send 将邮件写入文件
+func (m *FileMailer) Send(mail Mail) error {
+	data, err := buildMIME(m.From, mail)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s_%s.eml", time.Now().Format("20060102150405.000000000"), strings.NewReplacer("@", "_at_", "/", "_").Replace(mail.To))
+	return os.WriteFile(filepath.Join(m.Dir, name), data, 0o644)
+}
+
+// MemoryMailer 将邮件保存在内存中，用于测试
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []Mail
+}
+
+// Send 将邮件保存到内存，与其他发送器一样拒绝邮件头中包含换行符的邮件
+func (m *MemoryMailer) Send(mail Mail) error {
+	if _, err := buildMIME("", mail); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, mail)
+	return nil
+}
+
+// Sent 返回已发送的邮件
+func (m *MemoryMailer) Sent() []Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Mail(nil), m.sent...)
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"volunteer-system-backend/config"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildMIMERejectsHeaderInjection(t *testing.T) {
+	cases := []Mail{
+		{To: "user@example.com\r\nBcc: attacker@example.com", Subject: "主题"},
+		{To: "user@example.com", Subject: "主题\nBcc: attacker@example.com"},
+		{To: "user@example.com\n", Subject: "主题"},
+	}
+	for _, mail := range cases {
+		if _, err := buildMIME("noreply@example.com", mail); !errors.Is(err, ErrInvalidMailHeader) {
+			t.Errorf("buildMIME(%q, %q) 应返回 ErrInvalidMailHeader，实际为 %v", mail.To, mail.Subject, err)
+		}
+	}
+	if _, err := buildMIME("noreply@example.com\r\nBcc: attacker@example.com", Mail{To: "user@example.com"}); !errors.Is(err, ErrInvalidMailHeader) {
+		t.Errorf("发件人包含换行符时应返回 ErrInvalidMailHeader，实际为 %v", err)
+	}
+
+	data, err := buildMIME("noreply@example.com", Mail{To: "user@example.com", Subject: "欢迎", HTMLBody: "<p>正文\r\n第二行</p>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, body, _ := strings.Cut(string(data), "\r\n\r\n")
+	if !strings.Contains(header, "To: user@example.com\r\n") || !strings.Contains(header, "Subject: =?UTF-8?b?") {
+		t.Fatalf("邮件头不正确:\n%s", header)
+	}
+	if body != "<p>正文\r\n第二行</p>" {
+		t.Fatalf("正文中的换行不应受影响: %q", body)
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	mailer := &MemoryMailer{}
+	if err := mailer.Send(Mail{To: "a@example.com", Subject: "第一封"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mailer.Send(Mail{To: "b@example.com\r\nBcc: c@example.com", Subject: "注入"}); !errors.Is(err, ErrInvalidMailHeader) {
+		t.Fatalf("应拒绝包含换行符的收件人，实际为 %v", err)
+	}
+	sent := mailer.Sent()
+	if len(sent) != 1 || sent[0].To != "a@example.com" {
+		t.Fatalf("已发送的邮件不正确: %+v", sent)
+	}
+	// 返回的是副本，修改不影响已保存的邮件
+	sent[0].To = "changed@example.com"
+	if mailer.Sent()[0].To != "a@example.com" {
+		t.Fatal("Sent 应返回副本")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer := &FileMailer{Dir: dir, From: "noreply@example.com"}
+	if err := mailer.Send(Mail{To: "user@example.com", Subject: "主题", HTMLBody: "<p>正文</p>"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mailer.Send(Mail{To: "user@example.com", Subject: "主题\r\nBcc: attacker@example.com"}); !errors.Is(err, ErrInvalidMailHeader) {
+		t.Fatalf("应拒绝包含换行符的主题，实际为 %v", err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || !strings.HasSuffix(files[0].Name(), "_user_at_example.com.eml") {
+		t.Fatalf("应只写入一封邮件: %v", files)
+	}
+}
+
+func TestNewMailerRequiresExplicitSink(t *testing.T) {
+	saved := config.ProjectConfig
+	defer func() { config.ProjectConfig = saved }()
+	config.ProjectConfig = &config.Config{}
+
+	for _, sink := range []string{"", "smpt"} {
+		config.ProjectConfig.Email.Sink = sink
+		mailer := NewMailer()
+		if _, ok := mailer.(*FileMailer); ok {
+			t.Fatalf("sink 为 %q 时不应写入本地文件", sink)
+		}
+		if err := mailer.Send(Mail{To: "user@example.com", Subject: "主题"}); !errors.Is(err, ErrMailerNotConfigured) {
+			t.Fatalf("sink 为 %q 时应返回 ErrMailerNotConfigured，实际为 %v", sink, err)
+		}
+	}
+
+	config.ProjectConfig.Email.Sink = "file"
+	if mailer, ok := NewMailer().(*FileMailer); !ok || mailer.Dir != "mail_sink" {
+		t.Fatalf("sink 为 file 时应写入 mail_sink 目录，实际为 %#v", mailer)
+	}
+}
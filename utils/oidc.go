@@ -0,0 +1,315 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	oidcCacheTTL       = time.Hour        // 发现文档和公钥的缓存时间
+	oidcJWKSMinRefresh = time.Minute      // 遇到未知 kid 时重新获取公钥的最小间隔，防止伪造的令牌频繁触发请求
+	oidcMaxResponse    = 1 << 20          // 身份提供方响应的最大长度
+	oidcClockSkew      = 30 * time.Second // 校验 ID Token 时间时允许的时钟误差
+)
+
+// oidcHTTPClient 访问身份提供方使用的 HTTP 客户端
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// OIDCDiscovery 身份提供方的发现文档中使用到的字段
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCClaims ID Token 中使用到的声明
+type OIDCClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce"`
+	AuthorizedParty   string `json:"azp"`
+	Email             string `json:"email"`
+	EmailVerified     any    `json:"email_verified"` // 部分身份提供方以字符串形式返回
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+// IsEmailVerified 判断身份提供方是否声明邮箱已验证
+func (c *OIDCClaims) IsEmailVerified() bool {
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
+
+// HasEmailVerified 判断身份提供方是否返回了 email_verified 声明
+func (c *OIDCClaims) HasEmailVerified() bool {
+	return c.EmailVerified != nil
+}
+
+var (
+	oidcMu        sync.Mutex
+	oidcDiscovery = map[string]*cachedDiscovery{}
+	oidcKeySets   = map[string]*cachedKeySet{}
+)
+
+type cachedDiscovery struct {
+	doc       *OIDCDiscovery
+	fetchedAt time.Time
+}
+
+type cachedKeySet struct {
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// oidcGetJSON 请求身份提供方的 JSON 接口
+func oidcGetJSON(endpoint string, v any) error {
+	resp, err := oidcHTTPClient.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求 %s 返回 %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponse)).Decode(v)
+}
+
+// DiscoverOIDC 获取身份提供方的发现文档，结果缓存一小时
+func DiscoverOIDC(issuer string) (*OIDCDiscovery, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	oidcMu.Lock()
+	cached := oidcDiscovery[issuer]
+	oidcMu.Unlock()
+	if cached != nil && time.Since(cached.fetchedAt) < oidcCacheTTL {
+		return cached.doc, nil
+	}
+	var doc OIDCDiscovery
+	if err := oidcGetJSON(issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, err
+	}
+	// 发现文档中的 issuer 必须与配置一致，否则可能是被篡改的文档
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("发现文档中的 issuer %q 与配置不一致", doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("发现文档缺少必要的端点")
+	}
+	oidcMu.Lock()
+	oidcDiscovery[issuer] = &cachedDiscovery{doc: &doc, fetchedAt: time.Now()}
+	oidcMu.Unlock()
+	return &doc, nil
+}
+
+// PKCEChallenge 按 S256 方法计算 PKCE 校验码对应的挑战值
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// OIDCAuthURL 生成跳转到身份提供方的授权地址
+func OIDCAuthURL(doc *OIDCDiscovery, clientID, redirectURL string, scopes []string, state, nonce, verifier string) string {
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", clientID)
+	values.Set("redirect_uri", redirectURL)
+	values.Set("scope", strings.Join(scopes, " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", PKCEChallenge(verifier))
+	values.Set("code_challenge_method", "S256")
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + values.Encode()
+}
+
+// ExchangeOIDCCode 使用授权码和 PKCE 校验码换取 ID Token，提供客户端密钥时使用 HTTP Basic 认证
+func ExchangeOIDCCode(doc *OIDCDiscovery, clientID, clientSecret, redirectURL, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("code_verifier", verifier)
+	if clientSecret == "" {
+		form.Set("client_id", clientID)
+	}
+	req, err := http.NewRequest(http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponse)).Decode(&result); err != nil {
+		return "", fmt.Errorf("无法解析令牌端点的响应：%v", err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("令牌端点返回错误 %s", strings.TrimSpace(result.Error+" "+result.ErrorDescription))
+	}
+	if resp.StatusCode != http.StatusOK || result.IDToken == "" {
+		return "", fmt.Errorf("令牌端点返回 %s，未包含 ID Token", resp.Status)
+	}
+	return result.IDToken, nil
+}
+
+// parseJWK 将 JWK 转换为公钥，支持 RSA、EC 和 Ed25519
+func parseJWK(key map[string]string) (crypto.PublicKey, error) {
+	decode := func(name string) ([]byte, error) {
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(key[name], "="))
+	}
+	switch key["kty"] {
+	case "RSA":
+		n, err := decode("n")
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode("e")
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch key["crv"] {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("不支持的曲线 %s", key["crv"])
+		}
+		x, err := decode("x")
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode("y")
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if key["crv"] != "Ed25519" {
+			return nil, fmt.Errorf("不支持的曲线 %s", key["crv"])
+		}
+		x, err := decode("x")
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("无效的 Ed25519 公钥")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("不支持的密钥类型 %s", key["kty"])
+}
+
+// oidcKey 按 kid 查找身份提供方的签名公钥，找不到时重新获取公钥集合以支持密钥轮换
+func oidcKey(jwksURI, kid string) (crypto.PublicKey, error) {
+	oidcMu.Lock()
+	cached := oidcKeySets[jwksURI]
+	oidcMu.Unlock()
+	if cached != nil {
+		key, ok := cached.keys[kid]
+		age := time.Since(cached.fetchedAt)
+		if ok && age < oidcCacheTTL {
+			return key, nil
+		}
+		if !ok && age < oidcJWKSMinRefresh {
+			return nil, fmt.Errorf("未找到签名公钥 %q", kid)
+		}
+	}
+	var set struct {
+		Keys []map[string]any `json:"keys"`
+	}
+	if err := oidcGetJSON(jwksURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, raw := range set.Keys {
+		fields := make(map[string]string)
+		for name, value := range raw {
+			if s, ok := value.(string); ok {
+				fields[name] = s
+			}
+		}
+		if fields["use"] != "" && fields["use"] != "sig" {
+			continue
+		}
+		key, err := parseJWK(fields)
+		if err != nil {
+			continue
+		}
+		keys[fields["kid"]] = key
+	}
+	oidcMu.Lock()
+	oidcKeySets[jwksURI] = &cachedKeySet{keys: keys, fetchedAt: time.Now()}
+	oidcMu.Unlock()
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("未找到签名公钥 %q", kid)
+	}
+	return key, nil
+}
+
+// VerifyIDToken 校验 ID Token 的签名、issuer、audience、有效期和 nonce
+func VerifyIDToken(doc *OIDCDiscovery, clientID, rawIDToken, nonce string) (*OIDCClaims, error) {
+	claims := &OIDCClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return oidcKey(doc.JWKSURI, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(oidcClockSkew),
+	)
+	if err != nil {
+		return nil, err
+	}
+	// 多个 audience 时 azp 必须是本客户端
+	if (len(claims.Audience) > 1 || claims.AuthorizedParty != "") && claims.AuthorizedParty != clientID {
+		return nil, errors.New("ID Token 的 azp 与客户端不一致")
+	}
+	if nonce == "" || claims.Nonce != nonce {
+		return nil, errors.New("ID Token 的 nonce 不匹配")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("ID Token 缺少 sub")
+	}
+	return claims, nil
+}
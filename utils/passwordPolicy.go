@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"volunteer-system-backend/config"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// bcryptMaxBytes bcrypt 只使用密码的前72个字节
+const bcryptMaxBytes = 72
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonPasswords 随程序打包的常见及已泄露的弱密码
+var commonPasswords = func() map[string]bool {
+	passwords := make(map[string]bool)
+	for _, line := range strings.Split(commonPasswordList, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			passwords[strings.ToLower(line)] = true
+		}
+	}
+	return passwords
+}()
+
+// CheckPassword 按配置的密码策略校验密码，personal 为邮箱、手机号等不能用作密码的个人信息
+// 返回所有未满足的规则说明，全部满足时返回空
+func CheckPassword(password string, personal ...string) []string {
+	policy := config.ProjectConfig.PasswordPolicy
+	minLength := policy.MinLength
+	if minLength <= 0 {
+		minLength = 8
+	}
+	minClasses := policy.MinClasses
+	if minClasses <= 0 {
+		minClasses = 2
+	}
+
+	var violations []string
+	if len([]rune(password)) < minLength {
+		violations = append(violations, fmt.Sprintf("长度至少为 %d 位", minLength))
+	}
+	if len(password) > bcryptMaxBytes {
+		violations = append(violations, fmt.Sprintf("长度不能超过 %d 个字节", bcryptMaxBytes))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, ok := range []bool{upper, lower, digit, symbol} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < minClasses {
+		violations = append(violations, fmt.Sprintf("至少包含大写字母、小写字母、数字、符号中的 %d 类", minClasses))
+	}
+	if policy.RequireUpper && !upper {
+		violations = append(violations, "需要包含大写字母")
+	}
+	if policy.RequireLower && !lower {
+		violations = append(violations, "需要包含小写字母")
+	}
+	if policy.RequireDigit && !digit {
+		violations = append(violations, "需要包含数字")
+	}
+	if policy.RequireSymbol && !symbol {
+		violations = append(violations, "需要包含符号")
+	}
+
+	lowered := strings.ToLower(password)
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		local, _, _ := strings.Cut(value, "@")
+		if lowered == value || lowered == local {
+			violations = append(violations, "不能与邮箱或手机号相同")
+			break
+		}
+	}
+	if commonPasswords[lowered] {
+		violations = append(violations, "不能使用常见或已泄露的密码")
+	}
+	return violations
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter 基于滑动窗口的内存限流器，同一个 key 在窗口时间内最多允许 limit 次操作
+type RateLimiter struct {
+	limit  int
+	window time.Duration
+	mu     sync.Mutex
+	hits   map[string][]time.Time
+}
+
+// NewRateLimiter 创建限流器
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:  limit,
+		window: window,
+		hits:   make(map[string][]time.Time),
+	}
+}
+
+// Allow 判断 key 是否还可以进行一次操作，允许时记录本次操作
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	hits := l.recent(key, now)
+	if len(hits) >= l.limit {
+		l.hits[key] = hits
+		return false
+	}
+	l.hits[key] = append(hits, now)
+	return true
+}
+
+// Reset 清除 key 的操作记录
+func (l *RateLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.hits, key)
+}
+
+// recent 返回 key 在窗口时间内的操作记录，窗口内没有记录时释放该 key
+func (l *RateLimiter) recent(key string, now time.Time) []time.Time {
+	hits := l.hits[key]
+	i := 0
+	for i < len(hits) && now.Sub(hits[i]) >= l.window {
+		i++
+	}
+	hits = hits[i:]
+	if len(hits) == 0 {
+		delete(l.hits, key)
+		return nil
+	}
+	return hits
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"volunteer-system-backend/config"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod = 30 // 每个验证码的有效时长（秒）
+	totpDigits = 6  // 验证码位数
+	totpSkew   = 1  // 允许前后偏差的时间步数，容忍客户端时钟误差
+)
+
+// encryptedTOTPPrefix 加密保存的 TOTP 密钥的前缀，用于区分升级前以明文保存的密钥
+const encryptedTOTPPrefix = "enc:v1:"
+
+var (
+	// ErrTOTPKeyNotConfigured 没有配置加密 TOTP 密钥使用的密钥文件
+	ErrTOTPKeyNotConfigured = errors.New("服务器未配置两步验证密钥的加密密钥")
+	// ErrInvalidTOTPCiphertext 保存的 TOTP 密钥无法解密
+	ErrInvalidTOTPCiphertext = errors.New("两步验证密钥无法解密")
+)
+
+// totpAEAD 加密 TOTP 密钥使用的 AES-256-GCM，未配置密钥文件时为空
+var totpAEAD cipher.AEAD
+
+// totpEncoding 不带填充的 Base32 编码，与常见的身份验证器应用兼容
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret 生成 160 位的随机 TOTP 密钥，以 Base32 编码返回
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// TOTPCode 按 RFC 6238 计算指定时间步的验证码
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%uint32(math.Pow10(totpDigits))), nil
+}
+
+// ValidateTOTP 校验验证码，返回匹配的时间步，调用方需要拒绝不大于上次使用的时间步以防止验证码被重放
+func ValidateTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPURI 生成身份验证器应用使用的 otpauth URI，前端可以将其渲染为二维码
+func TOTPURI(issuer, account, secret string) string {
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", issuer)
+	values.Set("algorithm", "SHA1")
+	values.Set("digits", fmt.Sprint(totpDigits))
+	values.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + values.Encode()
+}
+
+// LoadTOTPKey 加载加密 TOTP 密钥使用的 AES-256 密钥，启动时调用
+// 密钥保存在数据库之外的文件中，内容为 Base64 编码的 32 字节随机数，可以使用 openssl rand -base64 32 生成
+func LoadTOTPKey() error {
+	path := config.ProjectConfig.Account.TOTPKeyFile
+	if path == "" {
+		totpAEAD = nil
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		return fmt.Errorf("%s 的内容应为 Base64 编码的 32 字节密钥", path)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	totpAEAD = aead
+	return nil
+}
+
+// TOTPKeyConfigured 是否已加载加密 TOTP 密钥使用的密钥
+func TOTPKeyConfigured() bool {
+	return totpAEAD != nil
+}
+
+// IsEncryptedTOTPSecret 判断保存的 TOTP 密钥是否已经加密
+func IsEncryptedTOTPSecret(stored string) bool {
+	return strings.HasPrefix(stored, encryptedTOTPPrefix)
+}
+
+// EncryptTOTPSecret 使用 AES-256-GCM 加密 TOTP 密钥，返回带版本前缀的 Base64 字符串
+func EncryptTOTPSecret(secret string) (string, error) {
+	if totpAEAD == nil {
+		return "", ErrTOTPKeyNotConfigured
+	}
+	nonce := make([]byte, totpAEAD.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := totpAEAD.Seal(nonce, nonce, []byte(secret), nil)
+	return encryptedTOTPPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptTOTPSecret 解密 EncryptTOTPSecret 加密的 TOTP 密钥，不接受未加密的密钥
+func DecryptTOTPSecret(stored string) (string, error) {
+	if totpAEAD == nil {
+		return "", ErrTOTPKeyNotConfigured
+	}
+	encoded, ok := strings.CutPrefix(stored, encryptedTOTPPrefix)
+	if !ok {
+		return "", ErrInvalidTOTPCiphertext
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < totpAEAD.NonceSize() {
+		return "", ErrInvalidTOTPCiphertext
+	}
+	nonce, ciphertext := sealed[:totpAEAD.NonceSize()], sealed[totpAEAD.NonceSize():]
+	secret, err := totpAEAD.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrInvalidTOTPCiphertext
+	}
+	return string(secret), nil
+}
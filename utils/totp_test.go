@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"volunteer-system-backend/config"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func loadTestTOTPKey(t *testing.T) {
+	t.Helper()
+	saved := config.ProjectConfig
+	t.Cleanup(func() {
+		config.ProjectConfig = saved
+		totpAEAD = nil
+	})
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	config.ProjectConfig = &config.Config{}
+	config.ProjectConfig.Account.TOTPKeyFile = filepath.Join(t.TempDir(), "totp.key")
+	if err := os.WriteFile(config.ProjectConfig.Account.TOTPKeyFile, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadTOTPKey(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncryptTOTPSecret(t *testing.T) {
+	loadTestTOTPKey(t)
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := EncryptTOTPSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncryptedTOTPSecret(encrypted) || len(encrypted) > 128 {
+		t.Fatalf("加密后的密钥格式不正确: %q", encrypted)
+	}
+	if decrypted, err := DecryptTOTPSecret(encrypted); err != nil || decrypted != secret {
+		t.Fatalf("解密结果应为 %q，实际为 %q（%v）", secret, decrypted, err)
+	}
+
+	tampered := []byte(encrypted)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := DecryptTOTPSecret(string(tampered)); !errors.Is(err, ErrInvalidTOTPCiphertext) {
+		t.Fatalf("被篡改的密钥应无法解密，实际为 %v", err)
+	}
+	if _, err := DecryptTOTPSecret(secret); !errors.Is(err, ErrInvalidTOTPCiphertext) {
+		t.Fatalf("不应接受明文保存的密钥，实际为 %v", err)
+	}
+}
+
+func TestEncryptTOTPSecretRequiresKey(t *testing.T) {
+	totpAEAD = nil
+	if _, err := EncryptTOTPSecret("JBSWY3DPEHPK3PXP"); !errors.Is(err, ErrTOTPKeyNotConfigured) {
+		t.Fatalf("未配置密钥时应返回 ErrTOTPKeyNotConfigured，实际为 %v", err)
+	}
+}